@@ -5,26 +5,46 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/brandonlbarrow/jaggerbot/internal/discord"
+	"github.com/brandonlbarrow/jaggerbot/internal/secrets"
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
 	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
 	"github.com/brandonlbarrow/jaggerbot/internal/webserver"
 	"github.com/joho/godotenv"
 )
 
+// eventSubSecretOverlap is how long the previous EventSub secret is accepted after a rotation.
+const eventSubSecretOverlap = 10 * time.Minute
+
+const (
+	// callbackMaxFailures requests failing verification within callbackFailureWindow block a client
+	// until the window passes.
+	callbackMaxFailures   = 10
+	callbackFailureWindow = 10 * time.Minute
+)
+
 func main() {
 
 	godotenv.Load()
 
-	eventChan := make(chan twitchws.Event)
-	errorEventChan := make(chan error)
+	// The queues let the webserver answer Twitch while an earlier notification is still dispatched.
+	eventChan := make(chan twitchws.Notification, 100)
+	errorEventChan := make(chan error, 100)
 
+	discordTransport, err := discord.NewSessionTransport(secrets.Get("DISCORD_BOT_TOKEN"))
+	if err != nil {
+		log.Fatalf("error creating discord client, cannot continue: %s", err)
+	}
 	discordConfig := discord.Config{
+		Transport:         discordTransport,
 		DiscordGuildID:    os.Getenv("DISCORD_GUILD_ID"),
-		DiscordBotToken:   os.Getenv("DISCORD_BOT_TOKEN"),
 		DiscordChannelIDs: strings.Split(os.Getenv("DISCORD_CHANNEL_IDS"), ","),
 		AdminChannelIDs:   strings.Split(os.Getenv("DISCORD_ADMIN_CHANNEL_IDs"), ","),
+		AdminRoleID:       os.Getenv("DISCORD_ADMIN_ROLE_ID"),
 		EventChannel:      eventChan,
 	}
 
@@ -32,54 +52,406 @@ func main() {
 	if err != nil {
 		log.Fatalf("error creating discord client, cannot continue: %s", err)
 	}
+	notifier := discord.NewAdminNotifier(discordClient, notifierConfig())
+	stop := make(chan struct{})
+	go notifier.Run(stop)
+	if os.Getenv("JAGGER_PUBLIC_URL") == "" && os.Getenv("JAGGER_HOSTNAME") == "" {
+		notifier.Warning("JAGGER_HOSTNAME and JAGGER_PUBLIC_URL are not set", fmt.Sprintf("Twitch is sent callbacks for %s, set either to the public address of the callback server.", twitchws.PublicURL()))
+	}
+
+	storePath := os.Getenv("JAGGER_STORE_PATH")
+	if storePath == "" {
+		storePath = "data/jagger.json"
+	}
+	jaggerStore, err := store.Open(storePath)
+	if err != nil {
+		log.Fatalf("error opening store, cannot continue: %s", err)
+	}
+	// keystore is nil unless JAGGER_KEYSTORE_KEY is set, Twitch authorization and EventSub secret
+	// rotation need it to store their secrets.
+	var keystore *secrets.Keystore
+	if keystoreKey := secrets.Get("JAGGER_KEYSTORE_KEY"); keystoreKey != "" {
+		keystore, err = secrets.NewKeystore(jaggerStore, keystoreKey)
+		if err != nil {
+			log.Fatalf("error opening keystore, cannot continue: %s", err)
+		}
+	} else {
+		notifier.Warning("JAGGER_KEYSTORE_KEY is not set", "Twitch authorization through /twitch-consent and EventSub secret rotation are disabled until it is set.")
+	}
+	eventSubSecrets, err := secrets.LoadEventSubSecrets(jaggerStore, keystore, secrets.Get("TWITCH_EVENTSUB_SECRET"))
+	if err != nil {
+		log.Fatalf("error loading eventsub secrets, cannot continue: %s", err)
+	}
+	tokens := twitchws.NewTokenStore(jaggerStore, keystore)
+	// subscriberRoles is nil unless subscriber roles are configured.
+	var subscriberRoles *discord.SubscriberRoleSync
+	oauthHandler := &webserver.OAuthHandler{
+		Tokens:            tokens,
+		ErrorEventChannel: errorEventChan,
+		OnAuthorized: func(token *twitchws.UserToken) {
+			notifier.Info(fmt.Sprintf("%s authorized jagger on Twitch", token.Login), fmt.Sprintf("Scopes: `%s`", strings.Join(token.Scopes, " ")))
+			if token.UserID != os.Getenv("TWITCH_SENSAI_USER_ID") {
+				return
+			}
+			if err := twitchws.SubscribeAuthorized(tokens, token.UserID, twitchws.WebhookTransport(eventSubSecrets.Current())); err != nil {
+				notifier.Error("Could not subscribe to broadcaster authorized events", err)
+				return
+			}
+			notifier.Info("Subscribed to broadcaster authorized events", fmt.Sprintf("%s's granted scopes are now in use.", token.Login))
+		},
+		OnLinked: func(discordUserID, twitchUserID, twitchLogin string) error {
+			if err := discord.LinkAccount(jaggerStore, discordUserID, twitchUserID, twitchLogin); err != nil {
+				return err
+			}
+			notifier.Debug(fmt.Sprintf("%s linked their Twitch account", twitchLogin), fmt.Sprintf("Discord user <@%s>", discordUserID))
+			if subscriberRoles != nil {
+				go subscriberRoles.SyncUser(twitchUserID)
+			}
+			return nil
+		},
+	}
+	if keystore != nil {
+		discordClient.AddCommand(discord.ConsentCommand(oauthHandler.NewConsentLink, twitchws.DefaultBroadcasterScopes))
+	}
+	eventLog := twitchws.NewEventLog(100)
+	discordClient.AddCommand(discordClient.TwitchCommand(func() error {
+		return twitchws.SetupTwitch(tokens, twitchws.WebhookTransport(eventSubSecrets.Current()))
+	}, eventLog))
+	discordClient.AddCommand(discord.ClipCommand(func() (string, error) {
+		return twitchws.CreateClip(tokens)
+	}))
+	discordClient.AddCommand(discord.RewardsCommand(tokens))
+	discordClient.AddCommand(discord.LinkCommand(jaggerStore, oauthHandler.NewAccountLink))
+	discordClient.AddCommand(discord.UnlinkCommand(jaggerStore))
+	var modLog *discord.ModerationLog
+	if channelID := os.Getenv("DISCORD_MODLOG_CHANNEL_ID"); channelID != "" {
+		retention, _ := time.ParseDuration(os.Getenv("DISCORD_MODLOG_RETENTION"))
+		maxEntries, _ := strconv.Atoi(os.Getenv("DISCORD_MODLOG_MAX_ENTRIES"))
+		modLog = discord.NewModerationLog(discordClient, jaggerStore, discord.ModerationLogConfig{
+			ChannelID:  channelID,
+			Retention:  retention,
+			MaxEntries: maxEntries,
+			Timeouts:   os.Getenv("DISCORD_MODLOG_TIMEOUTS") == "true",
+		})
+		discordClient.AddCommand(modLog.SearchCommand())
+		go modLog.Run(stop)
+	}
+	var banSync *discord.BanSync
+	if os.Getenv("DISCORD_BAN_SYNC") == "true" {
+		banSync = discord.NewBanSync(discordClient, jaggerStore, func(userID, reason string) error {
+			return twitchws.BanUser(tokens, userID, reason)
+		})
+	}
+
 	done := make(chan error)
 	go runDiscordClient(discordClient, done)
-	discordClient.SendAdminMessage("started jagger discord client...")
-	go runCallbackServer(eventChan, errorEventChan, done)
-	discordClient.SendAdminMessage("started jagger webserver...")
-	if err = twitchws.SetupTwitch(); err != nil {
-		discordClient.SendAdminMessage(fmt.Sprintf("jagger ran into an error. OOPSIE WOOPSIE! %s", err.Error()))
+	notifier.Debug("Discord client started", "")
+	go runCallbackServer(eventChan, errorEventChan, eventSubSecrets, oauthHandler, done)
+	notifier.Debug("Webserver started", "")
+	if err = twitchws.SetupTwitch(tokens, twitchws.WebhookTransport(eventSubSecrets.Current())); err != nil {
+		notifier.Error("Twitch setup failed", err)
 		log.Fatalf("error creating twitch client, cannot continue: %s", err)
 	}
+	if rotation, err := time.ParseDuration(os.Getenv("TWITCH_EVENTSUB_SECRET_ROTATION")); err == nil && keystore != nil {
+		go rotateEventSubSecret(eventSubSecrets, tokens, rotation, notifier, stop)
+	}
+	if channelID := os.Getenv("DISCORD_SCHEDULE_CHANNEL_ID"); channelID != "" {
+		reminderMinutes, _ := strconv.Atoi(os.Getenv("DISCORD_SCHEDULE_REMINDER_MINUTES"))
+		syncInterval, _ := time.ParseDuration(os.Getenv("DISCORD_SCHEDULE_SYNC_INTERVAL"))
+		scheduleAnnouncer := discord.NewScheduleAnnouncer(discordClient, jaggerStore, discord.ScheduleConfig{
+			ChannelID:      channelID,
+			ReminderBefore: time.Duration(reminderMinutes) * time.Minute,
+			SyncInterval:   syncInterval,
+		})
+		go scheduleAnnouncer.Run(stop)
+	}
 	if resp, err := twitchws.GetChannelInformation(); err != nil {
 		log.Printf("could not get channel info: %s", err)
 	} else {
 		log.Println(resp)
 	}
+	var liveEvent *discord.LiveEvent
+	if os.Getenv("DISCORD_LIVE_EVENTS") == "true" {
+		liveEvent = discord.NewLiveEvent(discordClient, jaggerStore)
+		go liveEvent.Run(stop)
+	}
+	liveStatusConfig := discord.LiveStatusConfig{
+		LiveName:    os.Getenv("DISCORD_LIVE_CHANNEL_NAME"),
+		OfflineName: os.Getenv("DISCORD_LIVE_CHANNEL_OFFLINE_NAME"),
+	}
+	liveStatusConfig.RenameInterval, _ = time.ParseDuration(os.Getenv("DISCORD_LIVE_CHANNEL_RENAME_INTERVAL"))
+	if channelID := os.Getenv("DISCORD_LIVE_CHANNEL_ID"); channelID != "" && liveStatusConfig.OfflineName == "" {
+		notifier.Warning("DISCORD_LIVE_CHANNEL_OFFLINE_NAME is not set", "The live channel is not renamed, it could not be renamed back when the stream goes offline.")
+	} else {
+		liveStatusConfig.ChannelID = channelID
+	}
+	liveStatus := discord.NewLiveStatus(discordClient, liveStatusConfig)
+	var relay *discord.ChatRelay
+	// chat is nil unless the Twitch chat client is enabled.
+	var chat discord.ChatSender
+	if secrets.Get("TWITCH_CHAT_OAUTH_TOKEN") != "" {
+		chatClient, err := newChatClient()
+		if err != nil {
+			notifier.Error("Could not create Twitch chat client", err)
+		} else {
+			chat = chatClient
+			go chatClient.RunIRCClient(stop)
+			notifier.Debug("Twitch chat client started", "")
+			if channelID := os.Getenv("DISCORD_RELAY_CHANNEL_ID"); channelID != "" {
+				relay = discord.NewChatRelay(discordClient, chatClient, discord.RelayConfig{
+					ChannelID:     channelID,
+					TwoWay:        os.Getenv("DISCORD_RELAY_TWO_WAY") == "true",
+					Format:        os.Getenv("DISCORD_RELAY_FORMAT"),
+					IgnoredUsers:  strings.Split(os.Getenv("DISCORD_RELAY_IGNORED_USERS"), ","),
+					RelayCommands: os.Getenv("DISCORD_RELAY_COMMANDS") == "true",
+				})
+				if stream, err := twitchws.GetStream(); err != nil {
+					log.Printf("could not get stream to start chat relay: %s", err)
+				} else if stream != nil {
+					relay.Start()
+				}
+			}
+		}
+	}
+	if channelID := os.Getenv("DISCORD_CLIPS_CHANNEL_ID"); channelID != "" {
+		broadcasterIDs := []string{os.Getenv("TWITCH_SENSAI_USER_ID")}
+		if ids := os.Getenv("TWITCH_CLIP_BROADCASTER_IDS"); ids != "" {
+			broadcasterIDs = strings.Split(ids, ",")
+		}
+		clipInterval, _ := time.ParseDuration(os.Getenv("DISCORD_CLIPS_INTERVAL"))
+		clipPoller := discord.NewClipPoller(discordClient, jaggerStore, discord.ClipConfig{
+			ChannelID:      channelID,
+			BroadcasterIDs: broadcasterIDs,
+			Interval:       clipInterval,
+		})
+		go clipPoller.Run(stop)
+	}
+	var vods *discord.VODPoller
+	if channelID := os.Getenv("DISCORD_VODS_CHANNEL_ID"); channelID != "" {
+		broadcasterIDs := []string{os.Getenv("TWITCH_SENSAI_USER_ID")}
+		if ids := os.Getenv("TWITCH_VOD_BROADCASTER_IDS"); ids != "" {
+			broadcasterIDs = strings.Split(ids, ",")
+		}
+		vodInterval, _ := time.ParseDuration(os.Getenv("DISCORD_VODS_INTERVAL"))
+		vods = discord.NewVODPoller(discordClient, jaggerStore, discord.VODConfig{
+			ChannelID:      channelID,
+			BroadcasterIDs: broadcasterIDs,
+			Highlights:     os.Getenv("DISCORD_VODS_HIGHLIGHTS") == "true",
+			Interval:       vodInterval,
+		})
+		go vods.Run(stop)
+	}
+	var celebrations *discord.CelebrationFeed
+	if channelID := os.Getenv("DISCORD_CELEBRATIONS_CHANNEL_ID"); channelID != "" {
+		celebrations = discord.NewCelebrationFeed(discordClient, jaggerStore, func() (int, error) {
+			return twitchws.GetFollowerCount(tokens)
+		}, celebrationConfig(channelID))
+		go celebrations.Run(stop)
+	}
+	if tierRoles, err := discord.ParseTierRoles(os.Getenv("DISCORD_SUBSCRIBER_ROLES")); err != nil {
+		notifier.Error("Could not parse DISCORD_SUBSCRIBER_ROLES, subscriber roles are not synced", err)
+	} else if len(tierRoles) > 0 {
+		grace, _ := time.ParseDuration(os.Getenv("DISCORD_SUBSCRIBER_ROLE_GRACE"))
+		syncInterval, _ := time.ParseDuration(os.Getenv("DISCORD_SUBSCRIBER_ROLE_SYNC_INTERVAL"))
+		subscriberRoles = discord.NewSubscriberRoleSync(discordClient, jaggerStore, notifier, func(userIDs ...string) ([]twitchws.ChannelSubscription, error) {
+			return twitchws.GetBroadcasterSubscriptions(tokens, userIDs...)
+		}, discord.SubscriberRoleConfig{
+			TierRoleIDs: tierRoles,
+			GracePeriod: grace,
+			Interval:    syncInterval,
+		})
+		go subscriberRoles.Run(stop)
+	}
+	var polls *discord.PollMirror
+	if os.Getenv("DISCORD_POLLS") == "true" {
+		var pollChannelIDs []string
+		if channelIDs := os.Getenv("DISCORD_POLLS_CHANNEL_IDS"); channelIDs != "" {
+			pollChannelIDs = strings.Split(channelIDs, ",")
+		}
+		polls = discord.NewPollMirror(discordClient, jaggerStore, discord.PollConfig{ChannelIDs: pollChannelIDs})
+	}
+	var hypeTrains *discord.HypeTrainTracker
+	if os.Getenv("DISCORD_HYPE_TRAINS") == "true" {
+		var hypeTrainChannelIDs []string
+		if channelIDs := os.Getenv("DISCORD_HYPE_TRAIN_CHANNEL_IDS"); channelIDs != "" {
+			hypeTrainChannelIDs = strings.Split(channelIDs, ",")
+		}
+		hypeTrains = discord.NewHypeTrainTracker(discordClient, jaggerStore, discord.HypeTrainConfig{
+			ChannelIDs: hypeTrainChannelIDs,
+			PingRoleID: os.Getenv("DISCORD_HYPE_TRAIN_ROLE_ID"),
+		})
+	}
+	var redemptions *discord.RedemptionHandler
+	if actions, err := discord.ParseRedemptionActions(os.Getenv("DISCORD_REDEMPTION_ACTIONS")); err != nil {
+		notifier.Error("Could not parse DISCORD_REDEMPTION_ACTIONS, channel points redemptions are ignored", err)
+	} else if len(actions) > 0 {
+		var redemptionChannelIDs []string
+		if channelIDs := os.Getenv("DISCORD_REDEMPTION_CHANNEL_IDS"); channelIDs != "" {
+			redemptionChannelIDs = strings.Split(channelIDs, ",")
+		}
+		redemptions = discord.NewRedemptionHandler(discordClient, jaggerStore, discord.RedemptionConfig{
+			ChannelIDs: redemptionChannelIDs,
+			Actions:    actions,
+		})
+		go redemptions.Run(stop)
+	}
+	raidMinViewers, _ := strconv.Atoi(os.Getenv("DISCORD_RAID_MIN_VIEWERS"))
+	var raidChannelIDs []string
+	if channelIDs := os.Getenv("DISCORD_RAID_CHANNEL_IDS"); channelIDs != "" {
+		raidChannelIDs = strings.Split(channelIDs, ",")
+	}
+	raids := discord.NewRaidAnnouncer(discordClient, chat, discord.RaidConfig{
+		ChannelIDs:     raidChannelIDs,
+		MinViewers:     raidMinViewers,
+		ThankYou:       os.Getenv("TWITCH_RAID_THANK_YOU") == "true",
+		ThankYouFormat: os.Getenv("TWITCH_RAID_THANK_YOU_FORMAT"),
+	})
+	dispatcher := &discord.Dispatcher{
+		Client:          discordClient,
+		Notifier:        notifier,
+		BroadcasterID:   os.Getenv("TWITCH_SENSAI_USER_ID"),
+		EventLog:        eventLog,
+		Relay:           relay,
+		LiveEvent:       liveEvent,
+		LiveStatus:      liveStatus,
+		VODs:            vods,
+		Raids:           raids,
+		Celebrations:    celebrations,
+		SubscriberRoles: subscriberRoles,
+		Polls:           polls,
+		HypeTrains:      hypeTrains,
+		Redemptions:     redemptions,
+		ModLog:          modLog,
+		BanSync:         banSync,
+	}
+	notifier.Info("Jagger is listening for Twitch events", "")
 	for {
-		var event twitchws.Event
+		var notification twitchws.Notification
 		var errEvent error
-		discordClient.SendAdminMessage("jagger is listening for Twitch events...")
 		select {
 		case runErr := <-done:
 			if runErr != nil {
-				discordClient.SendAdminMessage(fmt.Sprintf("jagger ran into an error. OOPSIE WOOPSIE! %s", runErr.Error()))
+				notifier.Error("Jagger ran into an error. OOPSIE WOOPSIE!", runErr)
+				close(stop)
 				log.Fatal(runErr)
 			}
-		case event = <-eventChan:
-			log.Printf("received event: %v\n", event)
-			discordClient.SendAdminMessage(fmt.Sprintf("jagger received an event from Twitch: \n%v", event))
-			resp, err := twitchws.GetChannelInformation()
+		case notification = <-eventChan:
+			dispatcher.Dispatch(notification)
+		case errEvent = <-errorEventChan:
+			log.Printf("webserver encountered error: %s", errEvent)
+			notifier.Error("Webserver had an error handling a Twitch event", errEvent)
+		}
+	}
+
+}
+
+// rotateEventSubSecret rotates the EventSub secret whenever it is older than every, recreating the
+// subscriptions with the new secret while the old one stays accepted for eventSubSecretOverlap.
+func rotateEventSubSecret(eventSubSecrets *secrets.EventSubSecrets, tokens *twitchws.TokenStore, every time.Duration, notifier *discord.AdminNotifier, stop chan struct{}) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if time.Since(eventSubSecrets.RotatedAt()) < every {
+				continue
+			}
+			secret, generation, err := eventSubSecrets.Rotate(eventSubSecretOverlap)
 			if err != nil {
-				discordClient.SendAdminMessage(fmt.Sprintf("jagger could not get channel information for stream announcement. Sending a normal message. Error: \n%s", err.Error()))
-				discordClient.SendMessage("get in here, Sensai's shitting it up! https://twitch.tv/sensaiopti")
-			} else if len(resp.Data) != 1 {
-				discordClient.SendAdminMessage(fmt.Sprintf("jagger got game information but the response was not expected: %v", resp.Data))
-			} else {
-				var gameName, streamTitle string
-				for _, d := range resp.Data {
-					gameName = d.GameName
-					streamTitle = d.Title
+				notifier.Error("Could not rotate EventSub secret", err)
+				continue
+			}
+			if err := twitchws.RotateSubscriptions(tokens, twitchws.WebhookTransport(secret, generation)); err != nil {
+				if revertErr := eventSubSecrets.Revert(generation); revertErr != nil {
+					err = fmt.Errorf("%w, and the rotation could not be reverted: %s", err, revertErr)
 				}
-				discordClient.SendMessageEmbed("get in here, Sensai's shitting it up!", gameName, streamTitle)
+				notifier.Error("Could not recreate EventSub subscriptions with rotated secret, keeping the previous secret", err)
+				continue
 			}
+			notifier.Info("Rotated EventSub secret", fmt.Sprintf("Subscriptions now use secret generation %d, the previous secret is accepted for %s.", generation, eventSubSecretOverlap))
+		}
+	}
+}
 
-		case errEvent = <-errorEventChan:
-			log.Printf("webserver encountered error: %s", errEvent)
-			discordClient.SendAdminMessage(fmt.Sprintf("jagger webserver had error handling Twitch event: %s", errEvent.Error()))
+func newChatClient() (*twitchws.Client, error) {
+	commands, err := twitchws.ParseChatCommands(os.Getenv("TWITCH_CHAT_COMMANDS"))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing TWITCH_CHAT_COMMANDS: %w", err)
+	}
+	timers, err := twitchws.ParseChatTimers(os.Getenv("TWITCH_CHAT_TIMERS"))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing TWITCH_CHAT_TIMERS: %w", err)
+	}
+	cooldown, _ := time.ParseDuration(os.Getenv("TWITCH_CHAT_COMMAND_COOLDOWN"))
+	channel := os.Getenv("TWITCH_CHAT_CHANNEL")
+	if channel == "" {
+		channel = "sensaiopti"
+	}
+	return twitchws.NewIRCClient(twitchws.ChatConfig{
+		Channel:         channel,
+		OAuthToken:      secrets.Get("TWITCH_CHAT_OAUTH_TOKEN"),
+		BotVerified:     os.Getenv("TWITCH_CHAT_BOT_VERIFIED") == "true",
+		Moderator:       os.Getenv("TWITCH_CHAT_MODERATOR") == "true",
+		Commands:        commands,
+		CommandCooldown: cooldown,
+		Timers:          timers,
+	})
+}
+
+// celebrationConfig reads the celebration feed configuration. DISCORD_CELEBRATIONS lists the enabled
+// kinds of celebrations, all of them if it is empty.
+func celebrationConfig(channelID string) discord.CelebrationConfig {
+	enabled := make(map[string]bool)
+	for _, kind := range strings.Split(os.Getenv("DISCORD_CELEBRATIONS"), ",") {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			enabled[kind] = true
 		}
 	}
+	all := len(enabled) == 0
+	batchWindow, _ := time.ParseDuration(os.Getenv("DISCORD_CELEBRATIONS_BATCH_WINDOW"))
+	var milestones []int
+	for _, milestone := range strings.Split(os.Getenv("DISCORD_FOLLOWER_MILESTONES"), ",") {
+		if m, err := strconv.Atoi(strings.TrimSpace(milestone)); err == nil {
+			milestones = append(milestones, m)
+		}
+	}
+	return discord.CelebrationConfig{
+		ChannelID:          channelID,
+		BatchWindow:        batchWindow,
+		Follows:            all || enabled["follows"],
+		Subscriptions:      all || enabled["subscriptions"],
+		Resubscriptions:    all || enabled["resubscriptions"],
+		Gifts:              all || enabled["gifts"],
+		Cheers:             all || enabled["cheers"],
+		FollowerMilestones: milestones,
+	}
+}
 
+func notifierConfig() discord.NotifierConfig {
+	defaultLevel, err := discord.ParseSeverity(os.Getenv("DISCORD_ADMIN_MIN_LEVEL"))
+	if err != nil {
+		log.Printf("invalid DISCORD_ADMIN_MIN_LEVEL, using info: %s", err)
+	}
+	channelLevels, err := discord.ParseChannelSeverities(os.Getenv("DISCORD_ADMIN_CHANNEL_LEVELS"))
+	if err != nil {
+		log.Printf("invalid DISCORD_ADMIN_CHANNEL_LEVELS, ignoring: %s", err)
+	}
+	dedupWindow, err := time.ParseDuration(os.Getenv("DISCORD_ADMIN_DEDUP_WINDOW"))
+	if err != nil {
+		dedupWindow = 5 * time.Minute
+	}
+	maxPerMinute, _ := strconv.Atoi(os.Getenv("DISCORD_ADMIN_MAX_PER_MINUTE"))
+	digestHour, _ := strconv.Atoi(os.Getenv("DISCORD_ADMIN_DIGEST_HOUR"))
+	return discord.NotifierConfig{
+		DefaultLevel:  defaultLevel,
+		ChannelLevels: channelLevels,
+		DedupWindow:   dedupWindow,
+		MaxPerMinute:  maxPerMinute,
+		DailyDigest:   os.Getenv("DISCORD_ADMIN_DAILY_DIGEST") == "true",
+		DigestHour:    digestHour,
+	}
 }
 
 func runDiscordClient(client *discord.Client, done chan error) error {
@@ -89,12 +461,46 @@ func runDiscordClient(client *discord.Client, done chan error) error {
 	return nil
 }
 
-func runCallbackServer(eventChan chan twitchws.Event, errorEventChan, done chan error) error {
-	server := http.NewServeMux()
-	handler := webserver.Handler{EventChannel: eventChan, ErrorEventChannel: errorEventChan}
-	server.HandleFunc("/jagger/callback", handler.HandleTwitchCallback)
-	log.Println("listening on :8080 for /jagger/callback")
-	if err := http.ListenAndServe(":8080", server); err != nil {
+func listenConfig() webserver.ListenConfig {
+	config := webserver.ListenConfig{
+		Addr:             os.Getenv("JAGGER_LISTEN_ADDR"),
+		RedirectAddr:     os.Getenv("JAGGER_REDIRECT_ADDR"),
+		Hostname:         os.Getenv("JAGGER_HOSTNAME"),
+		CertFile:         os.Getenv("JAGGER_TLS_CERT_FILE"),
+		KeyFile:          os.Getenv("JAGGER_TLS_KEY_FILE"),
+		Autocert:         os.Getenv("JAGGER_AUTOCERT") == "true",
+		AutocertCacheDir: os.Getenv("JAGGER_AUTOCERT_CACHE_DIR"),
+		AutocertEmail:    os.Getenv("JAGGER_AUTOCERT_EMAIL"),
+	}
+	if config.AutocertCacheDir == "" {
+		config.AutocertCacheDir = "data/autocert"
+	}
+	if config.TLS() {
+		if config.Addr == "" {
+			config.Addr = ":443"
+		}
+		if config.RedirectAddr == "" {
+			config.RedirectAddr = ":80"
+		}
+	} else if config.Addr == "" {
+		config.Addr = ":8080"
+	}
+	return config
+}
+
+func runCallbackServer(eventChan chan twitchws.Notification, errorEventChan chan error, eventSubSecrets *secrets.EventSubSecrets, oauthHandler *webserver.OAuthHandler, done chan error) error {
+	mux := http.NewServeMux()
+	handler := webserver.Handler{
+		EventChannel:      eventChan,
+		ErrorEventChannel: errorEventChan,
+		Secrets:           eventSubSecrets,
+		Limiter:           webserver.NewFailureLimiter(callbackMaxFailures, callbackFailureWindow),
+		TrustProxy:        os.Getenv("JAGGER_TRUST_PROXY") == "true",
+	}
+	mux.HandleFunc("/jagger/callback", handler.HandleTwitchCallback)
+	mux.HandleFunc("/jagger/oauth/start", oauthHandler.HandleStart)
+	mux.HandleFunc("/jagger/oauth/callback", oauthHandler.HandleCallback)
+	if err := webserver.ListenAndServe(listenConfig(), mux); err != nil {
 		done <- fmt.Errorf("error running callback server: %w", err)
 	}
 	return nil
@@ -0,0 +1,95 @@
+// jaggersim sends simulated Twitch EventSub webhook messages to a running jagger, signed with the
+// same secret jagger verifies them with.
+//
+//	go run ./cmd/jaggersim -type stream.online
+//	go run ./cmd/jaggersim -message revocation -type channel.cheer
+//	go run ./cmd/jaggersim -replay captured.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/eventsubtest"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	godotenv.Load()
+
+	callbackURL := flag.String("url", "http://localhost:8080/jagger/callback", "jagger callback URL")
+	secret := flag.String("secret", os.Getenv("TWITCH_EVENTSUB_SECRET"), "EventSub secret to sign messages with")
+	broadcasterID := flag.String("broadcaster", os.Getenv("TWITCH_SENSAI_USER_ID"), "broadcaster user ID of the simulated events")
+	subscriptionType := flag.String("type", "all", fmt.Sprintf("subscription type to simulate, or all: %s", strings.Join(eventsubtest.SubscriptionTypes(), ", ")))
+	messageType := flag.String("message", eventsubtest.MessageTypeNotification, "message to send: verification, notification, revocation or all")
+	revocationStatus := flag.String("revocation-status", "authorization_revoked", "subscription status sent with revocations")
+	replay := flag.String("replay", "", "file with a captured message body to send again instead of a simulated one")
+	flag.Parse()
+
+	sim := &eventsubtest.Simulator{
+		CallbackURL:   *callbackURL,
+		Secret:        *secret,
+		BroadcasterID: *broadcasterID,
+	}
+
+	if *replay != "" {
+		body, err := os.ReadFile(*replay)
+		if err != nil {
+			log.Fatalf("error reading captured payload: %s", err)
+		}
+		resp, err := sim.Replay(body)
+		report("replay "+*replay, resp, err)
+		return
+	}
+
+	types := []string{*subscriptionType}
+	if *subscriptionType == "all" {
+		types = eventsubtest.SubscriptionTypes()
+	}
+	messages := []string{*messageType}
+	if *messageType == "all" {
+		messages = []string{"verification", eventsubtest.MessageTypeNotification, eventsubtest.MessageTypeRevocation}
+	}
+	failed := false
+	for _, t := range types {
+		for _, m := range messages {
+			switch m {
+			case "verification":
+				failed = report(fmt.Sprintf("%s verification", t), nil, sim.Verify(t)) || failed
+			case eventsubtest.MessageTypeNotification:
+				resp, err := sim.Notify(t)
+				failed = report(fmt.Sprintf("%s notification", t), resp, err) || failed
+			case eventsubtest.MessageTypeRevocation:
+				resp, err := sim.Revoke(t, *revocationStatus)
+				failed = report(fmt.Sprintf("%s revocation", t), resp, err) || failed
+			default:
+				log.Fatalf("unknown message %q", m)
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// report logs the outcome of a simulated message and reports whether it failed.
+func report(what string, resp *http.Response, err error) bool {
+	if err != nil {
+		log.Printf("FAIL %s: %s", what, err)
+		return true
+	}
+	if resp == nil {
+		log.Printf("ok   %s", what)
+		return false
+	}
+	if resp.StatusCode >= 300 {
+		log.Printf("FAIL %s: %s", what, resp.Status)
+		return true
+	}
+	log.Printf("ok   %s: %s", what, resp.Status)
+	return false
+}
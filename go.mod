@@ -12,14 +12,16 @@ require (
 	github.com/tklauser/go-sysconf v0.3.12 // indirect
 	github.com/tklauser/numcpus v0.6.1 // indirect
 	github.com/yusufpapurcu/wmi v1.2.3 // indirect
+	golang.org/x/net v0.11.0 // indirect
+	golang.org/x/text v0.10.0 // indirect
 )
 
 require (
 	github.com/gorilla/websocket v1.5.0 // indirect
 	github.com/klauspost/compress v1.10.3 // indirect
 	github.com/shirou/gopsutil v3.21.11+incompatible
-	github.com/spddl/go-twitch-ws v0.0.0-20210519195157-c49c94366ced // indirect
-	golang.org/x/crypto v0.0.0-20210421170649-83a5a9bb288b // indirect
+	github.com/spddl/go-twitch-ws v0.0.0-20210519195157-c49c94366ced
+	golang.org/x/crypto v0.10.0
 	golang.org/x/sys v0.11.0 // indirect
 	nhooyr.io/websocket v1.8.7 // indirect
 )
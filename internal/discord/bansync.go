@@ -0,0 +1,284 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// banSyncBucket holds the open ban offers by platform and user ID.
+	banSyncBucket    = "ban_sync_offers"
+	banSyncComponent = "ban-sync"
+	// banSyncEcho is how long the event of a ban made by the sync is expected to arrive.
+	banSyncEcho = 10 * time.Minute
+	// banSyncOfferExpiry is how long an offer can be confirmed, later bans are left to be made by hand.
+	banSyncOfferExpiry = 24 * time.Hour
+	// banReasonLength is the longest ban reason Twitch and the Discord audit log accept.
+	banReasonLength = 500
+)
+
+// banSyncExpired is the reply to clicks on offers older than banSyncOfferExpiry.
+const banSyncExpired = "This ban offer expired, ban them by hand if it is still needed."
+
+// Platforms a ban is synced to.
+const (
+	banOnTwitch  = "twitch"
+	banOnDiscord = "discord"
+)
+
+// banSyncOffer is an offer in the admin channels to ban a user on the other platform.
+type banSyncOffer struct {
+	// Name is the name of the user to ban on the platform they are banned on.
+	Name      string    `json:"name"`
+	Reason    string    `json:"reason"`
+	OfferedAt time.Time `json:"offered_at"`
+}
+
+// BanSync offers to ban the linked Twitch account of members banned in Discord, and the linked
+// Discord account of users banned on Twitch. Offers are posted with buttons to the admin channels and
+// nothing is banned until an admin confirms.
+type BanSync struct {
+	client    *Client
+	store     *store.Store
+	banTwitch func(userID, reason string) error
+
+	// mu guards synced and claiming offers.
+	mu sync.Mutex
+	// synced are when the sync banned each user by platform and user ID, so the events of its own
+	// bans are not offered back.
+	synced map[string]time.Time
+}
+
+// NewBanSync creates a BanSync that listens for Discord bans. banTwitch bans a Twitch user ID.
+func NewBanSync(client *Client, s *store.Store, banTwitch func(userID, reason string) error) *BanSync {
+	b := &BanSync{
+		client:    client,
+		store:     s,
+		banTwitch: banTwitch,
+		synced:    make(map[string]time.Time),
+	}
+	client.addHandler(b.guildBanAdd)
+	client.AddComponent(Component{Prefix: banSyncComponent, AdminOnly: true, Handler: b.handleButton})
+	return b
+}
+
+func (b *BanSync) guildBanAdd(s *discordgo.Session, e *discordgo.GuildBanAdd) {
+	if e.GuildID == b.client.guildID && e.User != nil {
+		b.DiscordBan(e.User)
+	}
+}
+
+// DiscordBan offers to ban the Twitch account linked to user after they were banned in Discord.
+func (b *BanSync) DiscordBan(user *discordgo.User) {
+	if b.wasSynced(banOnDiscord, user.ID) {
+		return
+	}
+	twitchUserID, account, found := linkedTwitchAccount(b.store, user.ID)
+	if !found {
+		return
+	}
+	b.offer(banOnTwitch, twitchUserID, banSyncOffer{
+		Name:      account.TwitchLogin,
+		Reason:    fmt.Sprintf("Banned in Discord as %s", user.Username),
+		OfferedAt: time.Now(),
+	}, fmt.Sprintf("**%s** was banned in Discord, their linked Twitch account is **%s**.", escapeMarkdown(user.Username), escapeMarkdown(account.TwitchLogin)))
+}
+
+// TwitchBan offers to ban the Discord account linked to the user of ban after they were banned on
+// Twitch. Timeouts are ignored.
+func (b *BanSync) TwitchBan(ban twitchws.BanEvent) {
+	if !ban.IsPermanent || b.wasSynced(banOnTwitch, ban.UserID) {
+		return
+	}
+	discordUserID, found, err := linkedDiscordUser(b.store, ban.UserID)
+	if err != nil {
+		log.Printf("error looking up linked account of banned user %s: %s", ban.UserID, err)
+		return
+	}
+	if !found {
+		return
+	}
+	reason := "Banned on Twitch"
+	if ban.Reason != "" {
+		reason += ": " + ban.Reason
+	}
+	b.offer(banOnDiscord, discordUserID, banSyncOffer{
+		Name:      ban.UserLogin,
+		Reason:    reason,
+		OfferedAt: time.Now(),
+	}, fmt.Sprintf("**%s** was banned on Twitch by %s, their linked Discord account is <@%s>.", escapeMarkdown(ban.UserLogin), escapeMarkdown(ban.ModeratorUserLogin), discordUserID))
+}
+
+func (b *BanSync) offer(platform, userID string, offer banSyncOffer, description string) {
+	b.expireOffers(offer.OfferedAt)
+	if err := b.store.Put(banSyncBucket, banSyncKey(platform, userID), offer); err != nil {
+		log.Printf("error storing ban offer for %s: %s", userID, err)
+		return
+	}
+	title, button := "Ban on Twitch?", "Ban on Twitch"
+	if platform == banOnDiscord {
+		title, button = "Ban in Discord?", "Ban in Discord"
+	}
+	message := &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title:       title,
+			Description: description,
+			Color:       0xed4245,
+			Fields: []*discordgo.MessageEmbedField{
+				{Name: "Reason", Value: truncate(escapeMarkdown(sanitizeMentions(offer.Reason)), 1024)},
+			},
+			Timestamp: offer.OfferedAt.Format(time.RFC3339),
+		}},
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: button, Style: discordgo.DangerButton, CustomID: banSyncCustomID("ban", platform, userID)},
+				discordgo.Button{Label: "Dismiss", Style: discordgo.SecondaryButton, CustomID: banSyncCustomID("dismiss", platform, userID)},
+			}},
+		},
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	}
+	for _, channelID := range b.client.adminChannelIDs {
+		if _, err := b.client.transport.SendComplex(channelID, message); err != nil {
+			log.Printf("error sending ban offer to %s: %s", channelID, err)
+		}
+	}
+}
+
+// handleButton bans or dismisses the offer of a clicked button. The offer is posted to every admin
+// channel, so it may have been handled from another one already.
+func (b *BanSync) handleButton(i *discordgo.InteractionCreate) (string, error) {
+	parts := strings.SplitN(i.MessageComponentData().CustomID, ":", 4)
+	if len(parts) != 4 {
+		return "", fmt.Errorf("invalid ban button %q", i.MessageComponentData().CustomID)
+	}
+	action, platform, userID := parts[1], parts[2], parts[3]
+	// The user may have been offered again since, the newer offer is left to its own message.
+	if offeredAt, ok := offerMessageTime(i); ok && time.Since(offeredAt) >= banSyncOfferExpiry {
+		return banSyncExpired, nil
+	}
+	// The offer is claimed before acting on it, so two admins clicking at once do not both act.
+	offer, found, err := b.claimOffer(platform, userID)
+	if err != nil {
+		return "", fmt.Errorf("could not read the ban offer: %w", err)
+	}
+	if !found {
+		return "This ban was already handled.", nil
+	}
+	if time.Since(offer.OfferedAt) >= banSyncOfferExpiry {
+		return banSyncExpired, nil
+	}
+	admin := interactionUserID(i)
+	where := "on Twitch"
+	if platform == banOnDiscord {
+		where = "in Discord"
+	}
+	if action == "dismiss" {
+		return fmt.Sprintf("Not banned %s, dismissed by <@%s>.", where, admin), nil
+	}
+	reason := truncate(fmt.Sprintf("%s, confirmed by %s", offer.Reason, interactionUserName(i)), banReasonLength)
+	b.markSynced(platform, userID)
+	if platform == banOnDiscord {
+		err = b.client.transport.BanMember(b.client.guildID, userID, reason)
+	} else {
+		err = b.banTwitch(userID, reason)
+	}
+	if err != nil {
+		// Forget the ban so a later ban by hand is offered, and offer it again so it can be retried.
+		b.forgetSynced(platform, userID)
+		if putErr := b.store.Put(banSyncBucket, banSyncKey(platform, userID), offer); putErr != nil {
+			log.Printf("error restoring ban offer for %s: %s", userID, putErr)
+		}
+		return "", fmt.Errorf("could not ban %s %s: %w", offer.Name, where, err)
+	}
+	return fmt.Sprintf("🔨 Banned **%s** %s, confirmed by <@%s>.", escapeMarkdown(offer.Name), where, admin), nil
+}
+
+// claimOffer removes the offer to ban userID on platform and returns it. found is false if it was
+// already claimed.
+func (b *BanSync) claimOffer(platform, userID string) (offer banSyncOffer, found bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := banSyncKey(platform, userID)
+	found, err = b.store.Get(banSyncBucket, key, &offer)
+	if err != nil || !found {
+		return offer, false, err
+	}
+	if err := b.store.Delete(banSyncBucket, key); err != nil {
+		return offer, false, err
+	}
+	return offer, true, nil
+}
+
+// expireOffers forgets the offers that can no longer be confirmed at now.
+func (b *BanSync) expireOffers(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, key := range b.store.Keys(banSyncBucket) {
+		var offer banSyncOffer
+		if _, err := b.store.Get(banSyncBucket, key, &offer); err != nil || now.Sub(offer.OfferedAt) < banSyncOfferExpiry {
+			continue
+		}
+		if err := b.store.Delete(banSyncBucket, key); err != nil {
+			log.Printf("error expiring ban offer %s: %s", key, err)
+		}
+	}
+}
+
+// offerMessageTime returns when the offer whose button was clicked in i was made, from the timestamp
+// of its embed.
+func offerMessageTime(i *discordgo.InteractionCreate) (time.Time, bool) {
+	if i.Message == nil || len(i.Message.Embeds) == 0 {
+		return time.Time{}, false
+	}
+	offeredAt, err := time.Parse(time.RFC3339, i.Message.Embeds[0].Timestamp)
+	return offeredAt, err == nil
+}
+
+func (b *BanSync) markSynced(platform, userID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.synced[banSyncKey(platform, userID)] = time.Now()
+}
+
+// wasSynced reports whether the sync banned userID on platform recently and forgets the ban.
+func (b *BanSync) wasSynced(platform, userID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := banSyncKey(platform, userID)
+	at, ok := b.synced[key]
+	delete(b.synced, key)
+	return ok && time.Since(at) < banSyncEcho
+}
+
+// forgetSynced forgets that the sync banned userID on platform.
+func (b *BanSync) forgetSynced(platform, userID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.synced, banSyncKey(platform, userID))
+}
+
+func banSyncKey(platform, userID string) string {
+	return platform + ":" + userID
+}
+
+func banSyncCustomID(action, platform, userID string) string {
+	return strings.Join([]string{banSyncComponent, action, platform, userID}, ":")
+}
+
+// interactionUserName returns the username of the user who invoked i.
+func interactionUserName(i *discordgo.InteractionCreate) string {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User.Username
+	}
+	if i.User != nil {
+		return i.User.Username
+	}
+	return "unknown"
+}
@@ -0,0 +1,146 @@
+package discord
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+	"github.com/bwmarrin/discordgo"
+)
+
+func buttonClick(customID string) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type:      discordgo.InteractionMessageComponent,
+		ChannelID: testAdminChannelID,
+		Data:      discordgo.MessageComponentInteractionData{CustomID: customID},
+		Member:    &discordgo.Member{User: &discordgo.User{ID: "admin-1", Username: "admin"}},
+	}}
+}
+
+func TestBanSyncBansLinkedDiscordAccount(t *testing.T) {
+	client, recorder, s := newTestClient(t)
+	if err := LinkAccount(s, "discord-1", "twitch-1", "viewer"); err != nil {
+		t.Fatal(err)
+	}
+	b := NewBanSync(client, s, nil)
+	b.TwitchBan(twitchws.BanEvent{UserID: "twitch-1", UserLogin: "viewer", ModeratorUserLogin: "mod", Reason: "spam", IsPermanent: true})
+	offers := recorder.Messages(testAdminChannelID)
+	if len(offers) != 1 || len(offers[0].Components) != 1 {
+		t.Fatalf("posted %d offers, want 1 with buttons", len(offers))
+	}
+
+	content, err := b.handleButton(buttonClick(banSyncCustomID("ban", banOnDiscord, "discord-1")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(content, "Banned") {
+		t.Fatalf("button replied %q, want a ban confirmation", content)
+	}
+	if reason, ok := recorder.Bans(testGuildID)["discord-1"]; !ok || !strings.Contains(reason, "spam") {
+		t.Fatalf("bans are %v, want discord-1 banned for spam", recorder.Bans(testGuildID))
+	}
+	// The offer was posted to every admin channel, a second click finds it handled.
+	content, err = b.handleButton(buttonClick(banSyncCustomID("ban", banOnDiscord, "discord-1")))
+	if err != nil || content != "This ban was already handled." {
+		t.Fatalf("second click replied %q, %v, want already handled", content, err)
+	}
+	// The ban made by the sync is not offered back.
+	b.DiscordBan(&discordgo.User{ID: "discord-1", Username: "viewer"})
+	if got := len(recorder.Messages(testAdminChannelID)); got != 1 {
+		t.Fatalf("posted %d offers, want the sync's own ban ignored", got)
+	}
+}
+
+func TestBanSyncKeepsOfferWhenBanFails(t *testing.T) {
+	client, recorder, s := newTestClient(t)
+	if err := LinkAccount(s, "discord-1", "twitch-1", "viewer"); err != nil {
+		t.Fatal(err)
+	}
+	banErr := errors.New("missing scope")
+	var banned []string
+	b := NewBanSync(client, s, func(userID, reason string) error {
+		banned = append(banned, userID)
+		return banErr
+	})
+	b.DiscordBan(&discordgo.User{ID: "discord-1", Username: "viewer"})
+	click := buttonClick(banSyncCustomID("ban", banOnTwitch, "twitch-1"))
+
+	if _, err := b.handleButton(click); !errors.Is(err, banErr) {
+		t.Fatalf("button returned %v, want the ban error", err)
+	}
+	if b.wasSynced(banOnTwitch, "twitch-1") {
+		t.Fatal("the failed ban is still remembered as synced")
+	}
+	banErr = nil
+	if _, err := b.handleButton(click); err != nil {
+		t.Fatalf("retrying the offer returned %v", err)
+	}
+	if len(banned) != 2 {
+		t.Fatalf("banned %v, want twitch-1 twice", banned)
+	}
+	if got := len(recorder.Messages(testAdminChannelID)); got != 1 {
+		t.Fatalf("posted %d offers, want 1", got)
+	}
+}
+
+func TestBanSyncDismiss(t *testing.T) {
+	client, recorder, s := newTestClient(t)
+	if err := LinkAccount(s, "discord-1", "twitch-1", "viewer"); err != nil {
+		t.Fatal(err)
+	}
+	b := NewBanSync(client, s, nil)
+	b.TwitchBan(twitchws.BanEvent{UserID: "twitch-1", UserLogin: "viewer", IsPermanent: true})
+	content, err := b.handleButton(buttonClick(banSyncCustomID("dismiss", banOnDiscord, "discord-1")))
+	if err != nil || !strings.Contains(content, "dismissed") {
+		t.Fatalf("dismiss replied %q, %v", content, err)
+	}
+	if bans := recorder.Bans(testGuildID); len(bans) != 0 {
+		t.Fatalf("bans are %v after dismissing, want none", bans)
+	}
+	if content, _ := b.handleButton(buttonClick(banSyncCustomID("ban", banOnDiscord, "discord-1"))); content != "This ban was already handled." {
+		t.Fatalf("ban after dismissing replied %q, want already handled", content)
+	}
+}
+
+func TestBanSyncOffersExpire(t *testing.T) {
+	client, recorder, s := newTestClient(t)
+	if err := LinkAccount(s, "discord-1", "twitch-1", "viewer"); err != nil {
+		t.Fatal(err)
+	}
+	if err := LinkAccount(s, "discord-2", "twitch-2", "other"); err != nil {
+		t.Fatal(err)
+	}
+	b := NewBanSync(client, s, nil)
+	expired := time.Now().Add(-banSyncOfferExpiry)
+	if err := s.Put(banSyncBucket, banSyncKey(banOnDiscord, "discord-1"), banSyncOffer{Name: "viewer", OfferedAt: expired}); err != nil {
+		t.Fatal(err)
+	}
+	content, err := b.handleButton(buttonClick(banSyncCustomID("ban", banOnDiscord, "discord-1")))
+	if err != nil || content != banSyncExpired {
+		t.Fatalf("click on an expired offer replied %q, %v, want expired", content, err)
+	}
+	if _, banned := recorder.Bans(testGuildID)["discord-1"]; banned {
+		t.Fatal("the expired offer was banned")
+	}
+
+	// A new offer expires the old ones.
+	if err := s.Put(banSyncBucket, banSyncKey(banOnDiscord, "discord-1"), banSyncOffer{Name: "viewer", OfferedAt: expired}); err != nil {
+		t.Fatal(err)
+	}
+	b.TwitchBan(twitchws.BanEvent{UserID: "twitch-2", UserLogin: "other", IsPermanent: true})
+	if keys := s.Keys(banSyncBucket); len(keys) != 1 || keys[0] != banSyncKey(banOnDiscord, "discord-2") {
+		t.Fatalf("open offers are %v, want only the new one", keys)
+	}
+
+	// A click on an old message does not claim a newer offer for the same user.
+	click := buttonClick(banSyncCustomID("ban", banOnDiscord, "discord-2"))
+	click.Message = &discordgo.Message{Embeds: []*discordgo.MessageEmbed{{Timestamp: expired.Format(time.RFC3339)}}}
+	if content, err := b.handleButton(click); err != nil || content != banSyncExpired {
+		t.Fatalf("click on an expired message replied %q, %v, want expired", content, err)
+	}
+	if keys := s.Keys(banSyncBucket); len(keys) != 1 {
+		t.Fatal("the click on the expired message claimed the newer offer")
+	}
+}
@@ -0,0 +1,272 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	celebrationsBucket        = "celebrations"
+	followerMilestoneKey      = "follower_milestone"
+	defaultCelebrationBatch   = 5 * time.Minute
+	celebrationColor          = 0xffc83d
+	maxCelebrationNamesListed = 20
+)
+
+type CelebrationConfig struct {
+	// ChannelID is where celebrations are posted.
+	ChannelID string
+	// BatchWindow is how long follows and new subscriptions are collected before they are posted
+	// together, 5 minutes if it is 0.
+	BatchWindow time.Duration
+	// Follows, Subscriptions, Resubscriptions, Gifts and Cheers enable each kind of celebration.
+	Follows         bool
+	Subscriptions   bool
+	Resubscriptions bool
+	Gifts           bool
+	Cheers          bool
+	// FollowerMilestones are follower counts that are announced when they are reached.
+	FollowerMilestones []int
+}
+
+// CelebrationFeed posts follows, subscriptions, gifts and cheers to a celebrations channel.
+type CelebrationFeed struct {
+	client        *Client
+	store         *store.Store
+	config        CelebrationConfig
+	followerCount func() (int, error)
+
+	mu            sync.Mutex
+	follows       []string
+	subscriptions []string
+}
+
+// NewCelebrationFeed creates a CelebrationFeed. followerCount returns the current follower count for
+// milestones.
+func NewCelebrationFeed(client *Client, s *store.Store, followerCount func() (int, error), config CelebrationConfig) *CelebrationFeed {
+	if config.BatchWindow == 0 {
+		config.BatchWindow = defaultCelebrationBatch
+	}
+	sort.Ints(config.FollowerMilestones)
+	return &CelebrationFeed{
+		client:        client,
+		store:         s,
+		config:        config,
+		followerCount: followerCount,
+	}
+}
+
+// Run posts the batched celebrations every BatchWindow until stop is closed.
+func (f *CelebrationFeed) Run(stop chan struct{}) {
+	ticker := time.NewTicker(f.config.BatchWindow)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			f.flush()
+			return
+		case <-ticker.C:
+			f.flush()
+		}
+	}
+}
+
+// Handle celebrates notification if it is of an enabled kind.
+func (f *CelebrationFeed) Handle(notification twitchws.Notification) {
+	var err error
+	switch notification.Subscription.Type {
+	case twitchws.EventTypeChannelFollow:
+		var follow twitchws.FollowEvent
+		if err = notification.DecodeEvent(&follow); err == nil && f.config.Follows {
+			f.mu.Lock()
+			f.follows = append(f.follows, mentionLinked(f.store, follow.UserID, follow.UserName))
+			f.mu.Unlock()
+		}
+	case twitchws.EventTypeChannelSubscribe:
+		var subscribe twitchws.SubscribeEvent
+		// Gifted subscriptions are celebrated once for the gifter instead of once per recipient.
+		if err = notification.DecodeEvent(&subscribe); err == nil && f.config.Subscriptions && !subscribe.IsGift {
+			f.mu.Lock()
+			f.subscriptions = append(f.subscriptions, fmt.Sprintf("%s (%s)", mentionLinked(f.store, subscribe.UserID, subscribe.UserName), tierName(subscribe.Tier)))
+			f.mu.Unlock()
+		}
+	case twitchws.EventTypeChannelSubscriptionMessage:
+		var resubscribe twitchws.SubscriptionMessageEvent
+		if err = notification.DecodeEvent(&resubscribe); err == nil && f.config.Resubscriptions {
+			f.send(resubscriptionEmbed(resubscribe, mentionLinked(f.store, resubscribe.UserID, resubscribe.UserName)))
+		}
+	case twitchws.EventTypeChannelSubscriptionGift:
+		var gift twitchws.SubscriptionGiftEvent
+		if err = notification.DecodeEvent(&gift); err == nil && f.config.Gifts {
+			f.send(giftEmbed(gift, mentionLinked(f.store, gift.UserID, gift.UserName)))
+		}
+	case twitchws.EventTypeChannelCheer:
+		var cheer twitchws.CheerEvent
+		if err = notification.DecodeEvent(&cheer); err == nil && f.config.Cheers {
+			f.send(cheerEmbed(cheer, mentionLinked(f.store, cheer.UserID, cheer.UserName)))
+		}
+	}
+	if err != nil {
+		log.Printf("error celebrating %s: %s", notification.Subscription.Type, err)
+	}
+}
+
+// flush posts the follows and subscriptions collected since the last flush.
+func (f *CelebrationFeed) flush() {
+	f.mu.Lock()
+	follows, subscriptions := f.follows, f.subscriptions
+	f.follows, f.subscriptions = nil, nil
+	f.mu.Unlock()
+	window := f.config.BatchWindow.Round(time.Minute)
+	if len(follows) > 0 {
+		f.send(&discordgo.MessageEmbed{
+			Title:       batchTitle(len(follows), "new follower", window),
+			Description: nameList(follows),
+			Color:       celebrationColor,
+		})
+		f.checkFollowerMilestone()
+	}
+	if len(subscriptions) > 0 {
+		f.send(&discordgo.MessageEmbed{
+			Title:       batchTitle(len(subscriptions), "new subscriber", window),
+			Description: nameList(subscriptions),
+			Color:       celebrationColor,
+		})
+	}
+}
+
+// checkFollowerMilestone announces the highest milestone reached since the last one announced. The
+// first check only records the current milestone, so milestones reached before are not announced.
+func (f *CelebrationFeed) checkFollowerMilestone() {
+	if len(f.config.FollowerMilestones) == 0 || f.followerCount == nil {
+		return
+	}
+	count, err := f.followerCount()
+	if err != nil {
+		log.Printf("error getting follower count for milestones: %s", err)
+		return
+	}
+	reached := 0
+	for _, milestone := range f.config.FollowerMilestones {
+		if count >= milestone {
+			reached = milestone
+		}
+	}
+	var announced int
+	found, err := f.store.Get(celebrationsBucket, followerMilestoneKey, &announced)
+	if err != nil {
+		log.Printf("error reading follower milestone: %s", err)
+		return
+	}
+	if found && reached <= announced {
+		return
+	}
+	if found {
+		f.send(&discordgo.MessageEmbed{
+			Title:       fmt.Sprintf("🏆 %d followers!", reached),
+			URL:         twitchChannelURL,
+			Description: fmt.Sprintf("SensaiOpti just passed %d followers, thank you all!", reached),
+			Color:       celebrationColor,
+		})
+	}
+	if err := f.store.Put(celebrationsBucket, followerMilestoneKey, reached); err != nil {
+		log.Printf("error recording follower milestone: %s", err)
+	}
+}
+
+func (f *CelebrationFeed) send(embed *discordgo.MessageEmbed) {
+	if _, err := f.client.transport.SendEmbed(f.config.ChannelID, embed); err != nil {
+		log.Printf("error sending celebration to %s: %s", f.config.ChannelID, err)
+	}
+}
+
+// resubscriptionEmbed, giftEmbed and cheerEmbed celebrate the viewer shown as name, which is escaped.
+func resubscriptionEmbed(resubscribe twitchws.SubscriptionMessageEvent, name string) *discordgo.MessageEmbed {
+	description := fmt.Sprintf("**%s** resubscribed at %s for **%d months**", name, tierName(resubscribe.Tier), resubscribe.CumulativeMonths)
+	if resubscribe.StreakMonths != nil && *resubscribe.StreakMonths > 1 {
+		description += fmt.Sprintf(", %d in a row", *resubscribe.StreakMonths)
+	}
+	description += "!"
+	if text := strings.TrimSpace(resubscribe.Message.Text); text != "" {
+		description += fmt.Sprintf("\n> %s", escapeMarkdown(text))
+	}
+	return &discordgo.MessageEmbed{
+		Title:       "🎉 Resubscription",
+		Description: truncate(description, 4096),
+		Color:       celebrationColor,
+	}
+}
+
+func giftEmbed(gift twitchws.SubscriptionGiftEvent, name string) *discordgo.MessageEmbed {
+	gifter := "An anonymous gifter"
+	if !gift.IsAnonymous && gift.UserName != "" {
+		gifter = fmt.Sprintf("**%s**", name)
+	}
+	subs := "sub"
+	if gift.Total != 1 {
+		subs = "subs"
+	}
+	description := fmt.Sprintf("%s gifted %d %s %s!", gifter, gift.Total, tierName(gift.Tier), subs)
+	if gift.CumulativeTotal != nil {
+		description += fmt.Sprintf(" That's %d gifted in total.", *gift.CumulativeTotal)
+	}
+	return &discordgo.MessageEmbed{
+		Title:       "🎁 Gifted subs",
+		Description: description,
+		Color:       celebrationColor,
+	}
+}
+
+func cheerEmbed(cheer twitchws.CheerEvent, name string) *discordgo.MessageEmbed {
+	cheerer := "An anonymous cheerer"
+	if !cheer.IsAnonymous && cheer.UserName != "" {
+		cheerer = fmt.Sprintf("**%s**", name)
+	}
+	description := fmt.Sprintf("%s cheered **%d bits**!", cheerer, cheer.Bits)
+	if text := strings.TrimSpace(cheer.Message); text != "" {
+		description += fmt.Sprintf("\n> %s", escapeMarkdown(text))
+	}
+	return &discordgo.MessageEmbed{
+		Title:       "💎 Cheer",
+		Description: truncate(description, 4096),
+		Color:       celebrationColor,
+	}
+}
+
+// batchTitle describes count celebrations of kind, e.g. "12 new followers in the last 5 minutes".
+func batchTitle(count int, kind string, window time.Duration) string {
+	if count == 1 {
+		return fmt.Sprintf("🎉 1 %s", kind)
+	}
+	minutes := int(window.Minutes())
+	if minutes <= 1 {
+		return fmt.Sprintf("🎉 %d %ss in the last minute", count, kind)
+	}
+	return fmt.Sprintf("🎉 %d %ss in the last %d minutes", count, kind, minutes)
+}
+
+// nameList lists names, which are escaped, cut off after maxCelebrationNamesListed.
+func nameList(names []string) string {
+	if len(names) > maxCelebrationNamesListed {
+		names = append(names[:maxCelebrationNamesListed:maxCelebrationNamesListed], fmt.Sprintf("and %d more", len(names)-maxCelebrationNamesListed))
+	}
+	return strings.Join(names, ", ")
+}
+
+func tierName(tier string) string {
+	switch tier {
+	case "2000":
+		return "Tier 2"
+	case "3000":
+		return "Tier 3"
+	}
+	return "Tier 1"
+}
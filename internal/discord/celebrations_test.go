@@ -0,0 +1,115 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+)
+
+const testCelebrationsChannelID = "celebrations"
+
+func celebrationNotification(eventType, event string) twitchws.Notification {
+	return twitchws.Notification{
+		Subscription: twitchws.Subscription{Type: eventType},
+		RawEvent:     []byte(event),
+	}
+}
+
+func TestCelebrationsBatchFollowsAndSubscriptions(t *testing.T) {
+	client, recorder, s := newTestClient(t)
+	f := NewCelebrationFeed(client, s, nil, CelebrationConfig{
+		ChannelID:     testCelebrationsChannelID,
+		Follows:       true,
+		Subscriptions: true,
+	})
+	for i := 0; i < 3; i++ {
+		f.Handle(celebrationNotification(twitchws.EventTypeChannelFollow, fmt.Sprintf(`{"user_id":"%d","user_name":"follower_%d"}`, i, i)))
+	}
+	f.Handle(celebrationNotification(twitchws.EventTypeChannelSubscribe, `{"user_id":"10","user_name":"subscriber","tier":"2000"}`))
+	f.Handle(celebrationNotification(twitchws.EventTypeChannelSubscribe, `{"user_id":"11","user_name":"gifted","tier":"1000","is_gift":true}`))
+	if got := recorder.Messages(testCelebrationsChannelID); len(got) != 0 {
+		t.Fatalf("%d celebrations were posted before the batch window ended", len(got))
+	}
+
+	f.flush()
+	messages := recorder.Messages(testCelebrationsChannelID)
+	if len(messages) != 2 {
+		t.Fatalf("%d celebrations were posted, want one for follows and one for subscriptions", len(messages))
+	}
+	follows, subscriptions := messages[0].Embeds[0], messages[1].Embeds[0]
+	if follows.Title != "🎉 3 new followers in the last 5 minutes" || follows.Description != `follower\_0, follower\_1, follower\_2` {
+		t.Fatalf("follows were posted as %q: %q", follows.Title, follows.Description)
+	}
+	if subscriptions.Title != "🎉 1 new subscriber" || subscriptions.Description != "subscriber (Tier 2)" {
+		t.Fatalf("subscriptions were posted as %q: %q", subscriptions.Title, subscriptions.Description)
+	}
+
+	f.flush()
+	if got := recorder.Messages(testCelebrationsChannelID); len(got) != 2 {
+		t.Fatalf("an empty batch posted %d more celebrations", len(got)-2)
+	}
+}
+
+func TestCelebrationsSkipDisabledKinds(t *testing.T) {
+	client, recorder, s := newTestClient(t)
+	f := NewCelebrationFeed(client, s, nil, CelebrationConfig{ChannelID: testCelebrationsChannelID, Cheers: true})
+	f.Handle(celebrationNotification(twitchws.EventTypeChannelFollow, `{"user_id":"1","user_name":"follower"}`))
+	f.Handle(celebrationNotification(twitchws.EventTypeChannelCheer, `{"user_id":"2","user_name":"cheerer","bits":100,"message":"**hype**"}`))
+	f.flush()
+	messages := recorder.Messages(testCelebrationsChannelID)
+	if len(messages) != 1 || !strings.Contains(messages[0].Embeds[0].Description, `\*\*hype\*\*`) {
+		t.Fatalf("posted %v, want only the cheer with its message escaped", messages)
+	}
+}
+
+func TestNameListIsCutOff(t *testing.T) {
+	names := make([]string, maxCelebrationNamesListed+5)
+	for i := range names {
+		names[i] = fmt.Sprint(i)
+	}
+	if got := nameList(names); !strings.HasSuffix(got, ", 19, and 5 more") {
+		t.Fatalf("nameList returned %q", got)
+	}
+}
+
+func TestFollowerMilestones(t *testing.T) {
+	client, recorder, s := newTestClient(t)
+	count := 90
+	f := NewCelebrationFeed(client, s, func() (int, error) { return count, nil }, CelebrationConfig{
+		ChannelID:          testCelebrationsChannelID,
+		BatchWindow:        time.Minute,
+		FollowerMilestones: []int{500, 100, 50},
+	})
+	milestones := func() []string {
+		var titles []string
+		for _, m := range recorder.Messages(testCelebrationsChannelID) {
+			if strings.HasPrefix(m.Embeds[0].Title, "🏆") {
+				titles = append(titles, m.Embeds[0].Title)
+			}
+		}
+		return titles
+	}
+
+	// The first check only records the milestone already reached.
+	f.checkFollowerMilestone()
+	if got := milestones(); len(got) != 0 {
+		t.Fatalf("the first check announced %v", got)
+	}
+	count = 99
+	f.checkFollowerMilestone()
+	if got := milestones(); len(got) != 0 {
+		t.Fatalf("announced %v before the next milestone was reached", got)
+	}
+	count = 600
+	f.checkFollowerMilestone()
+	if got := milestones(); len(got) != 1 || got[0] != "🏆 500 followers!" {
+		t.Fatalf("announced %v, want only the highest milestone reached", got)
+	}
+	f.checkFollowerMilestone()
+	if got := milestones(); len(got) != 1 {
+		t.Fatalf("announced %v, want the milestone announced once", got)
+	}
+}
@@ -0,0 +1,198 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	clipCursorsBucket = "clip_cursors"
+	postedClipsBucket = "posted_clips"
+	// clipOverlap is how far before the cursor clips are fetched again, Twitch lists clips a while
+	// after they were created.
+	clipOverlap = time.Hour
+	// postedClipRetention is how long posted clips are remembered, longer than clipOverlap.
+	postedClipRetention = 24 * time.Hour
+	// firstClipWindow is how far back clips are fetched for a broadcaster without a cursor.
+	firstClipWindow     = time.Hour
+	defaultClipInterval = 5 * time.Minute
+	clipCommandCooldown = 30 * time.Second
+)
+
+type ClipConfig struct {
+	// ChannelID is where new clips are posted.
+	ChannelID string
+	// BroadcasterIDs are the Twitch users whose clips are posted.
+	BroadcasterIDs []string
+	// Interval is how often clips are checked, every 5 minutes if it is 0.
+	Interval time.Duration
+}
+
+// ClipPoller posts new Twitch clips to a Discord channel.
+type ClipPoller struct {
+	client *Client
+	store  *store.Store
+	config ClipConfig
+}
+
+func NewClipPoller(client *Client, s *store.Store, config ClipConfig) *ClipPoller {
+	if config.Interval == 0 {
+		config.Interval = defaultClipInterval
+	}
+	return &ClipPoller{client: client, store: s, config: config}
+}
+
+// Run checks for new clips every Interval until stop is closed.
+func (p *ClipPoller) Run(stop chan struct{}) {
+	p.Poll()
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.Poll()
+		}
+	}
+}
+
+// Poll posts the clips created since the last poll.
+func (p *ClipPoller) Poll() {
+	now := time.Now()
+	for _, broadcasterID := range p.config.BroadcasterIDs {
+		if err := p.poll(broadcasterID, now); err != nil {
+			log.Printf("error polling clips of %s: %s", broadcasterID, err)
+		}
+	}
+	p.forgetPostedClips(now)
+}
+
+func (p *ClipPoller) poll(broadcasterID string, now time.Time) error {
+	var cursor time.Time
+	found, err := p.store.Get(clipCursorsBucket, broadcasterID, &cursor)
+	if err != nil {
+		return err
+	}
+	if !found {
+		cursor = now.Add(-firstClipWindow)
+	}
+	clips, err := twitchws.GetClips(broadcasterID, cursor.Add(-clipOverlap), now)
+	if err != nil {
+		return err
+	}
+	var newClips []twitchws.Clip
+	gameIDs := make(map[string]bool)
+	for _, clip := range clips {
+		if posted, err := p.store.Get(postedClipsBucket, clip.ID, new(time.Time)); err != nil || posted {
+			continue
+		}
+		newClips = append(newClips, clip)
+		if clip.GameID != "" {
+			gameIDs[clip.GameID] = true
+		}
+	}
+	games := make(map[string]string)
+	if len(gameIDs) > 0 {
+		ids := make([]string, 0, len(gameIDs))
+		for id := range gameIDs {
+			ids = append(ids, id)
+		}
+		if games, err = twitchws.GetGameNames(ids...); err != nil {
+			log.Printf("error getting clip games, posting clips without them: %s", err)
+		}
+	}
+	// Twitch sorts clips by views, post them oldest first so the channel reads in order.
+	sort.Slice(newClips, func(i, j int) bool {
+		return newClips[i].CreatedAt.Before(newClips[j].CreatedAt)
+	})
+	for _, clip := range newClips {
+		if _, err := p.client.transport.SendEmbed(p.config.ChannelID, clipEmbed(clip, games[clip.GameID])); err != nil {
+			return fmt.Errorf("error posting clip %s: %w", clip.ID, err)
+		}
+		if err := p.store.Put(postedClipsBucket, clip.ID, clip.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return p.store.Put(clipCursorsBucket, broadcasterID, now)
+}
+
+func (p *ClipPoller) forgetPostedClips(now time.Time) {
+	for _, clipID := range p.store.Keys(postedClipsBucket) {
+		var createdAt time.Time
+		if _, err := p.store.Get(postedClipsBucket, clipID, &createdAt); err != nil || now.Sub(createdAt) < postedClipRetention {
+			continue
+		}
+		if err := p.store.Delete(postedClipsBucket, clipID); err != nil {
+			log.Printf("error forgetting posted clip %s: %s", clipID, err)
+		}
+	}
+}
+
+func clipEmbed(clip twitchws.Clip, gameName string) *discordgo.MessageEmbed {
+	fields := []*discordgo.MessageEmbedField{
+		{Name: "Clipped by", Value: escapeMarkdown(clip.CreatorName), Inline: true},
+		{Name: "Views", Value: fmt.Sprint(clip.ViewCount), Inline: true},
+	}
+	if gameName != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Game", Value: gameName, Inline: true})
+	}
+	return &discordgo.MessageEmbed{
+		Title:     truncate(clip.Title, 256),
+		URL:       clip.URL,
+		Author:    &discordgo.MessageEmbedAuthor{Name: fmt.Sprintf("New clip from %s", clip.BroadcasterName)},
+		Color:     0x9146ff,
+		Fields:    fields,
+		Image:     &discordgo.MessageEmbedImage{URL: clip.ThumbnailURL},
+		Timestamp: clip.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// ClipCommand is the /clip command, which clips the live stream with createClip. It can be used by
+// everyone, at most once per clipCommandCooldown.
+func ClipCommand(createClip func() (string, error)) Command {
+	var mu sync.Mutex
+	var lastClip time.Time
+	return Command{
+		Definition: &discordgo.ApplicationCommand{
+			Name:        "clip",
+			Description: "Clip the last seconds of the live stream",
+		},
+		Handler: func(i *discordgo.InteractionCreate) (string, error) {
+			mu.Lock()
+			if wait := clipCommandCooldown - time.Since(lastClip); wait > 0 {
+				mu.Unlock()
+				return "", fmt.Errorf("a clip was just made, try again in %d seconds", int(wait.Seconds())+1)
+			}
+			// The cooldown starts before the clip is made so concurrent uses do not make several clips,
+			// and is undone if no clip was made.
+			previous := lastClip
+			lastClip = time.Now()
+			mu.Unlock()
+			url, err := createClip()
+			if err != nil {
+				mu.Lock()
+				lastClip = previous
+				mu.Unlock()
+			}
+			if errors.Is(err, twitchws.ErrNotLive) {
+				return "", fmt.Errorf("the stream is not live right now")
+			} else if err != nil {
+				return "", fmt.Errorf("could not create a clip: %w", err)
+			}
+			by := "someone"
+			if i.Member != nil && i.Member.User != nil {
+				by = i.Member.User.Username
+			}
+			return fmt.Sprintf("🎬 Clipped by %s: %s", escapeMarkdown(by), url), nil
+		},
+	}
+}
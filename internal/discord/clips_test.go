@@ -0,0 +1,36 @@
+package discord
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestClipCommandCooldownStartsAfterClip(t *testing.T) {
+	err := twitchws.ErrNotLive
+	command := ClipCommand(func() (string, error) {
+		if err != nil {
+			return "", err
+		}
+		return "https://clips.twitch.tv/x", nil
+	})
+	i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{}}
+
+	if _, got := command.Handler(i); got == nil || !strings.Contains(got.Error(), "not live") {
+		t.Fatalf("returned %v, want the stream not being live", got)
+	}
+	err = errors.New("boom")
+	if _, got := command.Handler(i); got == nil || !strings.Contains(got.Error(), "could not create a clip") {
+		t.Fatalf("returned %v after a failed clip, want another attempt", got)
+	}
+	err = nil
+	if response, got := command.Handler(i); got != nil || !strings.Contains(response, "https://clips.twitch.tv/x") {
+		t.Fatalf("returned %q, %v, want the clip", response, got)
+	}
+	if _, got := command.Handler(i); got == nil || !strings.Contains(got.Error(), "a clip was just made") {
+		t.Fatalf("returned %v right after a clip, want the cooldown", got)
+	}
+}
@@ -0,0 +1,272 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// CommandHandler handles an invocation of a slash command. The returned content is sent as the
+// command's response; an error is reported to the invoking user instead.
+type CommandHandler func(i *discordgo.InteractionCreate) (string, error)
+
+// Command is a guild slash command.
+type Command struct {
+	Definition *discordgo.ApplicationCommand
+	// AdminOnly restricts the command to the admin channels.
+	AdminOnly bool
+	// Ephemeral responses are only shown to the invoking user.
+	Ephemeral bool
+	Handler   CommandHandler
+}
+
+// AddCommand registers a slash command. Commands added before Run are registered with Discord when
+// the session opens, commands added afterwards are registered immediately.
+func (c *Client) AddCommand(command Command) error {
+	c.mu.Lock()
+	c.commands[command.Definition.Name] = command
+	open := c.open
+	c.mu.Unlock()
+	if !open {
+		return nil
+	}
+	if err := c.registerCommands(); err != nil {
+		return fmt.Errorf("error registering command %s: %w", command.Definition.Name, err)
+	}
+	return nil
+}
+
+func (c *Client) registerCommands() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.open = true
+	definitions := make([]*discordgo.ApplicationCommand, 0, len(c.commands))
+	for _, command := range c.commands {
+		definitions = append(definitions, command.Definition)
+	}
+	if err := c.transport.RegisterCommands(c.guildID, definitions); err != nil {
+		return fmt.Errorf("error registering commands: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) commandHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+	data := i.ApplicationCommandData()
+	c.mu.Lock()
+	command, ok := c.commands[data.Name]
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	// Handlers may call slow APIs, so acknowledge the command first and send the content once the
+	// handler is done, Discord only waits three seconds for the first response.
+	var flags discordgo.MessageFlags
+	if command.Ephemeral {
+		flags = discordgo.MessageFlagsEphemeral
+	}
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: flags},
+	}); err != nil {
+		log.Printf("error acknowledging command /%s: %s", data.Name, err)
+		return
+	}
+	var content string
+	var err error
+	if command.AdminOnly && !c.isAdminChannel(i.ChannelID) {
+		err = fmt.Errorf("/%s can only be used in an admin channel", data.Name)
+	} else if command.AdminOnly && !c.hasAdminRole(i.Member) {
+		err = fmt.Errorf("/%s can only be used by members with the admin role", data.Name)
+	} else {
+		content, err = command.Handler(i)
+	}
+	if err != nil {
+		log.Printf("error handling command /%s: %s", data.Name, err)
+		content = fmt.Sprintf("⚠️ %s", err)
+	}
+	content = truncate(content, 2000)
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content:         &content,
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	}); err != nil {
+		log.Printf("error responding to command /%s: %s", data.Name, err)
+	}
+}
+
+// ComponentHandler handles a click on a message component. The returned content replaces the content
+// of the clicked message and removes its components, an error is reported to the clicking user
+// instead and leaves the message as it is.
+type ComponentHandler func(i *discordgo.InteractionCreate) (string, error)
+
+// Component handles the message components whose custom ID is Prefix or starts with Prefix and ":".
+type Component struct {
+	Prefix string
+	// AdminOnly restricts the component to the admin channels.
+	AdminOnly bool
+	Handler   ComponentHandler
+}
+
+// AddComponent registers the handler of message components such as buttons.
+func (c *Client) AddComponent(component Component) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.components[component.Prefix] = component
+}
+
+func (c *Client) componentHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionMessageComponent {
+		return
+	}
+	customID := i.MessageComponentData().CustomID
+	prefix, _, _ := strings.Cut(customID, ":")
+	c.mu.Lock()
+	component, ok := c.components[prefix]
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	}); err != nil {
+		log.Printf("error acknowledging component %s: %s", customID, err)
+		return
+	}
+	var content string
+	var err error
+	if component.AdminOnly && (!c.isAdminChannel(i.ChannelID) || !c.hasAdminRole(i.Member)) {
+		err = fmt.Errorf("only members with the admin role can use this")
+	} else {
+		content, err = component.Handler(i)
+	}
+	if err != nil {
+		log.Printf("error handling component %s: %s", customID, err)
+		if _, err := s.FollowupMessageCreate(i.Interaction, false, &discordgo.WebhookParams{
+			Content: fmt.Sprintf("⚠️ %s", err),
+			Flags:   discordgo.MessageFlagsEphemeral,
+		}); err != nil {
+			log.Printf("error responding to component %s: %s", customID, err)
+		}
+		return
+	}
+	content = truncate(content, 2000)
+	components := []discordgo.MessageComponent{}
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content:         &content,
+		Components:      &components,
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	}); err != nil {
+		log.Printf("error responding to component %s: %s", customID, err)
+	}
+}
+
+func (c *Client) isAdminChannel(channelID string) bool {
+	for _, adminChannelID := range c.adminChannelIDs {
+		if adminChannelID != "" && adminChannelID == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAdminRole reports whether member has the configured admin role. Every member is allowed if no
+// admin role is configured.
+func (c *Client) hasAdminRole(member *discordgo.Member) bool {
+	if c.adminRoleID == "" {
+		return true
+	}
+	if member == nil {
+		return false
+	}
+	for _, roleID := range member.Roles {
+		if roleID == c.adminRoleID {
+			return true
+		}
+	}
+	return false
+}
+
+// Subcommand returns the name of the invoked subcommand, or "" if the command has none.
+func Subcommand(i *discordgo.InteractionCreate) string {
+	options := i.ApplicationCommandData().Options
+	if len(options) == 1 && options[0].Type == discordgo.ApplicationCommandOptionSubCommand {
+		return options[0].Name
+	}
+	return ""
+}
+
+// commandOptions returns the options of the invoked command, or of its subcommand if it has one.
+func commandOptions(i *discordgo.InteractionCreate) []*discordgo.ApplicationCommandInteractionDataOption {
+	options := i.ApplicationCommandData().Options
+	if len(options) == 1 && options[0].Type == discordgo.ApplicationCommandOptionSubCommand {
+		return options[0].Options
+	}
+	return options
+}
+
+// StringOption returns the value of the string option name of a command invocation, or "" if it was not given.
+func StringOption(i *discordgo.InteractionCreate, name string) string {
+	for _, option := range commandOptions(i) {
+		if option.Name == name && option.Type == discordgo.ApplicationCommandOptionString {
+			return option.StringValue()
+		}
+	}
+	return ""
+}
+
+// IntOption returns the value of the integer option name of a command invocation, or def if it was not given.
+func IntOption(i *discordgo.InteractionCreate, name string, def int64) int64 {
+	for _, option := range commandOptions(i) {
+		if option.Name == name && option.Type == discordgo.ApplicationCommandOptionInteger {
+			return option.IntValue()
+		}
+	}
+	return def
+}
+
+// BoolOption returns the value of the boolean option name of a command invocation, or nil if it was not given.
+func BoolOption(i *discordgo.InteractionCreate, name string) *bool {
+	for _, option := range commandOptions(i) {
+		if option.Name == name && option.Type == discordgo.ApplicationCommandOptionBoolean {
+			v := option.BoolValue()
+			return &v
+		}
+	}
+	return nil
+}
+
+// ConsentCommand is the /twitch-consent admin command, which replies with a link the broadcaster
+// opens to grant jagger the given scopes. newLink creates the link.
+func ConsentCommand(newLink func(scopes []string) (string, error), defaultScopes []string) Command {
+	return Command{
+		Definition: &discordgo.ApplicationCommand{
+			Name:        "twitch-consent",
+			Description: "Create a link for the broadcaster to authorize jagger on Twitch",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "scopes",
+					Description: "Space separated Twitch scopes, the defaults if empty",
+				},
+			},
+		},
+		AdminOnly: true,
+		Ephemeral: true,
+		Handler: func(i *discordgo.InteractionCreate) (string, error) {
+			scopes := strings.Fields(StringOption(i, "scopes"))
+			scopesName := "Scopes"
+			if len(scopes) == 0 {
+				scopes, scopesName = defaultScopes, "Default scopes"
+			}
+			link, err := newLink(scopes)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Send this link to the broadcaster, it is valid for 30 minutes and can be used once:\n%s\n%s: `%s`", link, scopesName, strings.Join(scopes, " ")), nil
+		},
+	}
+}
@@ -2,6 +2,8 @@ package discord
 
 import (
 	"fmt"
+	"log"
+	"sync"
 	"time"
 
 	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
@@ -10,43 +12,81 @@ import (
 	"github.com/shirou/gopsutil/mem"
 )
 
+// twitchChannelURL is the stream jagger announces.
+const twitchChannelURL = "https://twitch.tv/sensaiopti"
+
 type Config struct {
+	// Transport sends everything to Discord. If nil a SessionTransport is created for DiscordBotToken.
+	Transport         Transport
 	DiscordBotToken   string
 	DiscordChannelIDs []string
 	AdminChannelIDs   []string
-	DiscordGuildID    string
-	EventChannel      chan twitchws.Event
+	// AdminRoleID, if set, is required in addition to an admin channel for admin commands.
+	AdminRoleID    string
+	DiscordGuildID string
+	EventChannel   chan twitchws.Notification
 }
 
 type Client struct {
+	transport Transport
+	// session is the gateway connection for receiving events, nil unless the transport is a
+	// SessionTransport.
 	session         *discordgo.Session
 	guildID         string
 	channelIDs      []string
 	adminChannelIDs []string
-	eventChan       chan twitchws.Event
+	adminRoleID     string
+	eventChan       chan twitchws.Notification
 	start           time.Time
+
+	mu         sync.Mutex
+	commands   map[string]Command
+	components map[string]Component
+	open       bool
 }
 
 func NewClient(config *Config) (*Client, error) {
-	session, err := discordgo.New("Bot " + config.DiscordBotToken)
-	if err != nil {
-		return nil, fmt.Errorf("error creating discord client: %w", err)
+	transport := config.Transport
+	if transport == nil {
+		sessionTransport, err := NewSessionTransport(config.DiscordBotToken)
+		if err != nil {
+			return nil, fmt.Errorf("error creating discord client: %w", err)
+		}
+		transport = sessionTransport
+	}
+	var session *discordgo.Session
+	if sessionTransport, ok := transport.(*SessionTransport); ok {
+		session = sessionTransport.session
 	}
 	return &Client{
+		transport:       transport,
 		session:         session,
 		guildID:         config.DiscordGuildID,
 		channelIDs:      config.DiscordChannelIDs,
 		adminChannelIDs: config.AdminChannelIDs,
+		adminRoleID:     config.AdminRoleID,
 		eventChan:       config.EventChannel,
 		start:           time.Now(),
+		commands:        make(map[string]Command),
+		components:      make(map[string]Component),
 	}, nil
 }
 
+// Run opens the gateway connection and registers the slash commands. Without a session, e.g. with a
+// Recorder, only the commands are registered.
 func (c *Client) Run() error {
+	if c.session == nil {
+		return c.registerCommands()
+	}
 	c.session.AddHandler(c.infoHandler)
+	c.session.AddHandler(c.commandHandler)
+	c.session.AddHandler(c.componentHandler)
 	if err := c.session.Open(); err != nil {
 		return fmt.Errorf("error opening or continuing websocket connection to discord: %w", err)
 	}
+	if err := c.registerCommands(); err != nil {
+		log.Printf("slash commands are unavailable: %s", err)
+	}
 
 	return nil
 }
@@ -64,19 +104,46 @@ func (c *Client) infoHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
 
 func (c *Client) SendMessage(content string) {
 	for _, channelID := range c.channelIDs {
-		c.session.ChannelMessageSend(channelID, content)
+		if _, err := c.transport.SendMessage(channelID, content); err != nil {
+			log.Printf("error sending message to %s: %s", channelID, err)
+		}
 	}
 }
 
-func (c *Client) SendMessageEmbed(message, gameName, streamTitle string) {
+// SendMessageEmbed posts the go-live announcement to the announcement channels and returns the
+// messages that were sent.
+func (c *Client) SendMessageEmbed(message, gameName, streamTitle string) []*discordgo.Message {
+	var sent []*discordgo.Message
 	for _, channelID := range c.channelIDs {
-		c.session.ChannelMessageSendEmbed(channelID, c.gameEmbed(message, gameName, streamTitle))
+		m, err := c.transport.SendEmbed(channelID, c.gameEmbed(message, gameName, streamTitle))
+		if err != nil {
+			log.Printf("error sending embed to %s: %s", channelID, err)
+			continue
+		}
+		sent = append(sent, m)
 	}
+	return sent
 }
 
 func (c *Client) SendAdminMessage(content string) {
 	for _, channelID := range c.adminChannelIDs {
-		c.session.ChannelMessageSend(channelID, content)
+		if _, err := c.transport.SendMessage(channelID, content); err != nil {
+			log.Printf("error sending admin message to %s: %s", channelID, err)
+		}
+	}
+}
+
+// addHandler adds a gateway event handler. It does nothing without a session.
+func (c *Client) addHandler(handler interface{}) {
+	if c.session != nil {
+		c.session.AddHandler(handler)
+	}
+}
+
+// addIntents requests gateway intents on top of the defaults. It does nothing without a session.
+func (c *Client) addIntents(intents discordgo.Intent) {
+	if c.session != nil {
+		c.session.Identify.Intents |= intents
 	}
 }
 
@@ -84,7 +151,7 @@ func (c *Client) gameEmbed(message, gameName, streamTitle string) *discordgo.Mes
 	return &discordgo.MessageEmbed{
 		Title:       streamTitle,
 		Description: message,
-		URL:         "https://twitch.tv/sensaiopti",
+		URL:         twitchChannelURL,
 		Color:       0x33ff33,
 		Fields: []*discordgo.MessageEmbedField{
 			{
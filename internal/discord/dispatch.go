@@ -0,0 +1,189 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+)
+
+// goLiveMessage is the text of the go-live announcement.
+const goLiveMessage = "get in here, Sensai's shitting it up!"
+
+// Dispatcher passes Twitch EventSub notifications on to the features that handle them. Features
+// that are nil are disabled.
+type Dispatcher struct {
+	Client   *Client
+	Notifier *AdminNotifier
+	// ChannelInformation gets the title and game for the go-live announcement,
+	// twitchws.GetChannelInformation is used if it is nil.
+	ChannelInformation func() (*twitchws.GetChannelInformationResponse, error)
+	// BroadcasterID is the tracked broadcaster, raids from them are outgoing.
+	BroadcasterID string
+
+	EventLog        *twitchws.EventLog
+	Relay           *ChatRelay
+	LiveEvent       *LiveEvent
+	LiveStatus      *LiveStatus
+	VODs            *VODPoller
+	Raids           *RaidAnnouncer
+	Celebrations    *CelebrationFeed
+	SubscriberRoles *SubscriberRoleSync
+	Polls           *PollMirror
+	HypeTrains      *HypeTrainTracker
+	Redemptions     *RedemptionHandler
+	ModLog          *ModerationLog
+	BanSync         *BanSync
+}
+
+// Dispatch handles notification with every feature it concerns.
+func (d *Dispatcher) Dispatch(notification twitchws.Notification) {
+	log.Printf("received %s event: %v\n", notification.Subscription.Type, notification.Event)
+	if d.EventLog != nil {
+		d.EventLog.Add(notification)
+	}
+	d.Notifier.Notify(EventNotice(notification))
+	switch notification.Subscription.Type {
+	case twitchws.EventTypeStreamOnline:
+		if d.Relay != nil {
+			d.Relay.Start()
+		}
+		var online twitchws.StreamOnlineEvent
+		if err := notification.DecodeEvent(&online); err != nil {
+			d.Notifier.Error("Could not decode stream online event", err)
+		}
+		d.announceStream(online.ID)
+	case twitchws.EventTypeStreamOffline:
+		if d.Relay != nil {
+			d.Relay.Stop()
+		}
+		if d.LiveStatus != nil {
+			d.LiveStatus.Offline()
+		}
+		if d.LiveEvent != nil {
+			if err := d.LiveEvent.Offline(); err != nil {
+				d.Notifier.Error("Could not complete the live Discord event", err)
+			}
+		}
+	case twitchws.EventTypeChannelRaid:
+		var raid twitchws.RaidEvent
+		if err := notification.DecodeEvent(&raid); err != nil {
+			d.Notifier.Error("Could not decode raid", err)
+		} else if d.Raids != nil {
+			d.Raids.Handle(raid, d.BroadcasterID)
+		}
+	case twitchws.EventTypeChannelFollow, twitchws.EventTypeChannelSubscribe, twitchws.EventTypeChannelSubscriptionMessage,
+		twitchws.EventTypeChannelSubscriptionGift, twitchws.EventTypeChannelCheer:
+		if d.Celebrations != nil {
+			d.Celebrations.Handle(notification)
+		}
+		if notification.Subscription.Type == twitchws.EventTypeChannelSubscribe {
+			d.syncSubscriberRole(notification)
+		}
+	case twitchws.EventTypeChannelSubscriptionEnd:
+		d.syncSubscriberRole(notification)
+	case twitchws.EventTypeChannelPollBegin, twitchws.EventTypeChannelPollProgress, twitchws.EventTypeChannelPollEnd,
+		twitchws.EventTypeChannelPredictionBegin, twitchws.EventTypeChannelPredictionProgress,
+		twitchws.EventTypeChannelPredictionLock, twitchws.EventTypeChannelPredictionEnd:
+		if d.Polls != nil {
+			d.Polls.Handle(notification)
+		}
+	case twitchws.EventTypeChannelHypeTrainBegin, twitchws.EventTypeChannelHypeTrainProgress, twitchws.EventTypeChannelHypeTrainEnd:
+		if d.HypeTrains != nil {
+			d.HypeTrains.Handle(notification)
+		}
+	case twitchws.EventTypeChannelPointsRedemptionAdd:
+		var redemption twitchws.RedemptionEvent
+		if err := notification.DecodeEvent(&redemption); err != nil {
+			d.Notifier.Error("Could not decode channel points redemption", err)
+		} else if d.Redemptions != nil {
+			d.Redemptions.Handle(redemption)
+		}
+	case twitchws.EventTypeChannelBan, twitchws.EventTypeChannelUnban,
+		twitchws.EventTypeChannelModeratorAdd, twitchws.EventTypeChannelModeratorRemove,
+		twitchws.EventTypeAutomodMessageHold, twitchws.EventTypeAutomodMessageUpdate:
+		if d.ModLog != nil {
+			d.ModLog.Handle(notification)
+		}
+		if notification.Subscription.Type == twitchws.EventTypeChannelBan {
+			d.syncBan(notification)
+		}
+	case twitchws.EventTypeChannelUpdate:
+		var update twitchws.ChannelUpdateEvent
+		if err := notification.DecodeEvent(&update); err != nil {
+			d.Notifier.Error("Could not decode channel update", err)
+			break
+		}
+		if d.LiveStatus != nil {
+			d.LiveStatus.Update(update.CategoryName)
+		}
+		if d.LiveEvent != nil {
+			if err := d.LiveEvent.Update(update.Title, update.CategoryName); err != nil {
+				d.Notifier.Error("Could not update the live Discord event", err)
+			}
+		}
+	}
+}
+
+// announceStream posts the go-live announcement and starts the live Discord event, if enabled. The
+// announcement of streamID is recorded so VODs can link the stream's VOD from it, if enabled.
+func (d *Dispatcher) announceStream(streamID string) {
+	channelInformation := d.ChannelInformation
+	if channelInformation == nil {
+		channelInformation = twitchws.GetChannelInformation
+	}
+	var gameName, streamTitle string
+	resp, err := channelInformation()
+	if err != nil {
+		d.Notifier.Warning("Could not get channel information for stream announcement, sending a normal message", err.Error())
+		d.Client.SendMessage(goLiveMessage + " " + twitchChannelURL)
+	} else if len(resp.Data) != 1 {
+		d.Notifier.Warning("Unexpected channel information response", fmt.Sprintf("expected 1 channel, got %d", len(resp.Data)))
+	} else {
+		gameName, streamTitle = resp.Data[0].GameName, resp.Data[0].Title
+		announcements := d.Client.SendMessageEmbed(goLiveMessage, gameName, streamTitle)
+		if d.VODs != nil && streamID != "" {
+			d.VODs.StreamStarted(streamID, goLiveMessage, gameName, streamTitle, announcements)
+		}
+	}
+	if d.LiveStatus != nil {
+		d.LiveStatus.Online(gameName)
+	}
+	if d.LiveEvent != nil {
+		if err := d.LiveEvent.Online(streamTitle, gameName); err != nil {
+			d.Notifier.Error("Could not start the live Discord event", err)
+		}
+	}
+}
+
+// syncSubscriberRole syncs the subscriber role of the user whose subscription started or ended in
+// notification, if subscriber roles are enabled.
+func (d *Dispatcher) syncSubscriberRole(notification twitchws.Notification) {
+	if d.SubscriberRoles == nil {
+		return
+	}
+	var subscription twitchws.SubscriptionEndEvent
+	if err := notification.DecodeEvent(&subscription); err != nil {
+		log.Printf("error decoding subscription to sync its role: %s", err)
+		return
+	}
+	tier := subscription.Tier
+	if notification.Subscription.Type == twitchws.EventTypeChannelSubscriptionEnd {
+		tier = ""
+	}
+	go d.SubscriberRoles.SyncTier(subscription.UserID, tier)
+}
+
+// syncBan offers to ban the linked Discord account of the user banned in notification, if ban sync is
+// enabled.
+func (d *Dispatcher) syncBan(notification twitchws.Notification) {
+	if d.BanSync == nil {
+		return
+	}
+	var ban twitchws.BanEvent
+	if err := notification.DecodeEvent(&ban); err != nil {
+		log.Printf("error decoding ban to sync it: %s", err)
+		return
+	}
+	d.BanSync.TwitchBan(ban)
+}
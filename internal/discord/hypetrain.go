@@ -0,0 +1,113 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	hypeTrainMessagesBucket = "hype_train_messages"
+	hypeTrainColor          = 0xff6905
+)
+
+type HypeTrainConfig struct {
+	// ChannelIDs are where hype trains are tracked, the announcement channels if empty.
+	ChannelIDs []string
+	// PingRoleID is mentioned when a hype train starts, if set.
+	PingRoleID string
+}
+
+// HypeTrainTracker tracks Twitch hype trains in a Discord message that is edited as the hype train
+// progresses and summarizes it when it ends.
+type HypeTrainTracker struct {
+	client *Client
+	store  *store.Store
+	config HypeTrainConfig
+}
+
+func NewHypeTrainTracker(client *Client, s *store.Store, config HypeTrainConfig) *HypeTrainTracker {
+	if len(config.ChannelIDs) == 0 {
+		config.ChannelIDs = client.channelIDs
+	}
+	return &HypeTrainTracker{client: client, store: s, config: config}
+}
+
+// Handle tracks notification if it is a hype train notification.
+func (t *HypeTrainTracker) Handle(notification twitchws.Notification) {
+	switch notification.Subscription.Type {
+	case twitchws.EventTypeChannelHypeTrainBegin, twitchws.EventTypeChannelHypeTrainProgress, twitchws.EventTypeChannelHypeTrainEnd:
+	default:
+		return
+	}
+	var train twitchws.HypeTrainEvent
+	if err := notification.DecodeEvent(&train); err != nil {
+		log.Printf("error tracking hype train: %s", err)
+		return
+	}
+	ended := notification.Subscription.Type == twitchws.EventTypeChannelHypeTrainEnd
+	var content string
+	if t.config.PingRoleID != "" {
+		content = fmt.Sprintf("<@&%s> a hype train has started!", t.config.PingRoleID)
+	}
+	if err := t.client.mirrorEmbed(t.store, hypeTrainMessagesBucket, train.ID, t.config.ChannelIDs, content, t.config.PingRoleID, hypeTrainEmbed(train, ended), ended); err != nil {
+		log.Printf("error tracking hype train %s: %s", train.ID, err)
+	}
+	forgetMirroredMessages(t.store, hypeTrainMessagesBucket, time.Now())
+}
+
+func hypeTrainEmbed(train twitchws.HypeTrainEvent, ended bool) *discordgo.MessageEmbed {
+	var lines []string
+	if ended {
+		lines = append(lines, fmt.Sprintf("The hype train reached **level %d** with %s, thank you all!", train.Level, hypeTrainPoints(train.Total)))
+		if startedAt, err := time.Parse(time.RFC3339, train.StartedAt); err == nil {
+			if endedAt, err := time.Parse(time.RFC3339, train.EndedAt); err == nil {
+				lines = append(lines, fmt.Sprintf("It ran for %s.", endedAt.Sub(startedAt).Round(time.Second)))
+			}
+		}
+	} else {
+		lines = append(lines, fmt.Sprintf("**Level %d** — %d%% to level %d", train.Level, percent(train.Progress, train.Goal), train.Level+1))
+		lines = append(lines, bar(train.Progress, train.Goal))
+		if expiresAt, err := time.Parse(time.RFC3339, train.ExpiresAt); err == nil {
+			lines = append(lines, fmt.Sprintf("Ends <t:%d:R> unless it keeps going", expiresAt.Unix()))
+		}
+	}
+	fields := []*discordgo.MessageEmbedField{{Name: "Total", Value: hypeTrainPoints(train.Total), Inline: true}}
+	if len(train.TopContributions) > 0 {
+		var contributors []string
+		for _, contribution := range train.TopContributions {
+			contributors = append(contributors, fmt.Sprintf("**%s** — %s", escapeMarkdown(contribution.UserName), contributionAmount(contribution)))
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Top contributors", Value: strings.Join(contributors, "\n"), Inline: true})
+	}
+	title := fmt.Sprintf("🚂 Hype train level %d!", train.Level)
+	if ended {
+		title = "🚂 The hype train has arrived"
+	}
+	return &discordgo.MessageEmbed{
+		Title:       title,
+		URL:         twitchChannelURL,
+		Description: strings.Join(lines, "\n"),
+		Color:       hypeTrainColor,
+		Fields:      fields,
+	}
+}
+
+func contributionAmount(contribution twitchws.HypeTrainContribution) string {
+	switch contribution.Type {
+	case "bits":
+		return plural(contribution.Total, "bit")
+	case "subscription":
+		return fmt.Sprintf("%s in subs", hypeTrainPoints(contribution.Total))
+	}
+	return hypeTrainPoints(contribution.Total)
+}
+
+func hypeTrainPoints(total int) string {
+	return plural(total, "point")
+}
@@ -0,0 +1,142 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
+	"github.com/bwmarrin/discordgo"
+)
+
+// linkedAccountsBucket maps Twitch user IDs to the Discord accounts they linked.
+const linkedAccountsBucket = "linked_accounts"
+
+// linkedAccount is a Discord account linked to a Twitch account.
+type linkedAccount struct {
+	DiscordUserID string    `json:"discord_user_id"`
+	TwitchLogin   string    `json:"twitch_login"`
+	LinkedAt      time.Time `json:"linked_at"`
+}
+
+// LinkAccount links the Twitch account twitchUserID to the Discord account discordUserID, replacing
+// the previous links of both.
+func LinkAccount(s *store.Store, discordUserID, twitchUserID, twitchLogin string) error {
+	if _, err := unlinkAccount(s, discordUserID); err != nil {
+		return err
+	}
+	return s.Put(linkedAccountsBucket, twitchUserID, linkedAccount{
+		DiscordUserID: discordUserID,
+		TwitchLogin:   twitchLogin,
+		LinkedAt:      time.Now(),
+	})
+}
+
+// linkedDiscordUser returns the Discord user ID linked to twitchUserID.
+func linkedDiscordUser(s *store.Store, twitchUserID string) (string, bool, error) {
+	if twitchUserID == "" {
+		return "", false, nil
+	}
+	var account linkedAccount
+	found, err := s.Get(linkedAccountsBucket, twitchUserID, &account)
+	if err != nil || !found {
+		return "", false, err
+	}
+	return account.DiscordUserID, true, nil
+}
+
+// linkedTwitchAccount returns the Twitch user ID and account linked to discordUserID.
+func linkedTwitchAccount(s *store.Store, discordUserID string) (string, linkedAccount, bool) {
+	for _, twitchUserID := range s.Keys(linkedAccountsBucket) {
+		var account linkedAccount
+		if _, err := s.Get(linkedAccountsBucket, twitchUserID, &account); err == nil && account.DiscordUserID == discordUserID {
+			return twitchUserID, account, true
+		}
+	}
+	return "", linkedAccount{}, false
+}
+
+// unlinkAccount removes the link of discordUserID and reports whether it had one.
+func unlinkAccount(s *store.Store, discordUserID string) (bool, error) {
+	twitchUserID, _, found := linkedTwitchAccount(s, discordUserID)
+	if !found {
+		return false, nil
+	}
+	if err := s.Delete(linkedAccountsBucket, twitchUserID); err != nil {
+		return false, fmt.Errorf("error unlinking %s: %w", discordUserID, err)
+	}
+	return true, nil
+}
+
+// LinkCommand is the /link command, which replies with a link the invoking member opens to link
+// their Twitch account. newLink creates the link for a Discord user ID.
+func LinkCommand(s *store.Store, newLink func(discordUserID string) (string, error)) Command {
+	return Command{
+		Definition: &discordgo.ApplicationCommand{
+			Name:        "link",
+			Description: "Link your Twitch account to your Discord account",
+		},
+		Ephemeral: true,
+		Handler: func(i *discordgo.InteractionCreate) (string, error) {
+			userID := interactionUserID(i)
+			if userID == "" {
+				return "", fmt.Errorf("could not tell who you are, use /link in the server")
+			}
+			link, err := newLink(userID)
+			if err != nil {
+				return "", fmt.Errorf("could not create a link: %w", err)
+			}
+			reply := fmt.Sprintf("Open this link and log in with Twitch to link your account, it is valid for 30 minutes:\n%s", link)
+			if _, account, found := linkedTwitchAccount(s, userID); found {
+				reply = fmt.Sprintf("You are linked to **%s** on Twitch, linking again replaces it.\n%s", escapeMarkdown(account.TwitchLogin), reply)
+			}
+			return reply, nil
+		},
+	}
+}
+
+// UnlinkCommand is the /unlink command, which removes the invoking member's linked Twitch account.
+func UnlinkCommand(s *store.Store) Command {
+	return Command{
+		Definition: &discordgo.ApplicationCommand{
+			Name:        "unlink",
+			Description: "Unlink your Twitch account from your Discord account",
+		},
+		Ephemeral: true,
+		Handler: func(i *discordgo.InteractionCreate) (string, error) {
+			unlinked, err := unlinkAccount(s, interactionUserID(i))
+			if err != nil {
+				log.Println(err)
+				return "", fmt.Errorf("could not unlink your account, please try again")
+			}
+			if !unlinked {
+				return "Your Discord account is not linked to a Twitch account.", nil
+			}
+			return "Your Twitch account is no longer linked.", nil
+		},
+	}
+}
+
+// mentionLinked returns the escaped name of the Twitch user twitchUserID, followed by a mention of
+// their Discord account if they linked one.
+func mentionLinked(s *store.Store, twitchUserID, name string) string {
+	discordUserID, found, err := linkedDiscordUser(s, twitchUserID)
+	if err != nil {
+		log.Printf("error looking up linked account of %s: %s", twitchUserID, err)
+	}
+	if !found {
+		return escapeMarkdown(name)
+	}
+	return fmt.Sprintf("%s (<@%s>)", escapeMarkdown(name), discordUserID)
+}
+
+// interactionUserID returns the ID of the user who invoked i.
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
@@ -0,0 +1,203 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	liveEventBucket = "live_event"
+	liveEventKey    = "current"
+	// scheduledEventWindow is how far from the scheduled start a stream may go live and still start
+	// the scheduled event instead of creating a new one.
+	scheduledEventWindow = 2 * time.Hour
+	// liveEventExtension is how far ahead the end of the live event is moved while the stream is
+	// live, Discord ends external events at their end time.
+	liveEventExtension = time.Hour
+	// liveEventCheckInterval is how often the end of the live event is checked while live.
+	liveEventCheckInterval = 10 * time.Minute
+)
+
+// LiveEvent shows the stream in Discord's events UI while it is live: a guild scheduled event
+// pointing at the Twitch channel is started when the stream goes online, renamed when the title or
+// game change and completed when the stream goes offline.
+type LiveEvent struct {
+	client *Client
+	store  *store.Store
+
+	mu sync.Mutex
+}
+
+// liveEvent is the Discord scheduled event of the current stream.
+type liveEvent struct {
+	EventID string    `json:"event_id"`
+	EndTime time.Time `json:"end_time"`
+}
+
+func NewLiveEvent(client *Client, s *store.Store) *LiveEvent {
+	return &LiveEvent{client: client, store: s}
+}
+
+// Online starts the event scheduled for about now, or creates one if nothing is scheduled. If the
+// event was already started it is only renamed.
+func (l *LiveEvent) Online(title, gameName string) error {
+	if current, err := currentLiveEvent(l.store); current != nil || err != nil {
+		if err != nil {
+			return err
+		}
+		return l.Update(title, gameName)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	end := now.Add(defaultSegmentDuration)
+	params := liveEventParams(title, gameName)
+	params.ScheduledEndTime = &end
+	event, err := l.scheduledEvent(now)
+	if err != nil {
+		return err
+	}
+	if event == nil {
+		// Events cannot be created in the past, so it is scheduled a moment from now and started
+		// right away.
+		start := now.Add(time.Minute)
+		create := *params
+		create.ScheduledStartTime = &start
+		create.PrivacyLevel = discordgo.GuildScheduledEventPrivacyLevelGuildOnly
+		create.EntityType = discordgo.GuildScheduledEventEntityTypeExternal
+		if event, err = l.client.transport.CreateScheduledEvent(l.client.guildID, &create); err != nil {
+			return fmt.Errorf("error creating live event: %w", err)
+		}
+	}
+	params.Status = discordgo.GuildScheduledEventStatusActive
+	if _, err := l.client.transport.EditScheduledEvent(l.client.guildID, event.ID, params); err != nil {
+		return fmt.Errorf("error starting live event %s: %w", event.ID, err)
+	}
+	return l.store.Put(liveEventBucket, liveEventKey, liveEvent{EventID: event.ID, EndTime: end})
+}
+
+// scheduledEvent returns the upcoming event for the Twitch channel scheduled closest to now within
+// scheduledEventWindow, or nil if there is none.
+func (l *LiveEvent) scheduledEvent(now time.Time) (*discordgo.GuildScheduledEvent, error) {
+	events, err := l.client.transport.ScheduledEvents(l.client.guildID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scheduled events: %w", err)
+	}
+	var closest *discordgo.GuildScheduledEvent
+	for _, event := range events {
+		if event.Status != discordgo.GuildScheduledEventStatusScheduled ||
+			event.EntityType != discordgo.GuildScheduledEventEntityTypeExternal ||
+			event.EntityMetadata.Location != twitchChannelURL {
+			continue
+		}
+		distance := event.ScheduledStartTime.Sub(now).Abs()
+		if distance > scheduledEventWindow {
+			continue
+		}
+		if closest == nil || distance < closest.ScheduledStartTime.Sub(now).Abs() {
+			closest = event
+		}
+	}
+	return closest, nil
+}
+
+// Run moves the end of the live event ahead while the stream is live until stop is closed, so
+// Discord does not end it during long streams.
+func (l *LiveEvent) Run(stop chan struct{}) {
+	ticker := time.NewTicker(liveEventCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if err := l.extend(now); err != nil {
+				log.Printf("error extending live event: %s", err)
+			}
+		}
+	}
+}
+
+// extend moves the end of the live event to liveEventExtension from now when it would otherwise
+// pass before the next two checks.
+func (l *LiveEvent) extend(now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	current, err := currentLiveEvent(l.store)
+	if current == nil || err != nil {
+		return err
+	}
+	if current.EndTime.Sub(now) >= 2*liveEventCheckInterval {
+		return nil
+	}
+	current.EndTime = now.Add(liveEventExtension)
+	if _, err := l.client.transport.EditScheduledEvent(l.client.guildID, current.EventID, &discordgo.GuildScheduledEventParams{
+		ScheduledEndTime: &current.EndTime,
+	}); err != nil {
+		return fmt.Errorf("error extending live event %s: %w", current.EventID, err)
+	}
+	return l.store.Put(liveEventBucket, liveEventKey, current)
+}
+
+// Update renames the live event after the title or game changed. It does nothing while offline.
+func (l *LiveEvent) Update(title, gameName string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	current, err := currentLiveEvent(l.store)
+	if current == nil || err != nil {
+		return err
+	}
+	params := liveEventParams(title, gameName)
+	if _, err := l.client.transport.EditScheduledEvent(l.client.guildID, current.EventID, params); err != nil {
+		return fmt.Errorf("error updating live event %s: %w", current.EventID, err)
+	}
+	return l.store.Put(liveEventBucket, liveEventKey, current)
+}
+
+// Offline completes the live event.
+func (l *LiveEvent) Offline() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	current, err := currentLiveEvent(l.store)
+	if current == nil || err != nil {
+		return err
+	}
+	if _, err := l.client.transport.EditScheduledEvent(l.client.guildID, current.EventID, &discordgo.GuildScheduledEventParams{
+		Status: discordgo.GuildScheduledEventStatusCompleted,
+	}); err != nil && !isNotFound(err) {
+		return fmt.Errorf("error completing live event %s: %w", current.EventID, err)
+	}
+	return l.store.Delete(liveEventBucket, liveEventKey)
+}
+
+// currentLiveEvent returns the live event, or nil if the stream is offline.
+func currentLiveEvent(s *store.Store) (*liveEvent, error) {
+	var current liveEvent
+	found, err := s.Get(liveEventBucket, liveEventKey, &current)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &current, nil
+}
+
+func liveEventParams(title, gameName string) *discordgo.GuildScheduledEventParams {
+	if title == "" {
+		title = "SensaiOpti is live"
+	}
+	name := fmt.Sprintf("🔴 %s", title)
+	description := "Live on Twitch"
+	if gameName != "" {
+		name = fmt.Sprintf("%s — %s", name, gameName)
+		description = fmt.Sprintf("Playing %s", gameName)
+	}
+	return &discordgo.GuildScheduledEventParams{
+		Name:           truncate(name, 100),
+		Description:    truncate(description, 1000),
+		EntityMetadata: &discordgo.GuildScheduledEventEntityMetadata{Location: twitchChannelURL},
+	}
+}
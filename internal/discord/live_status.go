@@ -0,0 +1,154 @@
+package discord
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	defaultLiveChannelName = "🔴 live-now"
+	// defaultRenameInterval keeps renames within Discord's limit of two per channel every 10 minutes.
+	defaultRenameInterval = 5 * time.Minute
+)
+
+type LiveStatusConfig struct {
+	// ChannelID, if set, is renamed to LiveName while the stream is live and back to OfflineName
+	// when it goes offline.
+	ChannelID string
+	// LiveName is the channel name while live, "🔴 live-now" if it is empty.
+	LiveName    string
+	OfflineName string
+	// RenameInterval is the least time between two renames, 5 minutes if it is 0.
+	RenameInterval time.Duration
+}
+
+// LiveStatus shows the stream is live with a streaming activity of the bot linking to the Twitch
+// channel, and optionally with the name of a channel. Renames are spaced out by RenameInterval, if
+// the stream goes on and offline in between only the latest state is applied.
+type LiveStatus struct {
+	client *Client
+	config LiveStatusConfig
+
+	mu   sync.Mutex
+	live bool
+	game string
+	// channelName is the name the channel was last renamed to, or had when jagger started.
+	channelName string
+	lastRename  time.Time
+	// renamePending is set while a rename waits for the interval to pass.
+	renamePending bool
+}
+
+func NewLiveStatus(client *Client, config LiveStatusConfig) *LiveStatus {
+	if config.LiveName == "" {
+		config.LiveName = defaultLiveChannelName
+	}
+	if config.RenameInterval == 0 {
+		config.RenameInterval = defaultRenameInterval
+	}
+	l := &LiveStatus{client: client, config: config}
+	if config.ChannelID != "" {
+		// Without the current name every restart would spend a rename on the name the channel has.
+		if channel, err := client.transport.Channel(config.ChannelID); err != nil {
+			log.Printf("error getting name of live channel %s: %s", config.ChannelID, err)
+		} else {
+			l.channelName = channel.Name
+		}
+	}
+	client.addHandler(l.ready)
+	return l
+}
+
+// ready restores the presence when the gateway connection is opened again, Discord forgets it.
+func (l *LiveStatus) ready(s *discordgo.Session, r *discordgo.Ready) {
+	l.updatePresence()
+}
+
+// Online sets the streaming activity for gameName and renames the channel to LiveName.
+func (l *LiveStatus) Online(gameName string) {
+	l.mu.Lock()
+	l.live, l.game = true, gameName
+	l.mu.Unlock()
+	l.updatePresence()
+	l.scheduleRename()
+}
+
+// Update changes the game of the streaming activity while the stream is live.
+func (l *LiveStatus) Update(gameName string) {
+	l.mu.Lock()
+	changed := l.live && l.game != gameName
+	l.game = gameName
+	l.mu.Unlock()
+	if changed {
+		l.updatePresence()
+	}
+}
+
+// Offline clears the streaming activity and renames the channel back to OfflineName.
+func (l *LiveStatus) Offline() {
+	l.mu.Lock()
+	l.live = false
+	l.mu.Unlock()
+	l.updatePresence()
+	l.scheduleRename()
+}
+
+func (l *LiveStatus) updatePresence() {
+	l.mu.Lock()
+	live, game := l.live, l.game
+	l.mu.Unlock()
+	var activity *discordgo.Activity
+	if live {
+		if game == "" {
+			game = "on Twitch"
+		}
+		activity = &discordgo.Activity{Name: game, Type: discordgo.ActivityTypeStreaming, URL: twitchChannelURL}
+	}
+	if err := l.client.transport.UpdatePresence(activity); err != nil {
+		log.Printf("error updating bot presence: %s", err)
+	}
+}
+
+// scheduleRename renames the channel right away if RenameInterval passed since the last rename, or
+// once it has.
+func (l *LiveStatus) scheduleRename() {
+	if l.config.ChannelID == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.renamePending {
+		return
+	}
+	l.renamePending = true
+	wait := l.config.RenameInterval - time.Since(l.lastRename)
+	if wait < 0 {
+		wait = 0
+	}
+	time.AfterFunc(wait, l.rename)
+}
+
+func (l *LiveStatus) rename() {
+	l.mu.Lock()
+	l.renamePending = false
+	name := l.config.OfflineName
+	if l.live {
+		name = l.config.LiveName
+	}
+	if name == l.channelName {
+		l.mu.Unlock()
+		return
+	}
+	l.lastRename = time.Now()
+	l.mu.Unlock()
+	if err := l.client.transport.RenameChannel(l.config.ChannelID, name); err != nil {
+		log.Printf("error renaming channel %s to %s: %s", l.config.ChannelID, name, err)
+		return
+	}
+	l.mu.Lock()
+	l.channelName = name
+	l.mu.Unlock()
+}
@@ -0,0 +1,43 @@
+package discord
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLiveStatusRenamesChannel(t *testing.T) {
+	client, recorder, _ := newTestClient(t)
+	recorder.RenameChannel("live", "offline")
+	l := NewLiveStatus(client, LiveStatusConfig{
+		ChannelID:      "live",
+		OfflineName:    "offline",
+		RenameInterval: 100 * time.Millisecond,
+	})
+
+	// The channel already has the offline name, it is not renamed to it again.
+	l.Offline()
+	time.Sleep(20 * time.Millisecond)
+	l.mu.Lock()
+	renamed := !l.lastRename.IsZero()
+	l.mu.Unlock()
+	if renamed {
+		t.Fatal("the channel was renamed to the name it already had")
+	}
+
+	l.Online("Just Chatting")
+	eventually(t, func() bool { return recorder.ChannelName("live") == defaultLiveChannelName })
+	if presence := recorder.Presence(); presence == nil || presence.Name != "Just Chatting" {
+		t.Fatalf("presence is %v, want streaming Just Chatting", presence)
+	}
+
+	// Going offline right away waits for the rename interval.
+	l.Offline()
+	time.Sleep(20 * time.Millisecond)
+	if name := recorder.ChannelName("live"); name != defaultLiveChannelName {
+		t.Fatalf("channel was renamed to %q before the interval passed", name)
+	}
+	eventually(t, func() bool { return recorder.ChannelName("live") == "offline" })
+	if presence := recorder.Presence(); presence != nil {
+		t.Fatalf("presence is %v after going offline, want none", presence)
+	}
+}
@@ -0,0 +1,84 @@
+package discord
+
+import (
+	"log"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
+	"github.com/bwmarrin/discordgo"
+)
+
+// mirroredMessageRetention is how long the messages of ended Twitch interactions are remembered, to
+// ignore notifications delivered after the end.
+const mirroredMessageRetention = 24 * time.Hour
+
+// mirroredMessages are the messages mirroring a Twitch interaction, like a poll or hype train, that
+// are edited as it progresses.
+type mirroredMessages struct {
+	Posts     []announcementPost `json:"posts"`
+	Ended     bool               `json:"ended"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// mirrorEmbed posts embed for the interaction with id to channelIDs, or edits the messages already
+// posted for it, which are kept in bucket. content is only sent with the first message and may
+// mention mentionRoleID. Once the interaction has ended only the final embed is applied,
+// notifications can arrive out of order.
+func (c *Client) mirrorEmbed(s *store.Store, bucket, id string, channelIDs []string, content, mentionRoleID string, embed *discordgo.MessageEmbed, ended bool) error {
+	var messages mirroredMessages
+	found, err := s.Get(bucket, id, &messages)
+	if err != nil {
+		return err
+	}
+	if messages.Ended {
+		return nil
+	}
+	if !found {
+		allowedMentions := &discordgo.MessageAllowedMentions{}
+		if mentionRoleID != "" {
+			allowedMentions.Roles = []string{mentionRoleID}
+		}
+		for _, channelID := range channelIDs {
+			if channelID == "" {
+				continue
+			}
+			message, err := c.transport.SendComplex(channelID, &discordgo.MessageSend{
+				Content:         content,
+				Embeds:          []*discordgo.MessageEmbed{embed},
+				AllowedMentions: allowedMentions,
+			})
+			if err != nil {
+				log.Printf("error sending %s to %s: %s", id, channelID, err)
+				continue
+			}
+			messages.Posts = append(messages.Posts, announcementPost{ChannelID: channelID, MessageID: message.ID})
+		}
+	} else {
+		for _, post := range messages.Posts {
+			if _, err := c.transport.EditMessage(&discordgo.MessageEdit{
+				ID:      post.MessageID,
+				Channel: post.ChannelID,
+				Embeds:  []*discordgo.MessageEmbed{embed},
+			}); err != nil {
+				log.Printf("error editing %s in %s: %s", id, post.ChannelID, err)
+			}
+		}
+	}
+	messages.Ended = ended
+	messages.UpdatedAt = time.Now()
+	return s.Put(bucket, id, messages)
+}
+
+// forgetMirroredMessages forgets the messages in bucket that were last updated before
+// mirroredMessageRetention.
+func forgetMirroredMessages(s *store.Store, bucket string, now time.Time) {
+	for _, id := range s.Keys(bucket) {
+		var messages mirroredMessages
+		if _, err := s.Get(bucket, id, &messages); err != nil || now.Sub(messages.UpdatedAt) < mirroredMessageRetention {
+			continue
+		}
+		if err := s.Delete(bucket, id); err != nil {
+			log.Printf("error forgetting mirrored messages of %s: %s", id, err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package discord
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestMirrorEmbedEditsPostedMessages(t *testing.T) {
+	client, recorder, s := newTestClient(t)
+	channelIDs := []string{"first", "second"}
+	mirror := func(title string, ended bool) {
+		t.Helper()
+		if err := client.mirrorEmbed(s, "test_mirror", "poll-1", channelIDs, "A poll started", "", &discordgo.MessageEmbed{Title: title}, ended); err != nil {
+			t.Fatal(err)
+		}
+	}
+	titles := func() []string {
+		var titles []string
+		for _, m := range recorder.Messages("") {
+			titles = append(titles, m.Embeds[0].Title)
+		}
+		return titles
+	}
+
+	mirror("begin", false)
+	if got := titles(); len(got) != 2 || got[0] != "begin" || got[1] != "begin" {
+		t.Fatalf("posted %v, want a begin embed in each channel", got)
+	}
+	mirror("progress", false)
+	mirror("end", true)
+	if got := titles(); len(got) != 2 || got[0] != "end" || got[1] != "end" {
+		t.Fatalf("messages are %v after the end, want both edited to end", got)
+	}
+	// A progress notification delivered after the end does not undo it.
+	mirror("late progress", false)
+	if got := titles(); got[0] != "end" || got[1] != "end" {
+		t.Fatalf("messages are %v after a late notification, want end", got)
+	}
+	if content := recorder.Messages("first")[0].Content; content != "A poll started" {
+		t.Fatalf("content is %q, want the first message's content kept", content)
+	}
+}
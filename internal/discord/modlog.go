@@ -0,0 +1,386 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	moderationLogBucket        = "moderation_log"
+	defaultModerationRetention = 90 * 24 * time.Hour
+	defaultModerationEntries   = 2000
+	moderationPruneInterval    = time.Hour
+	defaultModerationResults   = 10
+	maxModerationResults       = 25
+)
+
+// Moderation log sources.
+const (
+	ModerationSourceTwitch  = "twitch"
+	ModerationSourceDiscord = "discord"
+)
+
+// Moderation log actions. Automod actions are "automod held" and "automod " followed by the
+// lowercased status of the held message, e.g. "automod approved".
+const (
+	ModerationBan         = "ban"
+	ModerationTimeout     = "timeout"
+	ModerationUnban       = "unban"
+	ModerationModAdd      = "mod"
+	ModerationModRemove   = "unmod"
+	ModerationAutomodHeld = "automod held"
+)
+
+// ModerationEntry is an action in the moderation audit log.
+type ModerationEntry struct {
+	Source    string `json:"source"`
+	Action    string `json:"action"`
+	Moderator string `json:"moderator,omitempty"`
+	Target    string `json:"target"`
+	TargetID  string `json:"target_id"`
+	Reason    string `json:"reason,omitempty"`
+	// Duration is how long a timeout lasts.
+	Duration time.Duration `json:"duration,omitempty"`
+	At       time.Time     `json:"at"`
+}
+
+type ModerationLogConfig struct {
+	// ChannelID is the private moderator channel entries are posted to.
+	ChannelID string
+	// Retention is how long entries are kept for search, 90 days if it is 0.
+	Retention time.Duration
+	// MaxEntries is how many entries are kept at most, the oldest are pruned first. 2000 if it is
+	// 0, every entry is kept in the store, which is rewritten on every write.
+	MaxEntries int
+	// Timeouts records Discord timeouts. It needs the privileged Server Members intent to be enabled
+	// for the bot in the developer portal.
+	Timeouts bool
+}
+
+// ModerationLog keeps an audit trail of moderation on Twitch and Discord in the store and posts each
+// action to the moderator channel.
+type ModerationLog struct {
+	client *Client
+	store  *store.Store
+	config ModerationLogConfig
+
+	// mu keeps entry keys unique and guards timeouts.
+	mu sync.Mutex
+	// timeouts are the ends of the recorded Discord timeouts by user ID, member updates during a
+	// timeout are not recorded again.
+	timeouts map[string]time.Time
+}
+
+// NewModerationLog creates a ModerationLog that records Twitch notifications passed to Handle and
+// Discord bans, unbans and, if enabled, timeouts. The gateway does not tell who moderated Discord
+// members or why, so their entries have no moderator or reason.
+func NewModerationLog(client *Client, s *store.Store, config ModerationLogConfig) *ModerationLog {
+	if config.Retention == 0 {
+		config.Retention = defaultModerationRetention
+	}
+	if config.MaxEntries == 0 {
+		config.MaxEntries = defaultModerationEntries
+	}
+	l := &ModerationLog{client: client, store: s, config: config, timeouts: make(map[string]time.Time)}
+	client.addHandler(l.guildBanAdd)
+	client.addHandler(l.guildBanRemove)
+	if config.Timeouts {
+		client.addIntents(discordgo.IntentsGuildMembers)
+		client.addHandler(l.guildMemberUpdate)
+	}
+	return l
+}
+
+func (l *ModerationLog) guildBanAdd(s *discordgo.Session, e *discordgo.GuildBanAdd) {
+	if e.GuildID == l.client.guildID && e.User != nil {
+		l.Record(ModerationEntry{Source: ModerationSourceDiscord, Action: ModerationBan, Target: e.User.Username, TargetID: e.User.ID})
+	}
+}
+
+func (l *ModerationLog) guildBanRemove(s *discordgo.Session, e *discordgo.GuildBanRemove) {
+	if e.GuildID == l.client.guildID && e.User != nil {
+		l.Record(ModerationEntry{Source: ModerationSourceDiscord, Action: ModerationUnban, Target: e.User.Username, TargetID: e.User.ID})
+	}
+}
+
+func (l *ModerationLog) guildMemberUpdate(s *discordgo.Session, e *discordgo.GuildMemberUpdate) {
+	if e.Member != nil && e.GuildID == l.client.guildID && e.User != nil {
+		l.recordTimeout(e.User, e.CommunicationDisabledUntil, time.Now())
+	}
+}
+
+// recordTimeout records the timeout of user until until, if it was not recorded yet. until is nil
+// or in the past if user is not timed out.
+func (l *ModerationLog) recordTimeout(user *discordgo.User, until *time.Time, now time.Time) {
+	if until == nil || !until.After(now) {
+		return
+	}
+	l.mu.Lock()
+	for userID, end := range l.timeouts {
+		if !end.After(now) {
+			delete(l.timeouts, userID)
+		}
+	}
+	recorded := l.timeouts[user.ID].Equal(*until)
+	l.timeouts[user.ID] = *until
+	l.mu.Unlock()
+	if recorded {
+		return
+	}
+	l.Record(ModerationEntry{
+		Source:   ModerationSourceDiscord,
+		Action:   ModerationTimeout,
+		Target:   user.Username,
+		TargetID: user.ID,
+		Duration: until.Sub(now).Round(time.Second),
+		At:       now,
+	})
+}
+
+// Handle records notification if it is a Twitch moderation notification.
+func (l *ModerationLog) Handle(notification twitchws.Notification) {
+	entry := ModerationEntry{Source: ModerationSourceTwitch, At: time.Now()}
+	var err error
+	switch notification.Subscription.Type {
+	case twitchws.EventTypeChannelBan:
+		var ban twitchws.BanEvent
+		if err = notification.DecodeEvent(&ban); err == nil {
+			entry.Action, entry.Moderator, entry.Target, entry.TargetID, entry.Reason = ModerationBan, ban.ModeratorUserLogin, ban.UserLogin, ban.UserID, ban.Reason
+			if !ban.IsPermanent {
+				entry.Action = ModerationTimeout
+				bannedAt, bannedErr := time.Parse(time.RFC3339, ban.BannedAt)
+				endsAt, endsErr := time.Parse(time.RFC3339, ban.EndsAt)
+				if bannedErr == nil && endsErr == nil {
+					entry.Duration = endsAt.Sub(bannedAt).Round(time.Second)
+				}
+			}
+		}
+	case twitchws.EventTypeChannelUnban:
+		var unban twitchws.UnbanEvent
+		if err = notification.DecodeEvent(&unban); err == nil {
+			entry.Action, entry.Moderator, entry.Target, entry.TargetID = ModerationUnban, unban.ModeratorUserLogin, unban.UserLogin, unban.UserID
+		}
+	case twitchws.EventTypeChannelModeratorAdd, twitchws.EventTypeChannelModeratorRemove:
+		var moderator twitchws.ModeratorEvent
+		if err = notification.DecodeEvent(&moderator); err == nil {
+			entry.Action, entry.Moderator, entry.Target, entry.TargetID = ModerationModAdd, moderator.BroadcasterUserLogin, moderator.UserLogin, moderator.UserID
+			if notification.Subscription.Type == twitchws.EventTypeChannelModeratorRemove {
+				entry.Action = ModerationModRemove
+			}
+		}
+	case twitchws.EventTypeAutomodMessageHold, twitchws.EventTypeAutomodMessageUpdate:
+		var message twitchws.AutomodMessageEvent
+		if err = notification.DecodeEvent(&message); err == nil {
+			entry.Action, entry.Moderator, entry.Target, entry.TargetID = ModerationAutomodHeld, message.ModeratorUserLogin, message.UserLogin, message.UserID
+			entry.Reason = fmt.Sprintf("%s (level %d): %s", message.Category, message.Level, message.Message.Text)
+			if notification.Subscription.Type == twitchws.EventTypeAutomodMessageUpdate {
+				entry.Action = "automod " + strings.ToLower(message.Status)
+			}
+		}
+	default:
+		return
+	}
+	if err != nil {
+		log.Printf("error recording %s in the moderation log: %s", notification.Subscription.Type, err)
+		return
+	}
+	l.Record(entry)
+}
+
+// Record stores entry and posts it to the moderator channel.
+func (l *ModerationLog) Record(entry ModerationEntry) {
+	if entry.At.IsZero() {
+		entry.At = time.Now()
+	}
+	l.mu.Lock()
+	// Keys sort chronologically, entries in the same nanosecond are moved apart.
+	at := entry.At.UnixNano()
+	for {
+		if found, err := l.store.Get(moderationLogBucket, moderationKey(at), new(ModerationEntry)); err != nil || !found {
+			break
+		}
+		at++
+	}
+	err := l.store.Put(moderationLogBucket, moderationKey(at), entry)
+	l.mu.Unlock()
+	if err != nil {
+		log.Printf("error storing moderation log entry: %s", err)
+	}
+	if l.config.ChannelID != "" {
+		if _, err := l.client.transport.SendEmbed(l.config.ChannelID, moderationEmbed(entry)); err != nil {
+			log.Printf("error posting moderation log entry to %s: %s", l.config.ChannelID, err)
+		}
+	}
+}
+
+// Run prunes the entries past the retention or the entry limit every hour until stop is closed.
+func (l *ModerationLog) Run(stop chan struct{}) {
+	l.prune(time.Now())
+	ticker := time.NewTicker(moderationPruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			l.prune(time.Now())
+		}
+	}
+}
+
+// Search returns the newest entries, at most limit, whose target or moderator contains user, whose
+// action starts with action and that come from source. Empty filters match every entry.
+func (l *ModerationLog) Search(user, action, source string, limit int) []ModerationEntry {
+	user, action = strings.ToLower(user), strings.ToLower(action)
+	keys := l.store.Keys(moderationLogBucket)
+	var entries []ModerationEntry
+	for i := len(keys) - 1; i >= 0 && len(entries) < limit; i-- {
+		var entry ModerationEntry
+		if _, err := l.store.Get(moderationLogBucket, keys[i], &entry); err != nil {
+			continue
+		}
+		if user != "" && !strings.Contains(strings.ToLower(entry.Target), user) && !strings.Contains(strings.ToLower(entry.Moderator), user) {
+			continue
+		}
+		if action != "" && !strings.HasPrefix(entry.Action, action) {
+			continue
+		}
+		if source != "" && entry.Source != source {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func (l *ModerationLog) prune(now time.Time) {
+	cutoff := moderationKey(now.Add(-l.config.Retention).UnixNano())
+	keys := l.store.Keys(moderationLogBucket)
+	for i, key := range keys {
+		if key >= cutoff && len(keys)-i <= l.config.MaxEntries {
+			return
+		}
+		if err := l.store.Delete(moderationLogBucket, key); err != nil {
+			log.Printf("error pruning moderation log entry %s: %s", key, err)
+		}
+	}
+}
+
+// SearchCommand is the /modlog command, which searches the moderation log. It can only be used in
+// the moderator channel and the admin channels.
+func (l *ModerationLog) SearchCommand() Command {
+	choices := func(values ...string) []*discordgo.ApplicationCommandOptionChoice {
+		var c []*discordgo.ApplicationCommandOptionChoice
+		for _, v := range values {
+			c = append(c, &discordgo.ApplicationCommandOptionChoice{Name: v, Value: v})
+		}
+		return c
+	}
+	return Command{
+		Definition: &discordgo.ApplicationCommand{
+			Name:        "modlog",
+			Description: "Search the moderation log of Twitch and Discord",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionString, Name: "user", Description: "Part of the name of the target or moderator"},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "action",
+					Description: "Only show this action",
+					Choices:     choices(ModerationBan, ModerationTimeout, ModerationUnban, ModerationModAdd, ModerationModRemove, "automod"),
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "source",
+					Description: "Only show actions on Twitch or Discord",
+					Choices:     choices(ModerationSourceTwitch, ModerationSourceDiscord),
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "count",
+					Description: fmt.Sprintf("How many entries to show, at most %d", maxModerationResults),
+					MinValue:    floatPtr(1),
+					MaxValue:    maxModerationResults,
+				},
+			},
+		},
+		Ephemeral: true,
+		Handler: func(i *discordgo.InteractionCreate) (string, error) {
+			if i.ChannelID != l.config.ChannelID && !l.client.isAdminChannel(i.ChannelID) {
+				return "", fmt.Errorf("/modlog can only be used in the moderator channel")
+			}
+			entries := l.Search(StringOption(i, "user"), StringOption(i, "action"), StringOption(i, "source"), int(IntOption(i, "count", defaultModerationResults)))
+			if len(entries) == 0 {
+				return "No moderation log entries found.", nil
+			}
+			lines := make([]string, 0, len(entries))
+			for _, entry := range entries {
+				lines = append(lines, moderationLine(entry))
+			}
+			return strings.Join(lines, "\n"), nil
+		},
+	}
+}
+
+func moderationKey(unixNano int64) string {
+	return fmt.Sprintf("%020d", unixNano)
+}
+
+func moderationEmbed(entry ModerationEntry) *discordgo.MessageEmbed {
+	color := 0x9146ff
+	if entry.Source == ModerationSourceDiscord {
+		color = 0x5865f2
+	}
+	fields := []*discordgo.MessageEmbedField{
+		{Name: "Target", Value: moderationName(entry.Target, entry.TargetID), Inline: true},
+	}
+	if entry.Moderator != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Moderator", Value: escapeMarkdown(entry.Moderator), Inline: true})
+	}
+	if entry.Duration > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Duration", Value: entry.Duration.String(), Inline: true})
+	}
+	if entry.Reason != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Reason", Value: truncate(escapeMarkdown(sanitizeMentions(entry.Reason)), 1024)})
+	}
+	return &discordgo.MessageEmbed{
+		Title:     fmt.Sprintf("%s %s", moderationSourceName(entry.Source), entry.Action),
+		Color:     color,
+		Fields:    fields,
+		Timestamp: entry.At.Format(time.RFC3339),
+	}
+}
+
+func moderationLine(entry ModerationEntry) string {
+	line := fmt.Sprintf("<t:%d:f> **%s** %s on %s", entry.At.Unix(), entry.Action, moderationName(entry.Target, entry.TargetID), moderationSourceName(entry.Source))
+	if entry.Moderator != "" {
+		line += " by " + escapeMarkdown(entry.Moderator)
+	}
+	if entry.Duration > 0 {
+		line += fmt.Sprintf(" for %s", entry.Duration)
+	}
+	if entry.Reason != "" {
+		line += " — " + truncate(escapeMarkdown(sanitizeMentions(entry.Reason)), 200)
+	}
+	return line
+}
+
+func moderationName(name, id string) string {
+	if id == "" {
+		return escapeMarkdown(name)
+	}
+	return fmt.Sprintf("%s (`%s`)", escapeMarkdown(name), id)
+}
+
+func moderationSourceName(source string) string {
+	if source == ModerationSourceDiscord {
+		return "Discord"
+	}
+	return "Twitch"
+}
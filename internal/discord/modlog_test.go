@@ -0,0 +1,78 @@
+package discord
+
+import (
+	"testing"
+	"time"
+)
+
+func TestModerationLogSearch(t *testing.T) {
+	client, _, s := newTestClient(t)
+	l := NewModerationLog(client, s, ModerationLogConfig{})
+	at := time.Now().Add(-time.Hour)
+	for i, entry := range []ModerationEntry{
+		{Source: ModerationSourceTwitch, Action: ModerationBan, Moderator: "sensaiopti", Target: "Spammer", TargetID: "1"},
+		{Source: ModerationSourceDiscord, Action: ModerationTimeout, Target: "spammer2", TargetID: "2"},
+		{Source: ModerationSourceTwitch, Action: "automod approved", Moderator: "helper", Target: "viewer", TargetID: "3"},
+		{Source: ModerationSourceTwitch, Action: ModerationUnban, Moderator: "sensaiopti", Target: "spammer", TargetID: "1"},
+	} {
+		entry.At = at.Add(time.Duration(i) * time.Minute)
+		l.Record(entry)
+	}
+	tests := []struct {
+		user, action, source string
+		limit                int
+		want                 []string
+	}{
+		{limit: 10, want: []string{ModerationUnban, "automod approved", ModerationTimeout, ModerationBan}},
+		{limit: 2, want: []string{ModerationUnban, "automod approved"}},
+		{user: "SPAMMER", limit: 10, want: []string{ModerationUnban, ModerationTimeout, ModerationBan}},
+		{user: "helper", limit: 10, want: []string{"automod approved"}},
+		{action: "automod", limit: 10, want: []string{"automod approved"}},
+		{source: ModerationSourceDiscord, limit: 10, want: []string{ModerationTimeout}},
+		{user: "nobody", limit: 10},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, entry := range l.Search(test.user, test.action, test.source, test.limit) {
+			got = append(got, entry.Action)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("Search(%q, %q, %q, %d) returned %v, want %v", test.user, test.action, test.source, test.limit, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("Search(%q, %q, %q, %d) returned %v, want %v", test.user, test.action, test.source, test.limit, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestModerationLogKeepsEntriesInTheSameInstant(t *testing.T) {
+	client, _, s := newTestClient(t)
+	l := NewModerationLog(client, s, ModerationLogConfig{})
+	at := time.Now()
+	l.Record(ModerationEntry{Action: ModerationBan, Target: "a", At: at})
+	l.Record(ModerationEntry{Action: ModerationBan, Target: "b", At: at})
+	if got := l.Search("", "", "", 10); len(got) != 2 || got[0].Target != "b" {
+		t.Fatalf("Search returned %v, want both entries, newest first", got)
+	}
+}
+
+func TestModerationLogPrune(t *testing.T) {
+	client, _, s := newTestClient(t)
+	l := NewModerationLog(client, s, ModerationLogConfig{Retention: 24 * time.Hour, MaxEntries: 2})
+	now := time.Now()
+	for _, age := range []time.Duration{48 * time.Hour, 3 * time.Hour, 2 * time.Hour, time.Hour} {
+		l.Record(ModerationEntry{Action: ModerationBan, Target: age.String(), At: now.Add(-age)})
+	}
+	if got := l.Search("", "", "", 10); len(got) != 4 {
+		t.Fatalf("%d entries were kept before pruning, want every entry", len(got))
+	}
+	l.prune(now)
+	got := l.Search("", "", "", 10)
+	if len(got) != 2 || got[0].Target != "1h0m0s" || got[1].Target != "2h0m0s" {
+		t.Fatalf("Search returned %v after pruning, want the 2 newest entries", got)
+	}
+}
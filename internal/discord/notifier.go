@@ -0,0 +1,383 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Severity is the importance of an admin notification. Admin channels only
+// receive notifications at or above their configured minimum severity.
+type Severity int
+
+const (
+	SeverityDebug Severity = iota
+	SeverityInfo
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityDebug:
+		return "debug"
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+func (s Severity) color() int {
+	switch s {
+	case SeverityDebug:
+		return 0x999999
+	case SeverityInfo:
+		return 0x3399ff
+	case SeverityWarning:
+		return 0xffcc00
+	}
+	return 0xff3333
+}
+
+// ParseSeverity converts a configuration value such as "warning" into a Severity.
+func ParseSeverity(value string) (Severity, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return SeverityDebug, nil
+	case "info", "":
+		return SeverityInfo, nil
+	case "warn", "warning":
+		return SeverityWarning, nil
+	case "error":
+		return SeverityError, nil
+	}
+	return SeverityInfo, fmt.Errorf("unknown severity %q", value)
+}
+
+// ParseChannelSeverities parses a list of channelID=severity pairs, e.g.
+// "1234=warning,5678=debug", into a map of minimum severities per channel.
+func ParseChannelSeverities(value string) (map[string]Severity, error) {
+	levels := make(map[string]Severity)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		channelID, level, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid channel severity %q, expected channelID=severity", pair)
+		}
+		severity, err := ParseSeverity(level)
+		if err != nil {
+			return nil, fmt.Errorf("invalid channel severity for %s: %w", channelID, err)
+		}
+		levels[strings.TrimSpace(channelID)] = severity
+	}
+	return levels, nil
+}
+
+type NotifierConfig struct {
+	// DefaultLevel is the minimum severity for admin channels without an entry in ChannelLevels.
+	DefaultLevel Severity
+	// ChannelLevels overrides the minimum severity per admin channel ID.
+	ChannelLevels map[string]Severity
+	// DedupWindow is how long repeated notifications with the same title are collapsed into one.
+	DedupWindow time.Duration
+	// MaxPerMinute caps how many messages are posted to each admin channel per minute.
+	MaxPerMinute int
+	// DailyDigest collects info and debug notifications into one summary posted at DigestHour (UTC)
+	// instead of posting them as they happen.
+	DailyDigest bool
+	DigestHour  int
+}
+
+// Notice is a single admin notification.
+type Notice struct {
+	Severity    Severity
+	Title       string
+	Description string
+	Fields      []*discordgo.MessageEmbedField
+	// digest is set for the daily digest, which is posted to every admin channel whatever its
+	// minimum severity, it summarizes the notices held back from all of them.
+	digest bool
+}
+
+type pendingNotice struct {
+	notice    Notice
+	count     int
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// AdminNotifier posts notifications to the admin channels of a Client, filtering by severity,
+// collapsing repeated notifications and rate limiting each channel.
+type AdminNotifier struct {
+	client *Client
+	config NotifierConfig
+
+	mu         sync.Mutex
+	pending    map[string]*pendingNotice
+	digest     map[string]*pendingNotice
+	sent       map[string][]time.Time
+	suppressed map[string]int
+	lastDigest time.Time
+}
+
+func NewAdminNotifier(client *Client, config NotifierConfig) *AdminNotifier {
+	if config.DedupWindow <= 0 {
+		config.DedupWindow = 5 * time.Minute
+	}
+	if config.MaxPerMinute <= 0 {
+		config.MaxPerMinute = 10
+	}
+	return &AdminNotifier{
+		client:     client,
+		config:     config,
+		pending:    make(map[string]*pendingNotice),
+		digest:     make(map[string]*pendingNotice),
+		sent:       make(map[string][]time.Time),
+		suppressed: make(map[string]int),
+		lastDigest: time.Now().UTC(),
+	}
+}
+
+// Run flushes collapsed notifications and the daily digest until stop is closed.
+func (n *AdminNotifier) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			n.flush(now.UTC())
+		}
+	}
+}
+
+func (n *AdminNotifier) Debug(title, description string) {
+	n.Notify(Notice{Severity: SeverityDebug, Title: title, Description: description})
+}
+
+func (n *AdminNotifier) Info(title, description string) {
+	n.Notify(Notice{Severity: SeverityInfo, Title: title, Description: description})
+}
+
+func (n *AdminNotifier) Warning(title, description string) {
+	n.Notify(Notice{Severity: SeverityWarning, Title: title, Description: description})
+}
+
+func (n *AdminNotifier) Error(title string, err error) {
+	n.Notify(Notice{Severity: SeverityError, Title: title, Description: err.Error()})
+}
+
+// Notify posts a notice to every admin channel whose minimum severity allows it. A notice with the
+// same severity, title and first description line as one posted within the dedup window is counted
+// instead of posted, and the count is reported once the window has passed.
+func (n *AdminNotifier) Notify(notice Notice) {
+	log.Printf("admin %s: %s: %s", notice.Severity, notice.Title, notice.Description)
+	now := time.Now().UTC()
+	key := noticeKey(notice)
+
+	n.mu.Lock()
+	if n.config.DailyDigest && notice.Severity < SeverityWarning {
+		addPending(n.digest, key, notice, now)
+		n.mu.Unlock()
+		return
+	}
+	p, ok := n.pending[key]
+	if ok && now.Sub(p.firstSeen) < n.config.DedupWindow {
+		p.count++
+		p.lastSeen = now
+		n.mu.Unlock()
+		return
+	}
+	n.pending[key] = &pendingNotice{notice: notice, count: 1, firstSeen: now, lastSeen: now}
+	n.mu.Unlock()
+
+	// The window of the previous notice passed before flush reported its repeats.
+	if ok && p.count > 1 {
+		n.send(repeatNotice(p), now)
+	}
+	n.send(notice, now)
+}
+
+func (n *AdminNotifier) flush(now time.Time) {
+	var repeats []Notice
+	n.mu.Lock()
+	for key, p := range n.pending {
+		if now.Sub(p.firstSeen) < n.config.DedupWindow {
+			continue
+		}
+		delete(n.pending, key)
+		if p.count > 1 {
+			repeats = append(repeats, repeatNotice(p))
+		}
+	}
+	var digest *Notice
+	if n.config.DailyDigest && now.Hour() == n.config.DigestHour && now.Sub(n.lastDigest) > time.Hour {
+		n.lastDigest = now
+		digest = digestNotice(n.digest)
+		n.digest = make(map[string]*pendingNotice)
+	}
+	n.mu.Unlock()
+
+	for _, notice := range repeats {
+		n.send(notice, now)
+	}
+	if digest != nil {
+		n.send(*digest, now)
+	}
+}
+
+// repeatNotice reports how often the notice of p was repeated after it was posted.
+func repeatNotice(p *pendingNotice) Notice {
+	repeat := p.notice
+	repeat.Title = fmt.Sprintf("%s (repeated %d more times)", p.notice.Title, p.count-1)
+	repeat.Fields = append(append([]*discordgo.MessageEmbedField(nil), repeat.Fields...), &discordgo.MessageEmbedField{
+		Name:   "Last Seen",
+		Value:  p.lastSeen.Format(time.RFC1123),
+		Inline: true,
+	})
+	return repeat
+}
+
+func (n *AdminNotifier) send(notice Notice, now time.Time) {
+	for _, channelID := range n.client.adminChannelIDs {
+		if channelID == "" || (!notice.digest && notice.Severity < n.minimumLevel(channelID)) {
+			continue
+		}
+		if !n.allow(channelID, now) {
+			continue
+		}
+		if _, err := n.client.transport.SendEmbed(channelID, n.embed(channelID, notice)); err != nil {
+			log.Printf("error sending admin notification to %s: %s", channelID, err)
+		}
+	}
+}
+
+func (n *AdminNotifier) minimumLevel(channelID string) Severity {
+	if level, ok := n.config.ChannelLevels[channelID]; ok {
+		return level
+	}
+	return n.config.DefaultLevel
+}
+
+// allow reports whether channelID is under its per-minute message limit, recording the send if so.
+func (n *AdminNotifier) allow(channelID string, now time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var recent []time.Time
+	for _, t := range n.sent[channelID] {
+		if now.Sub(t) < time.Minute {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= n.config.MaxPerMinute {
+		n.sent[channelID] = recent
+		n.suppressed[channelID]++
+		return false
+	}
+	n.sent[channelID] = append(recent, now)
+	return true
+}
+
+func (n *AdminNotifier) embed(channelID string, notice Notice) *discordgo.MessageEmbed {
+	footer := fmt.Sprintf("jagger • %s", notice.Severity)
+	n.mu.Lock()
+	if suppressed := n.suppressed[channelID]; suppressed > 0 {
+		footer = fmt.Sprintf("%s • %d notifications dropped by rate limit", footer, suppressed)
+		n.suppressed[channelID] = 0
+	}
+	n.mu.Unlock()
+	return &discordgo.MessageEmbed{
+		Title:       truncate(notice.Title, 256),
+		Description: truncate(notice.Description, 4096),
+		Color:       notice.Severity.color(),
+		Timestamp:   time.Now().Format(time.RFC3339),
+		Fields:      notice.Fields,
+		Footer:      &discordgo.MessageEmbedFooter{Text: footer},
+	}
+}
+
+// EventNotice formats a Twitch EventSub notification as an info notice with one field per populated value.
+func EventNotice(notification twitchws.Notification) Notice {
+	event := notification.Event
+	var fields []*discordgo.MessageEmbedField
+	for _, f := range []struct{ name, value string }{
+		{"User", event.Username},
+		{"User ID", event.UserID},
+		{"Broadcaster", event.BroadcastUsername},
+		{"Broadcaster ID", event.BroadcastUserID},
+		{"Followed At", event.FollowedAt},
+	} {
+		if f.value == "" {
+			continue
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{Name: f.name, Value: f.value, Inline: true})
+	}
+	return Notice{
+		Severity: SeverityInfo,
+		Title:    fmt.Sprintf("Received Twitch %s event", notification.Subscription.Type),
+		Fields:   fields,
+	}
+}
+
+func addPending(notices map[string]*pendingNotice, key string, notice Notice, now time.Time) {
+	if p, ok := notices[key]; ok {
+		p.count++
+		p.lastSeen = now
+		return
+	}
+	notices[key] = &pendingNotice{notice: notice, count: 1, firstSeen: now, lastSeen: now}
+}
+
+func digestNotice(notices map[string]*pendingNotice) *Notice {
+	if len(notices) == 0 {
+		return nil
+	}
+	var lines []string
+	for _, p := range notices {
+		line := fmt.Sprintf("**%s** — %s", p.notice.Title, firstLine(p.notice.Description))
+		if p.count > 1 {
+			line = fmt.Sprintf("%s (x%d)", line, p.count)
+		}
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	return &Notice{
+		Severity:    SeverityInfo,
+		Title:       "Daily digest",
+		digest:      true,
+		Description: strings.Join(lines, "\n"),
+	}
+}
+
+func noticeKey(notice Notice) string {
+	return fmt.Sprintf("%d|%s|%s", notice.Severity, notice.Title, firstLine(notice.Description))
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// truncate shortens s to max characters. Discord counts its limits in characters, and cutting by
+// bytes could split a character and leave invalid UTF-8.
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max-3]) + "..."
+}
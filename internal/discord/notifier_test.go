@@ -0,0 +1,101 @@
+package discord
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func adminTitles(recorder *Recorder, channelID string) []string {
+	var titles []string
+	for _, m := range recorder.Messages(channelID) {
+		titles = append(titles, m.Embeds[0].Title)
+	}
+	return titles
+}
+
+func TestNotifierCollapsesRepeats(t *testing.T) {
+	client, recorder, _ := newTestClient(t)
+	n := NewAdminNotifier(client, NotifierConfig{DedupWindow: time.Minute})
+	for i := 0; i < 3; i++ {
+		n.Warning("Twitch is down", "503")
+	}
+	if got := adminTitles(recorder, testAdminChannelID); len(got) != 1 {
+		t.Fatalf("posted %v, want the notice once", got)
+	}
+	n.flush(time.Now().UTC().Add(2 * time.Minute))
+	got := adminTitles(recorder, testAdminChannelID)
+	if len(got) != 2 || got[1] != "Twitch is down (repeated 2 more times)" {
+		t.Fatalf("posted %v after the window, want the repeat count", got)
+	}
+}
+
+func TestNotifierReportsRepeatsBeforeFlush(t *testing.T) {
+	client, recorder, _ := newTestClient(t)
+	n := NewAdminNotifier(client, NotifierConfig{DedupWindow: 10 * time.Millisecond})
+	n.Warning("Twitch is down", "503")
+	n.Warning("Twitch is down", "503")
+	time.Sleep(20 * time.Millisecond)
+	n.Warning("Twitch is down", "503")
+	want := []string{"Twitch is down", "Twitch is down (repeated 1 more times)", "Twitch is down"}
+	if got := adminTitles(recorder, testAdminChannelID); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("posted %v, want %v", got, want)
+	}
+}
+
+func TestNotifierRateLimit(t *testing.T) {
+	client, recorder, _ := newTestClient(t)
+	n := NewAdminNotifier(client, NotifierConfig{MaxPerMinute: 2})
+	for _, title := range []string{"one", "two", "three"} {
+		n.Warning(title, "")
+	}
+	if got := adminTitles(recorder, testAdminChannelID); len(got) != 2 {
+		t.Fatalf("posted %v, want 2 within the limit", got)
+	}
+	n.send(Notice{Severity: SeverityWarning, Title: "four"}, time.Now().Add(2*time.Minute))
+	messages := recorder.Messages(testAdminChannelID)
+	if footer := messages[len(messages)-1].Embeds[0].Footer.Text; !strings.Contains(footer, "1 notifications dropped") {
+		t.Fatalf("footer is %q, want the dropped count", footer)
+	}
+}
+
+func TestNotifierFiltersSeverity(t *testing.T) {
+	client, recorder, _ := newTestClient(t)
+	n := NewAdminNotifier(client, NotifierConfig{ChannelLevels: map[string]Severity{testAdminChannelID: SeverityError}})
+	n.Info("Started", "")
+	n.Warning("Slow", "")
+	if got := adminTitles(recorder, testAdminChannelID); len(got) != 0 {
+		t.Fatalf("posted %v to an error channel", got)
+	}
+}
+
+func TestNotifierDigestReachesEveryChannel(t *testing.T) {
+	client, recorder, _ := newTestClient(t)
+	n := NewAdminNotifier(client, NotifierConfig{
+		DailyDigest:   true,
+		DigestHour:    9,
+		ChannelLevels: map[string]Severity{testAdminChannelID: SeverityError},
+	})
+	n.Info("Followed", "")
+	n.Info("Followed", "")
+	n.flush(time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC))
+	messages := recorder.Messages(testAdminChannelID)
+	if len(messages) != 1 {
+		t.Fatalf("posted %d messages, want the digest", len(messages))
+	}
+	embed := messages[0].Embeds[0]
+	if embed.Title != "Daily digest" || !strings.Contains(embed.Description, "(x2)") || embed.Color != SeverityInfo.color() {
+		t.Fatalf("digest is %q %q with color %x, want an info digest counting the follows", embed.Title, embed.Description, embed.Color)
+	}
+}
+
+func TestTruncateKeepsCharacters(t *testing.T) {
+	if got := truncate("short", 10); got != "short" {
+		t.Fatalf("truncate changed a short string to %q", got)
+	}
+	got := truncate(strings.Repeat("é", 10), 5)
+	if got != "éé..." || !utf8.ValidString(got) {
+		t.Fatalf("truncate returned %q, want 5 valid characters", got)
+	}
+}
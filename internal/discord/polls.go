@@ -0,0 +1,173 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	pollMessagesBucket = "poll_messages"
+	pollBarWidth       = 20
+)
+
+type PollConfig struct {
+	// ChannelIDs are where polls and predictions are mirrored, the announcement channels if empty.
+	ChannelIDs []string
+}
+
+// PollMirror mirrors Twitch polls and predictions into Discord as an embed that is edited as votes
+// come in and finalized with the result.
+type PollMirror struct {
+	client *Client
+	store  *store.Store
+	config PollConfig
+}
+
+func NewPollMirror(client *Client, s *store.Store, config PollConfig) *PollMirror {
+	if len(config.ChannelIDs) == 0 {
+		config.ChannelIDs = client.channelIDs
+	}
+	return &PollMirror{client: client, store: s, config: config}
+}
+
+// Handle mirrors notification if it is a poll or prediction notification.
+func (m *PollMirror) Handle(notification twitchws.Notification) {
+	var err error
+	switch notification.Subscription.Type {
+	case twitchws.EventTypeChannelPollBegin, twitchws.EventTypeChannelPollProgress, twitchws.EventTypeChannelPollEnd:
+		var poll twitchws.PollEvent
+		if err = notification.DecodeEvent(&poll); err == nil {
+			ended := notification.Subscription.Type == twitchws.EventTypeChannelPollEnd
+			err = m.mirror(poll.ID, pollEmbed(poll, ended), ended)
+		}
+	case twitchws.EventTypeChannelPredictionBegin, twitchws.EventTypeChannelPredictionProgress,
+		twitchws.EventTypeChannelPredictionLock, twitchws.EventTypeChannelPredictionEnd:
+		var prediction twitchws.PredictionEvent
+		if err = notification.DecodeEvent(&prediction); err == nil {
+			ended := notification.Subscription.Type == twitchws.EventTypeChannelPredictionEnd
+			locked := notification.Subscription.Type == twitchws.EventTypeChannelPredictionLock
+			err = m.mirror(prediction.ID, predictionEmbed(prediction, locked, ended), ended)
+		}
+	default:
+		return
+	}
+	if err != nil {
+		log.Printf("error mirroring %s: %s", notification.Subscription.Type, err)
+	}
+	forgetMirroredMessages(m.store, pollMessagesBucket, time.Now())
+}
+
+func (m *PollMirror) mirror(id string, embed *discordgo.MessageEmbed, ended bool) error {
+	return m.client.mirrorEmbed(m.store, pollMessagesBucket, id, m.config.ChannelIDs, "", "", embed, ended)
+}
+
+func pollEmbed(poll twitchws.PollEvent, ended bool) *discordgo.MessageEmbed {
+	total, most := 0, 0
+	for _, choice := range poll.Choices {
+		total += choice.Votes
+		if choice.Votes > most {
+			most = choice.Votes
+		}
+	}
+	var lines []string
+	for _, choice := range poll.Choices {
+		name := escapeMarkdown(choice.Title)
+		if ended && most > 0 && choice.Votes == most {
+			name = "🏆 " + name
+		}
+		lines = append(lines, fmt.Sprintf("**%s** — %d%% (%s)\n%s", name, percent(choice.Votes, total), plural(choice.Votes, "vote"), bar(choice.Votes, total)))
+	}
+	footer := "Vote in Twitch chat"
+	switch {
+	case ended && poll.Status == "terminated":
+		footer = "The poll was ended early"
+	case ended:
+		footer = "The poll has ended"
+	case poll.EndsAt != "":
+		if endsAt, err := time.Parse(time.RFC3339, poll.EndsAt); err == nil {
+			lines = append(lines, fmt.Sprintf("Voting ends <t:%d:R>", endsAt.Unix()))
+		}
+	}
+	return &discordgo.MessageEmbed{
+		Title:       truncate("📊 "+poll.Title, 256),
+		URL:         twitchChannelURL,
+		Description: truncate(strings.Join(lines, "\n\n"), 4096),
+		Color:       0x9146ff,
+		Footer:      &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("%s · %s", footer, plural(total, "vote"))},
+	}
+}
+
+func predictionEmbed(prediction twitchws.PredictionEvent, locked, ended bool) *discordgo.MessageEmbed {
+	total := 0
+	for _, outcome := range prediction.Outcomes {
+		total += outcome.ChannelPoints
+	}
+	color := 0x9146ff
+	var lines []string
+	for _, outcome := range prediction.Outcomes {
+		name := escapeMarkdown(outcome.Title)
+		if ended && outcome.ID == prediction.WinningOutcomeID {
+			name = "🏆 " + name
+			color = outcomeColor(outcome.Color)
+		}
+		lines = append(lines, fmt.Sprintf("**%s** — %d%% (%s, %s)\n%s", name, percent(outcome.ChannelPoints, total),
+			plural(outcome.ChannelPoints, "point"), plural(outcome.Users, "predictor"), bar(outcome.ChannelPoints, total)))
+	}
+	footer := "Predict in Twitch chat"
+	switch {
+	case ended && prediction.Status == "canceled":
+		footer = "The prediction was canceled and points were refunded"
+	case ended:
+		footer = "The prediction has ended"
+	case locked:
+		footer = "🔒 Predictions are locked"
+	case prediction.LocksAt != "":
+		if locksAt, err := time.Parse(time.RFC3339, prediction.LocksAt); err == nil {
+			lines = append(lines, fmt.Sprintf("Predictions lock <t:%d:R>", locksAt.Unix()))
+		}
+	}
+	return &discordgo.MessageEmbed{
+		Title:       truncate("🔮 "+prediction.Title, 256),
+		URL:         twitchChannelURL,
+		Description: truncate(strings.Join(lines, "\n\n"), 4096),
+		Color:       color,
+		Footer:      &discordgo.MessageEmbedFooter{Text: footer},
+	}
+}
+
+func outcomeColor(color string) int {
+	if color == "pink" {
+		return 0xf5009b
+	}
+	return 0x387aff
+}
+
+// bar draws count out of total as a bar of pollBarWidth blocks.
+func bar(count, total int) string {
+	filled := 0
+	if total > 0 {
+		filled = count * pollBarWidth / total
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", pollBarWidth-filled)
+}
+
+func percent(count, total int) int {
+	if total == 0 {
+		return 0
+	}
+	return count * 100 / total
+}
+
+// plural formats count with noun, e.g. "1 vote" or "12 votes".
+func plural(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
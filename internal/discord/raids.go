@@ -0,0 +1,99 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+	"github.com/bwmarrin/discordgo"
+)
+
+// defaultRaidThankYou is used when RaidConfig.ThankYouFormat is empty. {raider} and {viewers} are
+// replaced with the raider's display name and viewer count.
+const defaultRaidThankYou = "Thank you for the raid @{raider}! Welcome to all {viewers} of you <3"
+
+type RaidConfig struct {
+	// ChannelIDs are where raids are announced, the announcement channels if empty.
+	ChannelIDs []string
+	// MinViewers is the least viewers an incoming raid needs to be announced and thanked for.
+	MinViewers int
+	// ThankYou thanks incoming raiders in Twitch chat.
+	ThankYou bool
+	// ThankYouFormat is the Twitch chat message template, see defaultRaidThankYou.
+	ThankYouFormat string
+}
+
+// RaidAnnouncer announces raids into and out of the broadcaster's channel.
+type RaidAnnouncer struct {
+	client *Client
+	chat   ChatSender
+	config RaidConfig
+}
+
+// NewRaidAnnouncer creates a RaidAnnouncer. chat is used for thank-you messages and may be nil.
+func NewRaidAnnouncer(client *Client, chat ChatSender, config RaidConfig) *RaidAnnouncer {
+	if len(config.ChannelIDs) == 0 {
+		config.ChannelIDs = client.channelIDs
+	}
+	if config.ThankYouFormat == "" {
+		config.ThankYouFormat = defaultRaidThankYou
+	}
+	return &RaidAnnouncer{client: client, chat: chat, config: config}
+}
+
+// Handle announces raid, which is incoming if it is to broadcasterID and outgoing otherwise.
+func (r *RaidAnnouncer) Handle(raid twitchws.RaidEvent, broadcasterID string) {
+	if raid.ToBroadcasterUserID == broadcasterID {
+		r.incoming(raid)
+		return
+	}
+	r.outgoing(raid)
+}
+
+func (r *RaidAnnouncer) incoming(raid twitchws.RaidEvent) {
+	if raid.Viewers < r.config.MinViewers {
+		log.Printf("not announcing raid by %s with %d viewers, below the minimum of %d", raid.FromBroadcasterUserLogin, raid.Viewers, r.config.MinViewers)
+		return
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       "🚨 Incoming raid!",
+		URL:         fmt.Sprintf("https://twitch.tv/%s", raid.FromBroadcasterUserLogin),
+		Description: fmt.Sprintf("**%s** is raiding with **%d** viewers!", escapeMarkdown(raid.FromBroadcasterUserName), raid.Viewers),
+		Color:       0x9146ff,
+	}
+	if raider, err := twitchws.GetUser(raid.FromBroadcasterUserID); err != nil {
+		log.Printf("error getting raider %s, announcing without profile image: %s", raid.FromBroadcasterUserLogin, err)
+	} else if raider != nil && raider.ProfileImageURL != "" {
+		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: raider.ProfileImageURL}
+	}
+	r.send(embed)
+	if r.chat != nil && r.config.ThankYou {
+		r.chat.Say(strings.NewReplacer(
+			"{raider}", raid.FromBroadcasterUserName,
+			"{viewers}", strconv.Itoa(raid.Viewers),
+		).Replace(r.config.ThankYouFormat))
+	}
+}
+
+func (r *RaidAnnouncer) outgoing(raid twitchws.RaidEvent) {
+	targetURL := fmt.Sprintf("https://twitch.tv/%s", raid.ToBroadcasterUserLogin)
+	r.send(&discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("🚀 Raiding %s", raid.ToBroadcasterUserName),
+		URL:         targetURL,
+		Description: fmt.Sprintf("We raided **%s** with **%d** viewers, come say hi!\n%s", escapeMarkdown(raid.ToBroadcasterUserName), raid.Viewers, targetURL),
+		Color:       0x9146ff,
+	})
+}
+
+func (r *RaidAnnouncer) send(embed *discordgo.MessageEmbed) {
+	for _, channelID := range r.config.ChannelIDs {
+		if channelID == "" {
+			continue
+		}
+		if _, err := r.client.transport.SendEmbed(channelID, embed); err != nil {
+			log.Printf("error sending raid announcement to %s: %s", channelID, err)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package discord
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// RecordedReaction is a reaction added through a Recorder.
+type RecordedReaction struct {
+	ChannelID string
+	MessageID string
+	Emoji     string
+}
+
+// Recorder is an in-memory Transport that records everything sent through it, for tests.
+type Recorder struct {
+	mu        sync.Mutex
+	nextID    int
+	messages  []*discordgo.Message
+	reactions []RecordedReaction
+	commands  map[string][]*discordgo.ApplicationCommand
+	emojis    map[string][]*discordgo.Emoji
+	events    []*discordgo.GuildScheduledEvent
+	// roles are the role IDs of each member by guild and user ID.
+	roles map[string]map[string][]string
+	// bans are the ban reasons of each banned user by guild and user ID.
+	bans map[string]map[string]string
+	// channelNames are the names channels were renamed to by channel ID.
+	channelNames map[string]string
+	presence     *discordgo.Activity
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		commands:     make(map[string][]*discordgo.ApplicationCommand),
+		emojis:       make(map[string][]*discordgo.Emoji),
+		roles:        make(map[string]map[string][]string),
+		bans:         make(map[string]map[string]string),
+		channelNames: make(map[string]string),
+	}
+}
+
+func (r *Recorder) SendMessage(channelID, content string) (*discordgo.Message, error) {
+	return r.SendComplex(channelID, &discordgo.MessageSend{Content: content})
+}
+
+func (r *Recorder) SendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return r.SendComplex(channelID, &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}})
+}
+
+func (r *Recorder) SendComplex(channelID string, message *discordgo.MessageSend) (*discordgo.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	embeds := message.Embeds
+	if message.Embed != nil {
+		embeds = append([]*discordgo.MessageEmbed{message.Embed}, embeds...)
+	}
+	m := &discordgo.Message{
+		ID:         strconv.Itoa(r.nextID),
+		ChannelID:  channelID,
+		Content:    message.Content,
+		Embeds:     embeds,
+		Components: message.Components,
+	}
+	r.messages = append(r.messages, m)
+	return copyMessage(m), nil
+}
+
+func (r *Recorder) EditMessage(edit *discordgo.MessageEdit) (*discordgo.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range r.messages {
+		if m.ID != edit.ID || m.ChannelID != edit.Channel {
+			continue
+		}
+		if edit.Content != nil {
+			m.Content = *edit.Content
+		}
+		if edit.Embeds != nil {
+			m.Embeds = edit.Embeds
+		}
+		if edit.Components != nil {
+			m.Components = edit.Components
+		}
+		return copyMessage(m), nil
+	}
+	return nil, notFound(fmt.Sprintf("unknown message %s in channel %s", edit.ID, edit.Channel))
+}
+
+func (r *Recorder) React(channelID, messageID, emoji string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reactions = append(r.reactions, RecordedReaction{ChannelID: channelID, MessageID: messageID, Emoji: emoji})
+	return nil
+}
+
+func (r *Recorder) GuildEmojis(guildID string) ([]*discordgo.Emoji, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	emojis := make([]*discordgo.Emoji, 0, len(r.emojis[guildID]))
+	for _, emoji := range r.emojis[guildID] {
+		c := *emoji
+		emojis = append(emojis, &c)
+	}
+	return emojis, nil
+}
+
+func (r *Recorder) ScheduledEvents(guildID string) ([]*discordgo.GuildScheduledEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []*discordgo.GuildScheduledEvent
+	for _, event := range r.events {
+		if event.GuildID == guildID {
+			events = append(events, copyScheduledEvent(event))
+		}
+	}
+	return events, nil
+}
+
+func (r *Recorder) CreateScheduledEvent(guildID string, params *discordgo.GuildScheduledEventParams) (*discordgo.GuildScheduledEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	event := &discordgo.GuildScheduledEvent{
+		ID:         strconv.Itoa(r.nextID),
+		GuildID:    guildID,
+		Status:     discordgo.GuildScheduledEventStatusScheduled,
+		EntityType: params.EntityType,
+	}
+	applyScheduledEventParams(event, params)
+	r.events = append(r.events, event)
+	return copyScheduledEvent(event), nil
+}
+
+func (r *Recorder) EditScheduledEvent(guildID, eventID string, params *discordgo.GuildScheduledEventParams) (*discordgo.GuildScheduledEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, event := range r.events {
+		if event.GuildID == guildID && event.ID == eventID {
+			applyScheduledEventParams(event, params)
+			return copyScheduledEvent(event), nil
+		}
+	}
+	return nil, notFound(fmt.Sprintf("unknown scheduled event %s", eventID))
+}
+
+func (r *Recorder) DeleteScheduledEvent(guildID, eventID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, event := range r.events {
+		if event.GuildID == guildID && event.ID == eventID {
+			r.events = append(r.events[:i], r.events[i+1:]...)
+			return nil
+		}
+	}
+	return notFound(fmt.Sprintf("unknown scheduled event %s", eventID))
+}
+
+// notFound returns the error Discord responds with for something that does not exist.
+func notFound(message string) error {
+	return &discordgo.RESTError{
+		Response:     &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found"},
+		ResponseBody: []byte(message),
+		Message:      &discordgo.APIErrorMessage{Message: message},
+	}
+}
+
+func copyScheduledEvent(event *discordgo.GuildScheduledEvent) *discordgo.GuildScheduledEvent {
+	c := *event
+	if event.ScheduledEndTime != nil {
+		end := *event.ScheduledEndTime
+		c.ScheduledEndTime = &end
+	}
+	return &c
+}
+
+func applyScheduledEventParams(event *discordgo.GuildScheduledEvent, params *discordgo.GuildScheduledEventParams) {
+	if params.Name != "" {
+		event.Name = params.Name
+	}
+	if params.Description != "" {
+		event.Description = params.Description
+	}
+	if params.ScheduledStartTime != nil {
+		event.ScheduledStartTime = *params.ScheduledStartTime
+	}
+	if params.ScheduledEndTime != nil {
+		event.ScheduledEndTime = params.ScheduledEndTime
+	}
+	if params.Status != 0 {
+		event.Status = params.Status
+	}
+	if params.EntityMetadata != nil {
+		event.EntityMetadata = *params.EntityMetadata
+	}
+}
+
+func (r *Recorder) RegisterCommands(guildID string, commands []*discordgo.ApplicationCommand) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands[guildID] = commands
+	return nil
+}
+
+func (r *Recorder) AddRole(guildID, userID, roleID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.roles[guildID] == nil {
+		r.roles[guildID] = make(map[string][]string)
+	}
+	for _, id := range r.roles[guildID][userID] {
+		if id == roleID {
+			return nil
+		}
+	}
+	r.roles[guildID][userID] = append(r.roles[guildID][userID], roleID)
+	return nil
+}
+
+func (r *Recorder) RemoveRole(guildID, userID, roleID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	roles := r.roles[guildID][userID]
+	for i, id := range roles {
+		if id == roleID {
+			r.roles[guildID][userID] = append(roles[:i:i], roles[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r *Recorder) BanMember(guildID, userID, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.bans[guildID] == nil {
+		r.bans[guildID] = make(map[string]string)
+	}
+	r.bans[guildID][userID] = reason
+	return nil
+}
+
+// Channel returns a channel with the name it was last renamed to, channels that were never renamed
+// are not found.
+func (r *Recorder) Channel(channelID string) (*discordgo.Channel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name, ok := r.channelNames[channelID]
+	if !ok {
+		return nil, notFound(fmt.Sprintf("channel %s not found", channelID))
+	}
+	return &discordgo.Channel{ID: channelID, Name: name}, nil
+}
+
+func (r *Recorder) RenameChannel(channelID, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.channelNames[channelID] = name
+	return nil
+}
+
+func (r *Recorder) UpdatePresence(activity *discordgo.Activity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.presence = activity
+	return nil
+}
+
+// ChannelName returns the name channelID was last renamed to, or "" if it was never renamed.
+func (r *Recorder) ChannelName(channelID string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.channelNames[channelID]
+}
+
+// Presence returns the activity of the bot, nil if it has none.
+func (r *Recorder) Presence() *discordgo.Activity {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.presence
+}
+
+// Bans returns the ban reasons of the users banned from guildID by user ID.
+func (r *Recorder) Bans(guildID string) map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	bans := make(map[string]string, len(r.bans[guildID]))
+	for userID, reason := range r.bans[guildID] {
+		bans[userID] = reason
+	}
+	return bans
+}
+
+// MemberRoles returns the role IDs of userID in guildID.
+func (r *Recorder) MemberRoles(guildID, userID string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.roles[guildID][userID]...)
+}
+
+// SetGuildEmojis sets the emojis GuildEmojis returns for guildID.
+func (r *Recorder) SetGuildEmojis(guildID string, emojis []*discordgo.Emoji) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.emojis[guildID] = emojis
+}
+
+// Messages returns copies of the messages sent to channelID, oldest first, or to every channel if
+// channelID is empty, as they are after all edits so far.
+func (r *Recorder) Messages(channelID string) []*discordgo.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var messages []*discordgo.Message
+	for _, m := range r.messages {
+		if channelID == "" || m.ChannelID == channelID {
+			messages = append(messages, copyMessage(m))
+		}
+	}
+	return messages
+}
+
+// copyMessage copies m so later edits do not change the copy.
+func copyMessage(m *discordgo.Message) *discordgo.Message {
+	c := *m
+	c.Embeds = append([]*discordgo.MessageEmbed(nil), m.Embeds...)
+	c.Components = append([]discordgo.MessageComponent(nil), m.Components...)
+	return &c
+}
+
+// Reactions returns every reaction added, oldest first.
+func (r *Recorder) Reactions() []RecordedReaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedReaction(nil), r.reactions...)
+}
+
+// Commands returns the slash commands registered for guildID.
+func (r *Recorder) Commands(guildID string) []*discordgo.ApplicationCommand {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	commands := make([]*discordgo.ApplicationCommand, 0, len(r.commands[guildID]))
+	for _, command := range r.commands[guildID] {
+		c := *command
+		c.Options = append([]*discordgo.ApplicationCommandOption(nil), command.Options...)
+		commands = append(commands, &c)
+	}
+	return commands
+}
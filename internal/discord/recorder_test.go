@@ -0,0 +1,105 @@
+package discord
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	testGuildID        = "guild"
+	testChannelID      = "announcements"
+	testAdminChannelID = "admin"
+)
+
+// newTestClient returns a Client talking to a Recorder and an empty store.
+func newTestClient(t *testing.T) (*Client, *Recorder, *store.Store) {
+	t.Helper()
+	recorder := NewRecorder()
+	client, err := NewClient(&Config{
+		Transport:         recorder,
+		DiscordGuildID:    testGuildID,
+		DiscordChannelIDs: []string{testChannelID},
+		AdminChannelIDs:   []string{testAdminChannelID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.Open(filepath.Join(t.TempDir(), "jagger.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, recorder, s
+}
+
+// eventually fails t if condition is not true within a second.
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition was not met within a second")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRecorderMessagesAreCopies(t *testing.T) {
+	r := NewRecorder()
+	sent, err := r.SendMessage(testChannelID, "before")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := r.Messages(testChannelID)
+	content := "after"
+	if _, err := r.EditMessage(&discordgo.MessageEdit{ID: sent.ID, Channel: testChannelID, Content: &content}); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Content != "before" || messages[0].Content != "before" {
+		t.Fatalf("edit changed returned messages to %q and %q", sent.Content, messages[0].Content)
+	}
+	if got := r.Messages(testChannelID)[0].Content; got != "after" {
+		t.Fatalf("message content is %q after the edit, want %q", got, "after")
+	}
+}
+
+func TestRecorderScheduledEventsAreCopies(t *testing.T) {
+	r := NewRecorder()
+	created, err := r.CreateScheduledEvent(testGuildID, &discordgo.GuildScheduledEventParams{Name: "before"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed, err := r.ScheduledEvents(testGuildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited, err := r.EditScheduledEvent(testGuildID, created.ID, &discordgo.GuildScheduledEventParams{Name: "after"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited.Name = "changed by the caller"
+	if created.Name != "before" || listed[0].Name != "before" {
+		t.Fatalf("edit changed returned events to %q and %q", created.Name, listed[0].Name)
+	}
+	if events, _ := r.ScheduledEvents(testGuildID); events[0].Name != "after" {
+		t.Fatalf("event name is %q after the edit, want %q", events[0].Name, "after")
+	}
+}
+
+func TestRecorderCommandsAndEmojisAreCopies(t *testing.T) {
+	r := NewRecorder()
+	r.RegisterCommands(testGuildID, []*discordgo.ApplicationCommand{{Name: "clip"}})
+	r.SetGuildEmojis(testGuildID, []*discordgo.Emoji{{Name: "Kappa"}})
+	r.Commands(testGuildID)[0].Name = "changed"
+	emojis, _ := r.GuildEmojis(testGuildID)
+	emojis[0].Name = "changed"
+	if got := r.Commands(testGuildID)[0].Name; got != "clip" {
+		t.Fatalf("command name is %q, want clip", got)
+	}
+	if emojis, _ := r.GuildEmojis(testGuildID); emojis[0].Name != "Kappa" {
+		t.Fatalf("emoji name is %q, want Kappa", emojis[0].Name)
+	}
+}
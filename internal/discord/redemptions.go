@@ -0,0 +1,353 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	temporaryRolesBucket  = "temporary_roles"
+	temporaryRoleInterval = time.Minute
+)
+
+// Redemption action types.
+const (
+	// RedemptionActionMessage posts a message to the redemption channels.
+	RedemptionActionMessage = "message"
+	// RedemptionActionRole grants the redeeming viewer's linked Discord account a role for a while.
+	RedemptionActionRole = "role"
+	// RedemptionActionAnnounce posts an announcement to a specific channel.
+	RedemptionActionAnnounce = "announce"
+)
+
+// RedemptionAction is what happens in Discord when a custom reward is redeemed.
+type RedemptionAction struct {
+	// Reward is the title or ID of the custom reward.
+	Reward string
+	Type   string
+	// Message is the text of message and announce actions. {user}, {reward} and {input} are
+	// replaced with the viewer's name, the reward's title and the viewer's input.
+	Message string
+	// ChannelID is where announce actions post.
+	ChannelID string
+	// RoleID is the role role actions grant for Duration.
+	RoleID   string
+	Duration time.Duration
+}
+
+type RedemptionConfig struct {
+	// ChannelIDs are where message actions post, the announcement channels if empty.
+	ChannelIDs []string
+	Actions    []RedemptionAction
+}
+
+// RedemptionHandler runs the configured Discord actions for channel points redemptions.
+type RedemptionHandler struct {
+	client *Client
+	store  *store.Store
+	config RedemptionConfig
+}
+
+// temporaryRole is a role granted by a redemption until ExpiresAt.
+type temporaryRole struct {
+	GuildID   string    `json:"guild_id"`
+	UserID    string    `json:"user_id"`
+	RoleID    string    `json:"role_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func NewRedemptionHandler(client *Client, s *store.Store, config RedemptionConfig) *RedemptionHandler {
+	if len(config.ChannelIDs) == 0 {
+		config.ChannelIDs = client.channelIDs
+	}
+	return &RedemptionHandler{client: client, store: s, config: config}
+}
+
+// Run removes expired temporary roles until stop is closed.
+func (h *RedemptionHandler) Run(stop chan struct{}) {
+	h.removeExpiredRoles(time.Now())
+	ticker := time.NewTicker(temporaryRoleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			h.removeExpiredRoles(now)
+		}
+	}
+}
+
+// Handle runs the actions configured for the redeemed reward.
+func (h *RedemptionHandler) Handle(redemption twitchws.RedemptionEvent) {
+	for _, action := range h.config.Actions {
+		if action.Reward != redemption.Reward.ID && !strings.EqualFold(action.Reward, redemption.Reward.Title) {
+			continue
+		}
+		if err := h.run(action, redemption); err != nil {
+			log.Printf("error running %s action for %s redeemed by %s: %s", action.Type, redemption.Reward.Title, redemption.UserLogin, err)
+		}
+	}
+}
+
+func (h *RedemptionHandler) run(action RedemptionAction, redemption twitchws.RedemptionEvent) error {
+	message := strings.NewReplacer(
+		"{user}", escapeMarkdown(redemption.UserName),
+		"{reward}", escapeMarkdown(redemption.Reward.Title),
+		"{input}", escapeMarkdown(sanitizeMentions(redemption.UserInput)),
+	).Replace(action.Message)
+	switch action.Type {
+	case RedemptionActionMessage:
+		for _, channelID := range h.config.ChannelIDs {
+			if channelID == "" {
+				continue
+			}
+			if _, err := h.client.transport.SendComplex(channelID, &discordgo.MessageSend{
+				Content:         message,
+				AllowedMentions: &discordgo.MessageAllowedMentions{},
+			}); err != nil {
+				log.Printf("error sending redemption message to %s: %s", channelID, err)
+			}
+		}
+		return nil
+	case RedemptionActionAnnounce:
+		_, err := h.client.transport.SendEmbed(action.ChannelID, &discordgo.MessageEmbed{
+			Title:       fmt.Sprintf("✨ %s", redemption.Reward.Title),
+			URL:         twitchChannelURL,
+			Description: truncate(message, 4096),
+			Color:       0x9146ff,
+		})
+		return err
+	case RedemptionActionRole:
+		return h.grantRole(action, redemption)
+	}
+	return fmt.Errorf("unknown action type %q", action.Type)
+}
+
+// grantRole grants the role of action to the Discord account linked to the redeeming viewer until
+// the action's duration passes, redeeming again extends it.
+func (h *RedemptionHandler) grantRole(action RedemptionAction, redemption twitchws.RedemptionEvent) error {
+	discordUserID, found, err := linkedDiscordUser(h.store, redemption.UserID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		log.Printf("%s redeemed %s but has not linked a Discord account", redemption.UserLogin, redemption.Reward.Title)
+		return nil
+	}
+	if err := h.client.transport.AddRole(h.client.guildID, discordUserID, action.RoleID); err != nil {
+		return fmt.Errorf("error adding role %s: %w", action.RoleID, err)
+	}
+	role := temporaryRole{
+		GuildID:   h.client.guildID,
+		UserID:    discordUserID,
+		RoleID:    action.RoleID,
+		ExpiresAt: time.Now().Add(action.Duration),
+	}
+	return h.store.Put(temporaryRolesBucket, fmt.Sprintf("%s/%s/%s", role.GuildID, role.UserID, role.RoleID), role)
+}
+
+func (h *RedemptionHandler) removeExpiredRoles(now time.Time) {
+	for _, key := range h.store.Keys(temporaryRolesBucket) {
+		var role temporaryRole
+		if _, err := h.store.Get(temporaryRolesBucket, key, &role); err != nil || now.Before(role.ExpiresAt) {
+			continue
+		}
+		if err := h.client.transport.RemoveRole(role.GuildID, role.UserID, role.RoleID); err != nil && !isNotFound(err) {
+			log.Printf("error removing temporary role %s from %s: %s", role.RoleID, role.UserID, err)
+			continue
+		}
+		if err := h.store.Delete(temporaryRolesBucket, key); err != nil {
+			log.Printf("error forgetting temporary role %s: %s", key, err)
+		}
+	}
+}
+
+// ParseRedemptionActions parses a list of reward=action pairs separated by semicolons. The reward
+// is a custom reward's title or ID and the action one of "message:text",
+// "announce:channelID:text" or "role:roleID:duration", e.g.
+// "Hydrate=message:{user} says drink water!;VIP for a day=role:1234:24h".
+func ParseRedemptionActions(value string) ([]RedemptionAction, error) {
+	var actions []RedemptionAction
+	for _, pair := range strings.Split(value, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		reward, spec, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid redemption action %q, expected reward=action", pair)
+		}
+		action := RedemptionAction{Reward: strings.TrimSpace(reward)}
+		actionType, args, _ := strings.Cut(strings.TrimSpace(spec), ":")
+		action.Type = actionType
+		switch actionType {
+		case RedemptionActionMessage:
+			action.Message = args
+		case RedemptionActionAnnounce:
+			if action.ChannelID, action.Message, ok = strings.Cut(args, ":"); !ok {
+				return nil, fmt.Errorf("invalid announce action %q, expected announce:channelID:text", spec)
+			}
+		case RedemptionActionRole:
+			roleID, duration, ok := strings.Cut(args, ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid role action %q, expected role:roleID:duration", spec)
+			}
+			d, err := time.ParseDuration(duration)
+			if err != nil {
+				return nil, fmt.Errorf("invalid role action duration %q: %w", duration, err)
+			}
+			action.RoleID, action.Duration = roleID, d
+		default:
+			return nil, fmt.Errorf("unknown redemption action type %q", actionType)
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// RewardsCommand is the /rewards admin command, which manages the channel points custom rewards
+// jagger created with the broadcaster's tokens.
+func RewardsCommand(tokens *twitchws.TokenStore) Command {
+	rewardID := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "id",
+		Description: "The reward ID, see /rewards list",
+		Required:    true,
+	}
+	return Command{
+		Definition: &discordgo.ApplicationCommand{
+			Name:        "rewards",
+			Description: "Manage the channel points rewards created by jagger",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "list",
+					Description: "List the channel points rewards created by jagger",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "create",
+					Description: "Create a channel points reward",
+					Options: []*discordgo.ApplicationCommandOption{
+						{Type: discordgo.ApplicationCommandOptionString, Name: "title", Description: "The reward's title", Required: true},
+						{Type: discordgo.ApplicationCommandOptionInteger, Name: "cost", Description: "The reward's cost in channel points", Required: true, MinValue: floatPtr(1)},
+						{Type: discordgo.ApplicationCommandOptionString, Name: "prompt", Description: "The reward's description"},
+						{Type: discordgo.ApplicationCommandOptionBoolean, Name: "input-required", Description: "Whether viewers must enter text to redeem it"},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "edit",
+					Description: "Change a channel points reward",
+					Options: []*discordgo.ApplicationCommandOption{
+						rewardID,
+						{Type: discordgo.ApplicationCommandOptionString, Name: "title", Description: "The reward's title"},
+						{Type: discordgo.ApplicationCommandOptionInteger, Name: "cost", Description: "The reward's cost in channel points", MinValue: floatPtr(1)},
+						{Type: discordgo.ApplicationCommandOptionString, Name: "prompt", Description: "The reward's description"},
+						{Type: discordgo.ApplicationCommandOptionBoolean, Name: "enabled", Description: "Whether the reward is shown to viewers"},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "pause",
+					Description: "Stop viewers from redeeming a channel points reward",
+					Options:     []*discordgo.ApplicationCommandOption{rewardID},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "resume",
+					Description: "Let viewers redeem a paused channel points reward again",
+					Options:     []*discordgo.ApplicationCommandOption{rewardID},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "delete",
+					Description: "Delete a channel points reward",
+					Options:     []*discordgo.ApplicationCommandOption{rewardID},
+				},
+			},
+		},
+		AdminOnly: true,
+		Handler: func(i *discordgo.InteractionCreate) (string, error) {
+			switch Subcommand(i) {
+			case "list":
+				rewards, err := twitchws.GetCustomRewards(tokens)
+				if err != nil {
+					return "", fmt.Errorf("could not get rewards: %w", err)
+				}
+				if len(rewards) == 0 {
+					return "jagger has not created any channel points rewards.", nil
+				}
+				lines := make([]string, 0, len(rewards))
+				for _, reward := range rewards {
+					lines = append(lines, rewardLine(reward))
+				}
+				return strings.Join(lines, "\n"), nil
+			case "create":
+				title, cost := StringOption(i, "title"), int(IntOption(i, "cost", 0))
+				update := twitchws.CustomRewardUpdate{Title: &title, Cost: &cost, IsUserInputRequired: BoolOption(i, "input-required")}
+				if prompt := StringOption(i, "prompt"); prompt != "" {
+					update.Prompt = &prompt
+				}
+				reward, err := twitchws.CreateCustomReward(tokens, update)
+				if err != nil {
+					return "", fmt.Errorf("could not create reward: %w", err)
+				}
+				return "Created " + rewardLine(*reward), nil
+			case "edit":
+				var update twitchws.CustomRewardUpdate
+				if title := StringOption(i, "title"); title != "" {
+					update.Title = &title
+				}
+				if cost := int(IntOption(i, "cost", 0)); cost > 0 {
+					update.Cost = &cost
+				}
+				if prompt := StringOption(i, "prompt"); prompt != "" {
+					update.Prompt = &prompt
+				}
+				update.IsEnabled = BoolOption(i, "enabled")
+				return updateReward(tokens, StringOption(i, "id"), update)
+			case "pause", "resume":
+				paused := Subcommand(i) == "pause"
+				return updateReward(tokens, StringOption(i, "id"), twitchws.CustomRewardUpdate{IsPaused: &paused})
+			case "delete":
+				id := StringOption(i, "id")
+				if err := twitchws.DeleteCustomReward(tokens, id); err != nil {
+					return "", fmt.Errorf("could not delete reward: %w", err)
+				}
+				return fmt.Sprintf("Deleted reward `%s`.", id), nil
+			}
+			return "", fmt.Errorf("unknown subcommand")
+		},
+	}
+}
+
+func updateReward(tokens *twitchws.TokenStore, id string, update twitchws.CustomRewardUpdate) (string, error) {
+	reward, err := twitchws.UpdateCustomReward(tokens, id, update)
+	if err != nil {
+		return "", fmt.Errorf("could not update reward: %w", err)
+	}
+	return "Updated " + rewardLine(*reward), nil
+}
+
+func rewardLine(reward twitchws.CustomReward) string {
+	var status []string
+	if !reward.IsEnabled {
+		status = append(status, "disabled")
+	}
+	if reward.IsPaused {
+		status = append(status, "paused")
+	}
+	line := fmt.Sprintf("**%s** (%d points) `%s`", escapeMarkdown(reward.Title), reward.Cost, reward.ID)
+	if len(status) > 0 {
+		line += " — " + strings.Join(status, ", ")
+	}
+	return line
+}
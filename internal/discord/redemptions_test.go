@@ -0,0 +1,36 @@
+package discord
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRedemptionActions(t *testing.T) {
+	got, err := ParseRedemptionActions("Hydrate=message:{user} says: drink water!; Shout out = announce:1234:{user} wants a shout out;VIP for a day=role:5678:24h;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RedemptionAction{
+		{Reward: "Hydrate", Type: RedemptionActionMessage, Message: "{user} says: drink water!"},
+		{Reward: "Shout out", Type: RedemptionActionAnnounce, ChannelID: "1234", Message: "{user} wants a shout out"},
+		{Reward: "VIP for a day", Type: RedemptionActionRole, RoleID: "5678", Duration: 24 * time.Hour},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseRedemptionActions returned %+v, want %+v", got, want)
+	}
+}
+
+func TestParseRedemptionActionsErrors(t *testing.T) {
+	for _, value := range []string{
+		"Hydrate",
+		"Hydrate=dance:now",
+		"Shout out=announce:1234",
+		"VIP=role:5678",
+		"VIP=role:5678:a day",
+	} {
+		if _, err := ParseRedemptionActions(value); err == nil {
+			t.Errorf("ParseRedemptionActions(%q) returned no error", value)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// defaultRelayFormat is used when RelayConfig.Format is empty. {user} and {message} are replaced
+	// with the chatter's display name and their message.
+	defaultRelayFormat = "**{user}**: {message}"
+	// twitchMaxMessageLength is the longest message Twitch chat accepts.
+	twitchMaxMessageLength = 500
+	// relayQueueSize is how many chat messages may wait to be sent to Discord before new ones are
+	// dropped.
+	relayQueueSize = 100
+)
+
+// defaultRelayIgnoredUsers are common Twitch chat bots that are never relayed.
+var defaultRelayIgnoredUsers = []string{"nightbot", "streamelements", "streamlabs", "moobot", "fossabot", "wizebot", "jaggeropti"}
+
+// ChatSender sends messages to Twitch chat.
+type ChatSender interface {
+	Say(message string) bool
+}
+
+type RelayConfig struct {
+	// ChannelID is the Discord channel Twitch chat is mirrored into.
+	ChannelID string
+	// TwoWay also relays messages posted in ChannelID back to Twitch chat.
+	TwoWay bool
+	// Format is the Discord message template, see defaultRelayFormat.
+	Format string
+	// IgnoredUsers are Twitch logins whose messages are never relayed, in addition to known bots.
+	IgnoredUsers []string
+	// RelayCommands relays chat commands such as !uptime, which are dropped by default.
+	RelayCommands bool
+}
+
+// ChatRelay mirrors Twitch chat into a Discord channel while the stream is live.
+type ChatRelay struct {
+	client  *Client
+	chat    ChatSender
+	config  RelayConfig
+	ignored map[string]bool
+	queue   chan twitchws.ChatMessage
+
+	mu     sync.Mutex
+	active bool
+	emojis map[string]string
+}
+
+// NewChatRelay creates a relay between the joined channel of chat and config.ChannelID. The relay
+// does nothing until Start is called.
+func NewChatRelay(client *Client, chat *twitchws.Client, config RelayConfig) *ChatRelay {
+	if config.Format == "" {
+		config.Format = defaultRelayFormat
+	}
+	ignored := make(map[string]bool)
+	for _, login := range append(defaultRelayIgnoredUsers, config.IgnoredUsers...) {
+		ignored[strings.ToLower(strings.TrimSpace(login))] = true
+	}
+	r := &ChatRelay{
+		client:  client,
+		chat:    chat,
+		config:  config,
+		ignored: ignored,
+		queue:   make(chan twitchws.ChatMessage, relayQueueSize),
+	}
+	go r.sendQueued()
+	chat.OnMessage(r.relayToDiscord)
+	if config.TwoWay {
+		client.addHandler(r.relayToTwitch)
+	}
+	return r
+}
+
+// Start begins relaying, typically when the stream goes online.
+func (r *ChatRelay) Start() {
+	emojis := make(map[string]string)
+	if guildEmojis, err := r.client.transport.GuildEmojis(r.client.guildID); err != nil {
+		log.Printf("error getting guild emojis for chat relay, emotes will be rendered as text: %s", err)
+	} else {
+		for _, emoji := range guildEmojis {
+			emojis[emoji.Name] = emoji.MessageFormat()
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.emojis = emojis
+	if !r.active {
+		r.active = true
+		r.client.transport.SendMessage(r.config.ChannelID, "🔴 The stream is live, Twitch chat is now relayed here.")
+	}
+}
+
+// Stop ends relaying, typically when the stream goes offline.
+func (r *ChatRelay) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.active {
+		r.active = false
+		r.client.transport.SendMessage(r.config.ChannelID, "⚫ The stream is offline, Twitch chat relay stopped.")
+	}
+}
+
+func (r *ChatRelay) isActive() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.active
+}
+
+// relayToDiscord queues message to be sent to Discord, so slow Discord requests never hold up
+// reading Twitch chat. Messages are dropped while the queue is full.
+func (r *ChatRelay) relayToDiscord(message twitchws.ChatMessage) {
+	if !r.isActive() || r.ignored[strings.ToLower(message.Login)] {
+		return
+	}
+	if !r.config.RelayCommands && strings.HasPrefix(strings.TrimSpace(message.Text), "!") {
+		return
+	}
+	select {
+	case r.queue <- message:
+	default:
+		log.Printf("chat relay queue is full, dropping Twitch chat message %s", message.ID)
+	}
+}
+
+func (r *ChatRelay) sendQueued() {
+	for message := range r.queue {
+		r.send(message)
+	}
+}
+
+func (r *ChatRelay) send(message twitchws.ChatMessage) {
+	content := strings.NewReplacer(
+		"{user}", escapeMarkdown(message.DisplayName),
+		"{message}", r.renderEmotes(message),
+	).Replace(r.config.Format)
+	if _, err := r.client.transport.SendComplex(r.config.ChannelID, &discordgo.MessageSend{
+		Content:         truncate(content, 2000),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	}); err != nil {
+		log.Printf("error relaying Twitch chat message %s to Discord: %s", message.ID, err)
+	}
+}
+
+// renderEmotes escapes the message text and replaces Twitch emotes with the guild emoji of the same
+// name, or with :name: when the guild has no such emoji.
+func (r *ChatRelay) renderEmotes(message twitchws.ChatMessage) string {
+	runes := []rune(message.Text)
+	emotes := append([]twitchws.ChatEmote(nil), message.Emotes...)
+	sort.Slice(emotes, func(i, j int) bool { return emotes[i].Start < emotes[j].Start })
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var b strings.Builder
+	position := 0
+	for _, emote := range emotes {
+		if emote.Start < position || emote.End >= len(runes) {
+			continue
+		}
+		b.WriteString(sanitizeMentions(escapeMarkdown(string(runes[position:emote.Start]))))
+		if emoji, ok := r.emojis[emote.Name]; ok {
+			b.WriteString(emoji)
+		} else {
+			b.WriteString(fmt.Sprintf(":%s:", escapeMarkdown(emote.Name)))
+		}
+		position = emote.End + 1
+	}
+	b.WriteString(sanitizeMentions(escapeMarkdown(string(runes[position:]))))
+	return b.String()
+}
+
+func (r *ChatRelay) relayToTwitch(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.ChannelID != r.config.ChannelID || m.Author == nil || m.Author.Bot || !r.isActive() {
+		return
+	}
+	content := strings.TrimSpace(m.ContentWithMentionsReplaced())
+	if content == "" {
+		return
+	}
+	name := m.Author.Username
+	if m.Member != nil && m.Member.Nick != "" {
+		name = m.Member.Nick
+	}
+	// Never let Discord users run Twitch chat commands through the bot.
+	content = strings.TrimLeft(content, "/.!")
+	r.chat.Say(truncate(fmt.Sprintf("[Discord] %s: %s", name, content), twitchMaxMessageLength))
+}
+
+// sanitizeMentions breaks @everyone, @here and raw user, role and channel mentions so chatters
+// cannot ping anyone even if allowed mentions are ignored.
+func sanitizeMentions(s string) string {
+	return strings.NewReplacer(
+		"@everyone", "@\u200beveryone",
+		"@here", "@\u200bhere",
+		"<@", "<@\u200b",
+		"<#", "<#\u200b",
+	).Replace(s)
+}
+
+func escapeMarkdown(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		"*", `\*`,
+		"_", `\_`,
+		"~", `\~`,
+		"`", "\\`",
+		"|", `\|`,
+		">", `\>`,
+	).Replace(s)
+}
@@ -0,0 +1,49 @@
+package discord
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+)
+
+func TestSanitizeMentions(t *testing.T) {
+	for _, input := range []string{"@everyone", "@here", "<@123>", "<@&456>", "<#789>"} {
+		got := sanitizeMentions("hi " + input)
+		if strings.Contains(got, input) {
+			t.Errorf("sanitizeMentions(%q) returned %q, which still mentions", input, got)
+		}
+	}
+	if got := sanitizeMentions("mail me@example.com"); got != "mail me@example.com" {
+		t.Errorf("sanitizeMentions changed text without mentions to %q", got)
+	}
+}
+
+func TestEscapeMarkdown(t *testing.T) {
+	got := escapeMarkdown("**bold** _it_ ~~s~~ `c` ||spoiler|| > quote \\")
+	want := "\\*\\*bold\\*\\* \\_it\\_ \\~\\~s\\~\\~ \\`c\\` \\|\\|spoiler\\|\\| \\> quote \\\\"
+	if got != want {
+		t.Fatalf("escapeMarkdown returned %q, want %q", got, want)
+	}
+}
+
+func TestRelayRendersEmotes(t *testing.T) {
+	r := &ChatRelay{emojis: map[string]string{"Kappa": "<:Kappa:1>"}}
+	got := r.renderEmotes(twitchws.ChatMessage{
+		Text:   "*hi* Kappa @everyone Keepo",
+		Emotes: []twitchws.ChatEmote{{Name: "Keepo", Start: 21, End: 25}, {Name: "Kappa", Start: 5, End: 9}},
+	})
+	want := "\\*hi\\* <:Kappa:1> @​everyone :Keepo:"
+	if got != want {
+		t.Fatalf("renderEmotes returned %q, want %q", got, want)
+	}
+}
+
+func TestRelayDropsMessagesWhenQueueIsFull(t *testing.T) {
+	r := &ChatRelay{active: true, ignored: map[string]bool{}, queue: make(chan twitchws.ChatMessage, 1)}
+	r.relayToDiscord(twitchws.ChatMessage{ID: "1", Text: "first"})
+	r.relayToDiscord(twitchws.ChatMessage{ID: "2", Text: "second"})
+	if len(r.queue) != 1 || (<-r.queue).ID != "1" {
+		t.Fatal("the relay did not keep the first message and drop the second")
+	}
+}
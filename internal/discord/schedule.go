@@ -0,0 +1,357 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	scheduleBucket          = "schedule"
+	scheduleEventsBucket    = "schedule_events"
+	scheduleRemindersBucket = "schedule_reminders"
+	weeklyScheduleKey       = "weekly"
+	// scheduleHorizon is how far ahead Discord scheduled events are created.
+	scheduleHorizon = 7 * 24 * time.Hour
+	// defaultSegmentDuration is used for segments without an end time, Discord requires one.
+	defaultSegmentDuration = 3 * time.Hour
+	// scheduleRetention is how long records of past segments are kept.
+	scheduleRetention   = 24 * time.Hour
+	defaultScheduleSync = 15 * time.Minute
+)
+
+// ScheduleConfig configures the ScheduleAnnouncer.
+type ScheduleConfig struct {
+	// ChannelID is where the weekly schedule is posted. No schedule is posted if it is empty.
+	ChannelID string
+	// ReminderBefore is how long before a segment starts a reminder is posted to the announcement
+	// channels. No reminders are posted if it is 0.
+	ReminderBefore time.Duration
+	// SyncInterval is how often the schedule is fetched from Twitch, every 15 minutes if it is 0.
+	SyncInterval time.Duration
+}
+
+// ScheduleAnnouncer mirrors the broadcaster's Twitch schedule to Discord: a weekly schedule embed,
+// a guild scheduled event per segment and reminders shortly before segments start.
+type ScheduleAnnouncer struct {
+	client      *Client
+	store       *store.Store
+	config      ScheduleConfig
+	getSchedule func(from, until time.Time) (*twitchws.Schedule, error)
+
+	mu       sync.Mutex
+	schedule *twitchws.Schedule
+	lastSync time.Time
+}
+
+// scheduledEvent is the Discord scheduled event created for a schedule segment.
+type scheduledEvent struct {
+	EventID   string    `json:"event_id"`
+	StartTime time.Time `json:"start_time"`
+	// Version identifies the segment details the event was last updated with.
+	Version string `json:"version"`
+}
+
+// weeklySchedule is the schedule message posted for a week.
+type weeklySchedule struct {
+	Week      string `json:"week"`
+	ChannelID string `json:"channel_id"`
+	MessageID string `json:"message_id"`
+	Version   string `json:"version"`
+}
+
+func NewScheduleAnnouncer(client *Client, s *store.Store, config ScheduleConfig) *ScheduleAnnouncer {
+	if config.SyncInterval == 0 {
+		config.SyncInterval = defaultScheduleSync
+	}
+	return &ScheduleAnnouncer{
+		client:      client,
+		store:       s,
+		config:      config,
+		getSchedule: twitchws.GetSchedule,
+	}
+}
+
+// Run syncs the schedule periodically and posts reminders until stop is closed.
+func (a *ScheduleAnnouncer) Run(stop chan struct{}) {
+	a.Sync()
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			a.mu.Lock()
+			due := now.Sub(a.lastSync) >= a.config.SyncInterval
+			a.mu.Unlock()
+			if due {
+				a.Sync()
+			}
+			a.remind(now)
+		}
+	}
+}
+
+// Sync fetches the schedule from Twitch and updates the scheduled events and the weekly schedule.
+func (a *ScheduleAnnouncer) Sync() {
+	now := time.Now()
+	// The schedule is fetched from the start of the week, so the weekly schedule keeps the segments
+	// that already started.
+	schedule, err := a.getSchedule(startOfWeek(now), now.Add(scheduleHorizon))
+	if err != nil {
+		log.Printf("error syncing schedule: %s", err)
+		return
+	}
+	a.mu.Lock()
+	a.schedule = schedule
+	a.lastSync = now
+	a.mu.Unlock()
+	a.syncEvents(schedule, now)
+	if a.config.ChannelID != "" {
+		if err := a.postWeeklySchedule(schedule, now); err != nil {
+			log.Printf("error posting weekly schedule: %s", err)
+		}
+	}
+	a.forgetReminders(now)
+}
+
+// syncEvents creates, updates and deletes guild scheduled events to match the upcoming segments.
+func (a *ScheduleAnnouncer) syncEvents(schedule *twitchws.Schedule, now time.Time) {
+	// The event of the live stream is managed by LiveEvent until the stream ends.
+	var liveEventID string
+	if live, err := currentLiveEvent(a.store); err != nil {
+		log.Printf("error reading live event: %s", err)
+		return
+	} else if live != nil {
+		liveEventID = live.EventID
+	}
+	upcoming := make(map[string]bool)
+	for _, segment := range schedule.Segments {
+		if segment.Canceled() || !segment.StartTime.After(now) {
+			continue
+		}
+		upcoming[segment.ID] = true
+		if err := a.syncEvent(segment, liveEventID); err != nil {
+			log.Printf("error syncing scheduled event for segment %s: %s", segment.ID, err)
+		}
+	}
+	for _, segmentID := range a.store.Keys(scheduleEventsBucket) {
+		if upcoming[segmentID] {
+			continue
+		}
+		var event scheduledEvent
+		if _, err := a.store.Get(scheduleEventsBucket, segmentID, &event); err != nil {
+			log.Printf("error reading scheduled event for segment %s: %s", segmentID, err)
+			continue
+		}
+		switch {
+		case event.EventID == liveEventID:
+			continue
+		case event.StartTime.After(now):
+			// The segment was canceled or removed from the schedule.
+			if err := a.client.transport.DeleteScheduledEvent(a.client.guildID, event.EventID); err != nil && !isNotFound(err) {
+				log.Printf("error deleting scheduled event %s: %s", event.EventID, err)
+				continue
+			}
+		case now.Sub(event.StartTime) < scheduleRetention:
+			// Keep the events of segments that just started, they are completed when the stream ends.
+			continue
+		}
+		if err := a.store.Delete(scheduleEventsBucket, segmentID); err != nil {
+			log.Printf("error deleting scheduled event record for segment %s: %s", segmentID, err)
+		}
+	}
+}
+
+func (a *ScheduleAnnouncer) syncEvent(segment twitchws.ScheduleSegment, liveEventID string) error {
+	params := segmentEventParams(segment)
+	version := fmt.Sprintf("%s|%s|%d|%d", params.Name, params.Description, params.ScheduledStartTime.Unix(), params.ScheduledEndTime.Unix())
+	var event scheduledEvent
+	found, err := a.store.Get(scheduleEventsBucket, segment.ID, &event)
+	if err != nil {
+		return err
+	}
+	if found && (event.Version == version || event.EventID == liveEventID) {
+		return nil
+	}
+	if found {
+		if _, err := a.client.transport.EditScheduledEvent(a.client.guildID, event.EventID, params); err == nil {
+			event.StartTime = segment.StartTime
+			event.Version = version
+			return a.store.Put(scheduleEventsBucket, segment.ID, event)
+		} else if !isNotFound(err) {
+			return fmt.Errorf("error updating scheduled event %s: %w", event.EventID, err)
+		}
+		// The event was deleted in Discord, create it again.
+	}
+	created, err := a.client.transport.CreateScheduledEvent(a.client.guildID, params)
+	if err != nil {
+		return fmt.Errorf("error creating scheduled event: %w", err)
+	}
+	return a.store.Put(scheduleEventsBucket, segment.ID, scheduledEvent{
+		EventID:   created.ID,
+		StartTime: segment.StartTime,
+		Version:   version,
+	})
+}
+
+func segmentEventParams(segment twitchws.ScheduleSegment) *discordgo.GuildScheduledEventParams {
+	start := segment.StartTime
+	end := start.Add(defaultSegmentDuration)
+	if segment.EndTime != nil {
+		end = *segment.EndTime
+	}
+	description := "Live on Twitch"
+	if segment.Category != nil && segment.Category.Name != "" {
+		description = fmt.Sprintf("Playing %s", segment.Category.Name)
+	}
+	return &discordgo.GuildScheduledEventParams{
+		Name:               truncate(segmentTitle(segment), 100),
+		Description:        truncate(description, 1000),
+		ScheduledStartTime: &start,
+		ScheduledEndTime:   &end,
+		PrivacyLevel:       discordgo.GuildScheduledEventPrivacyLevelGuildOnly,
+		EntityType:         discordgo.GuildScheduledEventEntityTypeExternal,
+		EntityMetadata:     &discordgo.GuildScheduledEventEntityMetadata{Location: twitchChannelURL},
+	}
+}
+
+func segmentTitle(segment twitchws.ScheduleSegment) string {
+	if segment.Title != "" {
+		return segment.Title
+	}
+	return "SensaiOpti is live"
+}
+
+// postWeeklySchedule posts the schedule of the current week, or updates the message already posted
+// this week when the schedule changed.
+func (a *ScheduleAnnouncer) postWeeklySchedule(schedule *twitchws.Schedule, now time.Time) error {
+	weekStart := startOfWeek(now)
+	year, week := now.UTC().ISOWeek()
+	embed := weeklyScheduleEmbed(schedule, weekStart, weekStart.AddDate(0, 0, 7))
+	version := embed.Description
+	var posted weeklySchedule
+	if _, err := a.store.Get(scheduleBucket, weeklyScheduleKey, &posted); err != nil {
+		return err
+	}
+	current := weeklySchedule{
+		Week:      fmt.Sprintf("%d-W%02d", year, week),
+		ChannelID: a.config.ChannelID,
+		Version:   version,
+	}
+	if posted.Week == current.Week && posted.ChannelID == current.ChannelID {
+		if posted.Version == version {
+			return nil
+		}
+		if _, err := a.client.transport.EditMessage(&discordgo.MessageEdit{
+			ID:      posted.MessageID,
+			Channel: posted.ChannelID,
+			Embeds:  []*discordgo.MessageEmbed{embed},
+		}); err == nil {
+			current.MessageID = posted.MessageID
+			return a.store.Put(scheduleBucket, weeklyScheduleKey, current)
+		} else if !isNotFound(err) {
+			return fmt.Errorf("error updating weekly schedule: %w", err)
+		}
+	}
+	message, err := a.client.transport.SendEmbed(a.config.ChannelID, embed)
+	if err != nil {
+		return fmt.Errorf("error sending weekly schedule: %w", err)
+	}
+	current.MessageID = message.ID
+	return a.store.Put(scheduleBucket, weeklyScheduleKey, current)
+}
+
+func weeklyScheduleEmbed(schedule *twitchws.Schedule, from, to time.Time) *discordgo.MessageEmbed {
+	var lines []string
+	for _, segment := range schedule.Segments {
+		if segment.StartTime.Before(from) || !segment.StartTime.Before(to) {
+			continue
+		}
+		line := fmt.Sprintf("<t:%d:F> **%s**", segment.StartTime.Unix(), escapeMarkdown(segmentTitle(segment)))
+		if segment.Category != nil && segment.Category.Name != "" {
+			line += fmt.Sprintf(" — %s", escapeMarkdown(segment.Category.Name))
+		}
+		if segment.Canceled() {
+			line = fmt.Sprintf("~~%s~~ (canceled)", line)
+		}
+		lines = append(lines, line)
+	}
+	if vacation := schedule.Vacation; vacation != nil && vacation.StartTime.Before(to) && vacation.EndTime.After(from) {
+		lines = append(lines, fmt.Sprintf("🏖️ On vacation from <t:%d:D> to <t:%d:D>", vacation.StartTime.Unix(), vacation.EndTime.Unix()))
+	}
+	if len(lines) == 0 {
+		lines = []string{"Nothing is scheduled this week."}
+	}
+	return &discordgo.MessageEmbed{
+		Title:       "This week on stream",
+		URL:         twitchChannelURL + "/schedule",
+		Description: truncate(strings.Join(lines, "\n"), 4096),
+		Color:       0x33ff33,
+		Footer:      &discordgo.MessageEmbedFooter{Text: "Times are shown in your time zone"},
+	}
+}
+
+// startOfWeek returns the start of the ISO week, Monday 00:00 UTC, containing t.
+func startOfWeek(t time.Time) time.Time {
+	t = t.UTC()
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, time.UTC)
+}
+
+// remind posts a reminder for segments starting within ReminderBefore.
+func (a *ScheduleAnnouncer) remind(now time.Time) {
+	if a.config.ReminderBefore == 0 {
+		return
+	}
+	a.mu.Lock()
+	schedule := a.schedule
+	a.mu.Unlock()
+	if schedule == nil {
+		return
+	}
+	for _, segment := range schedule.Segments {
+		if segment.Canceled() || !segment.StartTime.After(now) || segment.StartTime.Sub(now) > a.config.ReminderBefore {
+			continue
+		}
+		var remindedAt time.Time
+		if found, err := a.store.Get(scheduleRemindersBucket, segment.ID, &remindedAt); err != nil || found {
+			continue
+		}
+		content := fmt.Sprintf("⏰ **%s** starts <t:%d:R>!", escapeMarkdown(segmentTitle(segment)), segment.StartTime.Unix())
+		if segment.Category != nil && segment.Category.Name != "" {
+			content += fmt.Sprintf(" Playing %s.", escapeMarkdown(segment.Category.Name))
+		}
+		a.client.SendMessage(fmt.Sprintf("%s %s", content, twitchChannelURL))
+		if err := a.store.Put(scheduleRemindersBucket, segment.ID, now); err != nil {
+			log.Printf("error recording reminder for segment %s: %s", segment.ID, err)
+		}
+	}
+}
+
+func (a *ScheduleAnnouncer) forgetReminders(now time.Time) {
+	for _, segmentID := range a.store.Keys(scheduleRemindersBucket) {
+		var remindedAt time.Time
+		if _, err := a.store.Get(scheduleRemindersBucket, segmentID, &remindedAt); err != nil || now.Sub(remindedAt) < scheduleRetention {
+			continue
+		}
+		if err := a.store.Delete(scheduleRemindersBucket, segmentID); err != nil {
+			log.Printf("error deleting reminder record for segment %s: %s", segmentID, err)
+		}
+	}
+}
+
+// isNotFound reports whether err is a Discord API error for something that does not exist.
+func isNotFound(err error) bool {
+	var restErr *discordgo.RESTError
+	return errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound
+}
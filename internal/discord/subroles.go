@@ -0,0 +1,257 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+)
+
+const (
+	// subscriberRolesBucket holds the subscriber role granted to each Discord user by the sync.
+	subscriberRolesBucket         = "subscriber_roles"
+	defaultSubscriberGrace        = 72 * time.Hour
+	defaultSubscriberRoleInterval = 6 * time.Hour
+)
+
+type SubscriberRoleConfig struct {
+	// TierRoleIDs are the roles granted for each subscription tier, "1000", "2000" or "3000".
+	// Tiers without a role get no role.
+	TierRoleIDs map[string]string
+	// GracePeriod is how long a role is kept after the subscription ended, 72 hours if it is 0.
+	GracePeriod time.Duration
+	// Interval is how often every linked account is synced, every 6 hours if it is 0.
+	Interval time.Duration
+}
+
+// SubscriberRoleSync grants linked Discord accounts the role of their Twitch subscription tier and
+// removes it once the subscription has ended for longer than the grace period. Only roles granted by
+// the sync are removed, roles given by hand are left alone.
+type SubscriberRoleSync struct {
+	client        *Client
+	store         *store.Store
+	notifier      *AdminNotifier
+	subscriptions func(userIDs ...string) ([]twitchws.ChannelSubscription, error)
+	config        SubscriberRoleConfig
+	// mu serializes syncs, event syncs can run while a full sync does.
+	mu sync.Mutex
+}
+
+// grantedSubscriberRole is a subscriber role granted to a Discord user.
+type grantedSubscriberRole struct {
+	TwitchUserID string `json:"twitch_user_id"`
+	RoleID       string `json:"role_id"`
+	// LapsedAt is when the subscription was first seen ended, the role is removed GracePeriod later.
+	LapsedAt *time.Time `json:"lapsed_at,omitempty"`
+}
+
+// subscriberSyncReport counts what a sync did.
+type subscriberSyncReport struct {
+	granted, removed, lapsed, errors int
+}
+
+func (r subscriberSyncReport) changed() bool {
+	return r.granted > 0 || r.removed > 0 || r.errors > 0
+}
+
+// NewSubscriberRoleSync creates a SubscriberRoleSync. subscriptions returns the subscriptions to the
+// broadcaster, only those of userIDs if any are given.
+func NewSubscriberRoleSync(client *Client, s *store.Store, notifier *AdminNotifier, subscriptions func(userIDs ...string) ([]twitchws.ChannelSubscription, error), config SubscriberRoleConfig) *SubscriberRoleSync {
+	if config.GracePeriod == 0 {
+		config.GracePeriod = defaultSubscriberGrace
+	}
+	if config.Interval == 0 {
+		config.Interval = defaultSubscriberRoleInterval
+	}
+	return &SubscriberRoleSync{client: client, store: s, notifier: notifier, subscriptions: subscriptions, config: config}
+}
+
+// Run syncs every linked account every Interval until stop is closed.
+func (r *SubscriberRoleSync) Run(stop chan struct{}) {
+	r.Sync()
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			r.Sync()
+		}
+	}
+}
+
+// Sync reconciles the roles of every linked account and of every Discord user holding a synced role,
+// and reports the run to the admin channels.
+func (r *SubscriberRoleSync) Sync() {
+	subscriptions, err := r.subscriptions()
+	if err != nil {
+		r.notifier.Error("Could not sync subscriber roles", err)
+		return
+	}
+	tiers := make(map[string]string, len(subscriptions))
+	for _, subscription := range subscriptions {
+		tiers[subscription.UserID] = subscription.Tier
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Discord users by the Twitch account they are synced for.
+	accounts := make(map[string]string)
+	for _, twitchUserID := range r.store.Keys(linkedAccountsBucket) {
+		if discordUserID, found, err := linkedDiscordUser(r.store, twitchUserID); err == nil && found {
+			accounts[discordUserID] = twitchUserID
+		}
+	}
+	// Users who unlinked keep their role until the grace period passes.
+	for _, discordUserID := range r.store.Keys(subscriberRolesBucket) {
+		if _, ok := accounts[discordUserID]; !ok {
+			accounts[discordUserID] = ""
+		}
+	}
+	var report subscriberSyncReport
+	now := time.Now()
+	for discordUserID, twitchUserID := range accounts {
+		tier := ""
+		if twitchUserID != "" {
+			tier = tiers[twitchUserID]
+		}
+		r.reconcile(discordUserID, twitchUserID, tier, now, &report)
+	}
+	description := fmt.Sprintf("Checked %d linked accounts against %d subscriptions: granted %d roles, removed %d, %d in their grace period.",
+		len(accounts), len(subscriptions), report.granted, report.removed, report.lapsed)
+	if report.errors > 0 {
+		description += fmt.Sprintf(" %d roles could not be changed, see the logs.", report.errors)
+	}
+	if report.changed() {
+		r.notifier.Info("Synced subscriber roles", description)
+	} else {
+		r.notifier.Debug("Synced subscriber roles", description)
+	}
+}
+
+// SyncUser reconciles the role of the Discord account linked to twitchUserID with their current
+// subscription, after they linked their account.
+func (r *SubscriberRoleSync) SyncUser(twitchUserID string) {
+	if _, found, err := linkedDiscordUser(r.store, twitchUserID); err != nil || !found {
+		return
+	}
+	subscriptions, err := r.subscriptions(twitchUserID)
+	if err != nil {
+		log.Printf("error getting subscription of %s: %s", twitchUserID, err)
+		return
+	}
+	tier := ""
+	for _, subscription := range subscriptions {
+		if subscription.UserID == twitchUserID {
+			tier = subscription.Tier
+		}
+	}
+	r.SyncTier(twitchUserID, tier)
+}
+
+// SyncTier reconciles the role of the Discord account linked to twitchUserID with tier, taken from
+// a subscription event, or "" after their subscription ended. The subscriptions are not fetched, they
+// can lag behind the events.
+func (r *SubscriberRoleSync) SyncTier(twitchUserID, tier string) {
+	discordUserID, found, err := linkedDiscordUser(r.store, twitchUserID)
+	if err != nil || !found {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var report subscriberSyncReport
+	r.reconcile(discordUserID, twitchUserID, tier, time.Now(), &report)
+}
+
+// reconcile gives discordUserID the role of tier, or starts or ends the grace period of their role if
+// tier is "".
+func (r *SubscriberRoleSync) reconcile(discordUserID, twitchUserID, tier string, now time.Time, report *subscriberSyncReport) {
+	var granted grantedSubscriberRole
+	if _, err := r.store.Get(subscriberRolesBucket, discordUserID, &granted); err != nil {
+		log.Printf("error reading subscriber role of %s: %s", discordUserID, err)
+		report.errors++
+		return
+	}
+	guildID := r.client.guildID
+	if roleID := r.config.TierRoleIDs[tier]; roleID != "" {
+		if granted.RoleID == roleID && granted.LapsedAt == nil {
+			return
+		}
+		if granted.RoleID != roleID {
+			if err := r.client.transport.AddRole(guildID, discordUserID, roleID); isNotFound(err) {
+				// The member left the server, their role is granted if they come back.
+				return
+			} else if err != nil {
+				log.Printf("error granting subscriber role %s to %s: %s", roleID, discordUserID, err)
+				report.errors++
+				return
+			}
+			report.granted++
+			if granted.RoleID != "" {
+				r.removeRole(discordUserID, granted.RoleID, report)
+			}
+		}
+		r.put(discordUserID, grantedSubscriberRole{TwitchUserID: twitchUserID, RoleID: roleID}, report)
+		return
+	}
+	if granted.RoleID == "" {
+		return
+	}
+	if granted.LapsedAt == nil {
+		granted.LapsedAt = &now
+		r.put(discordUserID, granted, report)
+	}
+	if now.Sub(*granted.LapsedAt) < r.config.GracePeriod {
+		report.lapsed++
+		return
+	}
+	if !r.removeRole(discordUserID, granted.RoleID, report) {
+		return
+	}
+	report.removed++
+	if err := r.store.Delete(subscriberRolesBucket, discordUserID); err != nil {
+		log.Printf("error forgetting subscriber role of %s: %s", discordUserID, err)
+	}
+}
+
+func (r *SubscriberRoleSync) removeRole(discordUserID, roleID string, report *subscriberSyncReport) bool {
+	if err := r.client.transport.RemoveRole(r.client.guildID, discordUserID, roleID); err != nil && !isNotFound(err) {
+		log.Printf("error removing subscriber role %s from %s: %s", roleID, discordUserID, err)
+		report.errors++
+		return false
+	}
+	return true
+}
+
+func (r *SubscriberRoleSync) put(discordUserID string, granted grantedSubscriberRole, report *subscriberSyncReport) {
+	if err := r.store.Put(subscriberRolesBucket, discordUserID, granted); err != nil {
+		log.Printf("error recording subscriber role of %s: %s", discordUserID, err)
+		report.errors++
+	}
+}
+
+// ParseTierRoles parses a list of tier=roleID pairs separated by commas, where tier is 1, 2 or 3,
+// e.g. "1=1234,2=5678,3=9012".
+func ParseTierRoles(value string) (map[string]string, error) {
+	roles := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		tier, roleID, ok := strings.Cut(pair, "=")
+		switch tier = strings.TrimSpace(tier); {
+		case !ok:
+			return nil, fmt.Errorf("invalid tier role %q, expected tier=roleID", pair)
+		case tier == "1" || tier == "2" || tier == "3":
+			roles[tier+"000"] = strings.TrimSpace(roleID)
+		default:
+			return nil, fmt.Errorf("invalid tier %q, expected 1, 2 or 3", tier)
+		}
+	}
+	return roles, nil
+}
@@ -0,0 +1,80 @@
+package discord
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+)
+
+func TestSubscriberRoleGrantLapseRemove(t *testing.T) {
+	client, recorder, s := newTestClient(t)
+	if err := LinkAccount(s, "discord-1", "twitch-1", "viewer"); err != nil {
+		t.Fatal(err)
+	}
+	r := NewSubscriberRoleSync(client, s, NewAdminNotifier(client, NotifierConfig{}), func(userIDs ...string) ([]twitchws.ChannelSubscription, error) {
+		return nil, nil
+	}, SubscriberRoleConfig{
+		TierRoleIDs: map[string]string{"1000": "tier-1", "2000": "tier-2"},
+		GracePeriod: time.Hour,
+	})
+	roles := func() []string {
+		return recorder.MemberRoles(testGuildID, "discord-1")
+	}
+
+	r.SyncTier("twitch-1", "1000")
+	if got := roles(); len(got) != 1 || got[0] != "tier-1" {
+		t.Fatalf("roles are %v after subscribing, want tier-1", got)
+	}
+	r.SyncTier("twitch-1", "2000")
+	if got := roles(); len(got) != 1 || got[0] != "tier-2" {
+		t.Fatalf("roles are %v after upgrading, want tier-2", got)
+	}
+
+	now := time.Now()
+	var report subscriberSyncReport
+	r.reconcile("discord-1", "twitch-1", "", now, &report)
+	if got := roles(); len(got) != 1 || report.lapsed != 1 {
+		t.Fatalf("roles are %v with %d lapsed after the subscription ended, want tier-2 kept for the grace period", got, report.lapsed)
+	}
+	r.reconcile("discord-1", "twitch-1", "", now.Add(2*time.Hour), &report)
+	if got := roles(); len(got) != 0 || report.removed != 1 {
+		t.Fatalf("roles are %v with %d removed after the grace period, want none", got, report.removed)
+	}
+	if found, _ := s.Get(subscriberRolesBucket, "discord-1", new(grantedSubscriberRole)); found {
+		t.Fatal("the removed role is still recorded")
+	}
+}
+
+func TestSubscriberRoleLeavesRolesGivenByHand(t *testing.T) {
+	client, recorder, s := newTestClient(t)
+	if err := LinkAccount(s, "discord-1", "twitch-1", "viewer"); err != nil {
+		t.Fatal(err)
+	}
+	recorder.AddRole(testGuildID, "discord-1", "tier-1")
+	r := NewSubscriberRoleSync(client, s, NewAdminNotifier(client, NotifierConfig{}), nil, SubscriberRoleConfig{
+		TierRoleIDs: map[string]string{"1000": "tier-1"},
+		GracePeriod: time.Hour,
+	})
+	var report subscriberSyncReport
+	r.reconcile("discord-1", "twitch-1", "", time.Now().Add(2*time.Hour), &report)
+	if got := recorder.MemberRoles(testGuildID, "discord-1"); len(got) != 1 {
+		t.Fatalf("roles are %v, want the role given by hand kept", got)
+	}
+}
+
+func TestParseTierRoles(t *testing.T) {
+	got, err := ParseTierRoles(" 1=1234, 3 = 9012,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"1000": "1234", "3000": "9012"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseTierRoles returned %v, want %v", got, want)
+	}
+	for _, value := range []string{"1", "4=1234", "prime=1234"} {
+		if _, err := ParseTierRoles(value); err == nil {
+			t.Errorf("ParseTierRoles(%q) returned no error", value)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package discord
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Transport is the part of the Discord API jagger uses. SessionTransport implements it with a
+// discordgo session and Recorder keeps everything in memory for tests.
+type Transport interface {
+	SendMessage(channelID, content string) (*discordgo.Message, error)
+	SendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error)
+	SendComplex(channelID string, message *discordgo.MessageSend) (*discordgo.Message, error)
+	EditMessage(edit *discordgo.MessageEdit) (*discordgo.Message, error)
+	React(channelID, messageID, emoji string) error
+	GuildEmojis(guildID string) ([]*discordgo.Emoji, error)
+	ScheduledEvents(guildID string) ([]*discordgo.GuildScheduledEvent, error)
+	CreateScheduledEvent(guildID string, params *discordgo.GuildScheduledEventParams) (*discordgo.GuildScheduledEvent, error)
+	EditScheduledEvent(guildID, eventID string, params *discordgo.GuildScheduledEventParams) (*discordgo.GuildScheduledEvent, error)
+	DeleteScheduledEvent(guildID, eventID string) error
+	AddRole(guildID, userID, roleID string) error
+	RemoveRole(guildID, userID, roleID string) error
+	BanMember(guildID, userID, reason string) error
+	Channel(channelID string) (*discordgo.Channel, error)
+	RenameChannel(channelID, name string) error
+	// UpdatePresence sets the activity of the bot, or clears it if activity is nil.
+	UpdatePresence(activity *discordgo.Activity) error
+	// RegisterCommands replaces the slash commands of guildID with commands.
+	RegisterCommands(guildID string, commands []*discordgo.ApplicationCommand) error
+}
+
+// SessionTransport is the Transport backed by a discordgo session.
+type SessionTransport struct {
+	session *discordgo.Session
+}
+
+// NewSessionTransport creates a discordgo session for the bot with token.
+func NewSessionTransport(token string) (*SessionTransport, error) {
+	session, err := discordgo.New("Bot " + token)
+	if err != nil {
+		return nil, fmt.Errorf("error creating discord session: %w", err)
+	}
+	return &SessionTransport{session: session}, nil
+}
+
+func (t *SessionTransport) SendMessage(channelID, content string) (*discordgo.Message, error) {
+	return t.session.ChannelMessageSend(channelID, content)
+}
+
+func (t *SessionTransport) SendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return t.session.ChannelMessageSendEmbed(channelID, embed)
+}
+
+func (t *SessionTransport) SendComplex(channelID string, message *discordgo.MessageSend) (*discordgo.Message, error) {
+	return t.session.ChannelMessageSendComplex(channelID, message)
+}
+
+func (t *SessionTransport) EditMessage(edit *discordgo.MessageEdit) (*discordgo.Message, error) {
+	return t.session.ChannelMessageEditComplex(edit)
+}
+
+func (t *SessionTransport) React(channelID, messageID, emoji string) error {
+	return t.session.MessageReactionAdd(channelID, messageID, emoji)
+}
+
+func (t *SessionTransport) GuildEmojis(guildID string) ([]*discordgo.Emoji, error) {
+	return t.session.GuildEmojis(guildID)
+}
+
+func (t *SessionTransport) ScheduledEvents(guildID string) ([]*discordgo.GuildScheduledEvent, error) {
+	return t.session.GuildScheduledEvents(guildID, false)
+}
+
+func (t *SessionTransport) CreateScheduledEvent(guildID string, params *discordgo.GuildScheduledEventParams) (*discordgo.GuildScheduledEvent, error) {
+	return t.session.GuildScheduledEventCreate(guildID, params)
+}
+
+func (t *SessionTransport) EditScheduledEvent(guildID, eventID string, params *discordgo.GuildScheduledEventParams) (*discordgo.GuildScheduledEvent, error) {
+	return t.session.GuildScheduledEventEdit(guildID, eventID, params)
+}
+
+func (t *SessionTransport) DeleteScheduledEvent(guildID, eventID string) error {
+	return t.session.GuildScheduledEventDelete(guildID, eventID)
+}
+
+func (t *SessionTransport) AddRole(guildID, userID, roleID string) error {
+	return t.session.GuildMemberRoleAdd(guildID, userID, roleID)
+}
+
+func (t *SessionTransport) RemoveRole(guildID, userID, roleID string) error {
+	return t.session.GuildMemberRoleRemove(guildID, userID, roleID)
+}
+
+func (t *SessionTransport) BanMember(guildID, userID, reason string) error {
+	return t.session.GuildBanCreateWithReason(guildID, userID, reason, 0)
+}
+
+func (t *SessionTransport) Channel(channelID string) (*discordgo.Channel, error) {
+	return t.session.Channel(channelID)
+}
+
+func (t *SessionTransport) RenameChannel(channelID, name string) error {
+	_, err := t.session.ChannelEdit(channelID, &discordgo.ChannelEdit{Name: name})
+	return err
+}
+
+func (t *SessionTransport) UpdatePresence(activity *discordgo.Activity) error {
+	status := discordgo.UpdateStatusData{Status: string(discordgo.StatusOnline), Activities: []*discordgo.Activity{}}
+	if activity != nil {
+		status.Activities = []*discordgo.Activity{activity}
+	}
+	return t.session.UpdateStatusComplex(status)
+}
+
+func (t *SessionTransport) RegisterCommands(guildID string, commands []*discordgo.ApplicationCommand) error {
+	if t.session.State.User == nil {
+		return fmt.Errorf("session is not open")
+	}
+	_, err := t.session.ApplicationCommandBulkOverwrite(t.session.State.User.ID, guildID, commands)
+	return err
+}
@@ -0,0 +1,156 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxRecentEvents is the most events /twitch events lists.
+const maxRecentEvents = 25
+
+// TwitchCommand is the /twitch admin command for inspecting and managing the EventSub subscriptions.
+// resubscribe recreates every subscription and events is the log of received notifications.
+func (c *Client) TwitchCommand(resubscribe func() error, events *twitchws.EventLog) Command {
+	return Command{
+		Definition: &discordgo.ApplicationCommand{
+			Name:        "twitch",
+			Description: "Manage jagger's Twitch EventSub subscriptions",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "subscriptions",
+					Description: "List the EventSub subscriptions with their status and cost",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "resubscribe",
+					Description: "Delete and recreate every EventSub subscription",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "unsubscribe",
+					Description: "Delete an EventSub subscription",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "id",
+							Description: "The subscription ID, see /twitch subscriptions",
+							Required:    true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "test-announcement",
+					Description: "Post the go-live announcement for the current channel information in this channel",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "events",
+					Description: "Show the most recently received Twitch events",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "count",
+							Description: fmt.Sprintf("How many events to show, at most %d", maxRecentEvents),
+							MinValue:    floatPtr(1),
+							MaxValue:    maxRecentEvents,
+						},
+					},
+				},
+			},
+		},
+		AdminOnly: true,
+		Handler: func(i *discordgo.InteractionCreate) (string, error) {
+			switch Subcommand(i) {
+			case "subscriptions":
+				return listSubscriptions()
+			case "resubscribe":
+				if err := resubscribe(); err != nil {
+					return "", fmt.Errorf("could not resubscribe: %w", err)
+				}
+				return listSubscriptions()
+			case "unsubscribe":
+				id := StringOption(i, "id")
+				if err := twitchws.DeleteEventSubscription(id); err != nil {
+					return "", fmt.Errorf("could not delete subscription %s: %w", id, err)
+				}
+				return fmt.Sprintf("Deleted subscription `%s`.", id), nil
+			case "test-announcement":
+				return c.testAnnouncement(i.ChannelID)
+			case "events":
+				return recentEvents(events, int(IntOption(i, "count", 5))), nil
+			}
+			return "", fmt.Errorf("unknown subcommand")
+		},
+	}
+}
+
+func listSubscriptions() (string, error) {
+	resp, err := twitchws.GetEventSubscriptions()
+	if err != nil {
+		return "", fmt.Errorf("could not get subscriptions: %w", err)
+	}
+	if len(resp.Data) == 0 {
+		return "There are no EventSub subscriptions.", nil
+	}
+	lines := []string{fmt.Sprintf("**%d subscriptions**, total cost %d of %d", resp.Total, resp.TotalCost, resp.MaxTotalCost)}
+	for _, subscription := range resp.Data {
+		status := subscription.Status
+		if status != "enabled" {
+			status = fmt.Sprintf("⚠️ %s", status)
+		}
+		lines = append(lines, fmt.Sprintf("`%s` **%s** v%s — %s, cost %d", subscription.ID, subscription.Type, subscription.Version, status, subscription.Cost))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (c *Client) testAnnouncement(channelID string) (string, error) {
+	resp, err := twitchws.GetChannelInformation()
+	if err != nil {
+		return "", fmt.Errorf("could not get channel information: %w", err)
+	}
+	if len(resp.Data) != 1 {
+		return "", fmt.Errorf("expected 1 channel, got %d", len(resp.Data))
+	}
+	info := resp.Data[0]
+	if _, err := c.transport.SendEmbed(channelID, c.gameEmbed("This is a test announcement.", info.GameName, info.Title)); err != nil {
+		return "", fmt.Errorf("could not send test announcement: %w", err)
+	}
+	return "Sent a test announcement.", nil
+}
+
+func recentEvents(events *twitchws.EventLog, count int) string {
+	received := events.Last(count)
+	if len(received) == 0 {
+		return "No Twitch events were received since jagger started."
+	}
+	lines := []string{fmt.Sprintf("**Last %d Twitch events**", len(received))}
+	for _, event := range received {
+		lines = append(lines, fmt.Sprintf("<t:%d:R> **%s** %s", event.ReceivedAt.Unix(), event.Subscription.Type, eventSummary(event.Notification)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// eventSummary describes who a notification is about in a few words.
+func eventSummary(notification twitchws.Notification) string {
+	event := notification.Event
+	var parts []string
+	if event.BroadcastUsername != "" {
+		parts = append(parts, fmt.Sprintf("broadcaster %s", event.BroadcastUsername))
+	}
+	if event.Username != "" {
+		parts = append(parts, fmt.Sprintf("user %s", event.Username))
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("subscription `%s`", notification.Subscription.ID)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
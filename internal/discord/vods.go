@@ -0,0 +1,246 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	streamSessionsBucket = "stream_sessions"
+	postedVideosBucket   = "posted_videos"
+	videoCursorsBucket   = "video_cursors"
+	// streamSessionRetention is how long a stream's announcement can still get its VOD link.
+	streamSessionRetention = 7 * 24 * time.Hour
+	// postedVideoRetention is how long posted videos are remembered after they were last listed,
+	// a video still among the 20 newest is never forgotten however old it is.
+	postedVideoRetention = 30 * 24 * time.Hour
+	// postedVideoSeenInterval is how often the time a posted video was last listed is updated, so not
+	// every poll rewrites the store.
+	postedVideoSeenInterval = 24 * time.Hour
+	defaultVideoInterval    = 10 * time.Minute
+)
+
+type VODConfig struct {
+	// ChannelID is where new VODs and highlights are posted.
+	ChannelID string
+	// BroadcasterIDs are the Twitch users whose videos are posted.
+	BroadcasterIDs []string
+	// Highlights also posts new highlights, not only past broadcasts.
+	Highlights bool
+	// Interval is how often videos are checked, every 10 minutes if it is 0.
+	Interval time.Duration
+}
+
+// VODPoller posts new past broadcasts and highlights to Discord once the stream they were recorded
+// from has ended, and adds a link to the VOD to the stream's go-live announcement.
+type VODPoller struct {
+	client *Client
+	store  *store.Store
+	config VODConfig
+}
+
+// streamSession is a stream that was announced, to link its VOD from the announcement.
+type streamSession struct {
+	StartedAt     time.Time          `json:"started_at"`
+	Message       string             `json:"message"`
+	GameName      string             `json:"game_name"`
+	Title         string             `json:"title"`
+	Announcements []announcementPost `json:"announcements"`
+}
+
+type announcementPost struct {
+	ChannelID string `json:"channel_id"`
+	MessageID string `json:"message_id"`
+}
+
+func NewVODPoller(client *Client, s *store.Store, config VODConfig) *VODPoller {
+	if config.Interval == 0 {
+		config.Interval = defaultVideoInterval
+	}
+	return &VODPoller{client: client, store: s, config: config}
+}
+
+// StreamStarted records the go-live announcements of streamID, sent by SendMessageEmbed with
+// message, gameName and title, so they can link to the stream's VOD.
+func (p *VODPoller) StreamStarted(streamID, message, gameName, title string, announcements []*discordgo.Message) {
+	session := streamSession{
+		StartedAt: time.Now(),
+		Message:   message,
+		GameName:  gameName,
+		Title:     title,
+	}
+	for _, m := range announcements {
+		session.Announcements = append(session.Announcements, announcementPost{ChannelID: m.ChannelID, MessageID: m.ID})
+	}
+	if err := p.store.Put(streamSessionsBucket, streamID, session); err != nil {
+		log.Printf("error recording stream session %s: %s", streamID, err)
+	}
+}
+
+// Run checks for new videos every Interval until stop is closed.
+func (p *VODPoller) Run(stop chan struct{}) {
+	p.Poll()
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.Poll()
+		}
+	}
+}
+
+// Poll posts the videos published since the last poll.
+func (p *VODPoller) Poll() {
+	// The archive of the live stream is still being recorded, it is posted once the stream ends.
+	var liveStreamID string
+	if stream, err := twitchws.GetStream(); err != nil {
+		log.Printf("error getting stream for VODs: %s", err)
+		return
+	} else if stream != nil {
+		liveStreamID = stream.ID
+	}
+	videoTypes := []string{twitchws.VideoTypeArchive}
+	if p.config.Highlights {
+		videoTypes = append(videoTypes, twitchws.VideoTypeHighlight)
+	}
+	polled := true
+	for _, broadcasterID := range p.config.BroadcasterIDs {
+		for _, videoType := range videoTypes {
+			if err := p.poll(broadcasterID, videoType, liveStreamID); err != nil {
+				log.Printf("error polling %s videos of %s: %s", videoType, broadcasterID, err)
+				polled = false
+			}
+		}
+	}
+	// Videos that could not be listed are not forgotten, they may still be among the newest.
+	p.forget(time.Now(), polled)
+}
+
+func (p *VODPoller) poll(broadcasterID, videoType, liveStreamID string) error {
+	videos, err := twitchws.GetVideos(broadcasterID, videoType)
+	if err != nil {
+		return err
+	}
+	cursorKey := fmt.Sprintf("%s/%s", broadcasterID, videoType)
+	seeded, err := p.store.Get(videoCursorsBucket, cursorKey, new(time.Time))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	// Videos are listed newest first, post them oldest first.
+	for i := len(videos) - 1; i >= 0; i-- {
+		video := videos[i]
+		var lastSeen time.Time
+		if posted, err := p.store.Get(postedVideosBucket, video.ID, &lastSeen); err != nil {
+			continue
+		} else if posted {
+			if now.Sub(lastSeen) >= postedVideoSeenInterval {
+				if err := p.store.Put(postedVideosBucket, video.ID, now); err != nil {
+					log.Printf("error recording posted video %s as seen: %s", video.ID, err)
+				}
+			}
+			continue
+		}
+		if video.StreamID != nil && *video.StreamID == liveStreamID {
+			continue
+		}
+		// The first poll of a broadcaster only records the existing videos.
+		if seeded {
+			if _, err := p.client.transport.SendEmbed(p.config.ChannelID, videoEmbed(video)); err != nil {
+				return fmt.Errorf("error posting video %s: %w", video.ID, err)
+			}
+			if video.StreamID != nil {
+				p.linkVOD(*video.StreamID, video)
+			}
+		}
+		if err := p.store.Put(postedVideosBucket, video.ID, now); err != nil {
+			return err
+		}
+	}
+	if !seeded {
+		return p.store.Put(videoCursorsBucket, cursorKey, now)
+	}
+	return nil
+}
+
+// linkVOD adds a link to video to the go-live announcements of streamID.
+func (p *VODPoller) linkVOD(streamID string, video twitchws.Video) {
+	var session streamSession
+	if found, err := p.store.Get(streamSessionsBucket, streamID, &session); err != nil || !found {
+		return
+	}
+	embed := p.client.gameEmbed(session.Message, session.GameName, session.Title)
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+		Name:   "VOD",
+		Value:  fmt.Sprintf("[Watch the VOD](%s)", video.URL),
+		Inline: true,
+	})
+	for _, announcement := range session.Announcements {
+		if _, err := p.client.transport.EditMessage(&discordgo.MessageEdit{
+			ID:      announcement.MessageID,
+			Channel: announcement.ChannelID,
+			Embeds:  []*discordgo.MessageEmbed{embed},
+		}); err != nil {
+			log.Printf("error linking VOD %s in announcement %s: %s", video.ID, announcement.MessageID, err)
+		}
+	}
+	if err := p.store.Delete(streamSessionsBucket, streamID); err != nil {
+		log.Printf("error deleting stream session %s: %s", streamID, err)
+	}
+}
+
+// forget deletes stream sessions too old to get their VOD link and, if videos is set, the posted
+// videos that were not listed for postedVideoRetention.
+func (p *VODPoller) forget(now time.Time, videos bool) {
+	for _, streamID := range p.store.Keys(streamSessionsBucket) {
+		var session streamSession
+		if _, err := p.store.Get(streamSessionsBucket, streamID, &session); err != nil || now.Sub(session.StartedAt) < streamSessionRetention {
+			continue
+		}
+		if err := p.store.Delete(streamSessionsBucket, streamID); err != nil {
+			log.Printf("error deleting stream session %s: %s", streamID, err)
+		}
+	}
+	if !videos {
+		return
+	}
+	for _, videoID := range p.store.Keys(postedVideosBucket) {
+		var lastSeen time.Time
+		if _, err := p.store.Get(postedVideosBucket, videoID, &lastSeen); err != nil || now.Sub(lastSeen) < postedVideoRetention {
+			continue
+		}
+		if err := p.store.Delete(postedVideosBucket, videoID); err != nil {
+			log.Printf("error forgetting posted video %s: %s", videoID, err)
+		}
+	}
+}
+
+func videoEmbed(video twitchws.Video) *discordgo.MessageEmbed {
+	kind := "Past broadcast"
+	if video.Type == twitchws.VideoTypeHighlight {
+		kind = "Highlight"
+	}
+	duration := video.Duration
+	if d, err := time.ParseDuration(video.Duration); err == nil {
+		duration = d.String()
+	}
+	return &discordgo.MessageEmbed{
+		Title:  truncate(video.Title, 256),
+		URL:    video.URL,
+		Author: &discordgo.MessageEmbedAuthor{Name: fmt.Sprintf("New %s from %s", kind, video.UserName)},
+		Color:  0x9146ff,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Duration", Value: duration, Inline: true},
+		},
+		Image:     &discordgo.MessageEmbedImage{URL: video.Thumbnail(640, 360)},
+		Timestamp: video.CreatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,27 @@
+package discord
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVODPollerForgetsVideosNoLongerListed(t *testing.T) {
+	client, _, s := newTestClient(t)
+	p := NewVODPoller(client, s, VODConfig{})
+	now := time.Now()
+	if err := s.Put(postedVideosBucket, "listed", now.Add(-postedVideoSeenInterval)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(postedVideosBucket, "gone", now.Add(-postedVideoRetention)); err != nil {
+		t.Fatal(err)
+	}
+
+	p.forget(now, false)
+	if keys := s.Keys(postedVideosBucket); len(keys) != 2 {
+		t.Fatalf("forgot videos %v after a failed poll, want none forgotten", keys)
+	}
+	p.forget(now, true)
+	if keys := s.Keys(postedVideosBucket); len(keys) != 1 || keys[0] != "listed" {
+		t.Fatalf("remembered videos %v, want only the one listed recently", keys)
+	}
+}
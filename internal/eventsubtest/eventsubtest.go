@@ -0,0 +1,308 @@
+// Package eventsubtest simulates Twitch EventSub webhook deliveries so the callback handler and
+// everything behind it can be exercised without a real Twitch subscription.
+package eventsubtest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/discord"
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+	"github.com/brandonlbarrow/jaggerbot/internal/webserver"
+)
+
+const (
+	MessageTypeVerification = "webhook_callback_verification"
+	MessageTypeNotification = "notification"
+	MessageTypeRevocation   = "revocation"
+)
+
+// DefaultBroadcasterID is the broadcaster the sample events are about unless another one is set.
+const DefaultBroadcasterID = "12826"
+
+// Sign returns the Twitch-Eventsub-Message-Signature header value for a message, using the same
+// HMAC-SHA256 scheme Twitch uses and the webserver verifies.
+func Sign(secret, messageID, timestamp string, body []byte) string {
+	hm := hmac.New(sha256.New, []byte(secret))
+	hm.Write([]byte(messageID))
+	hm.Write([]byte(timestamp))
+	hm.Write(body)
+	return fmt.Sprint("sha256=", hex.EncodeToString(hm.Sum(nil)))
+}
+
+// Simulator delivers signed EventSub messages to a callback URL.
+type Simulator struct {
+	CallbackURL   string
+	Secret        string
+	BroadcasterID string
+	Client        *http.Client
+}
+
+// SubscriptionTypes returns the subscription types the simulator has sample events for.
+func SubscriptionTypes() []string {
+	types := make([]string, 0, len(sampleEvents))
+	for subscriptionType := range sampleEvents {
+		types = append(types, subscriptionType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Subscription returns a subscription of subscriptionType for the simulator's broadcaster.
+func (s *Simulator) Subscription(subscriptionType, status string) twitchws.Subscription {
+	version := "1"
+	if sample, ok := sampleEvents[subscriptionType]; ok {
+		version = sample.version
+	}
+	return twitchws.Subscription{
+		ID:        randomID(),
+		Type:      subscriptionType,
+		Version:   version,
+		Status:    status,
+		Condition: map[string]string{"broadcaster_user_id": s.broadcasterID()},
+		Transport: twitchws.SubscriptionTransport{Method: "webhook", Callback: s.CallbackURL},
+		CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// Verify sends a webhook_callback_verification message for subscriptionType and checks that the
+// callback echoed the challenge.
+func (s *Simulator) Verify(subscriptionType string) error {
+	challenge := randomID()
+	body, err := json.Marshal(map[string]any{
+		"challenge":    challenge,
+		"subscription": s.Subscription(subscriptionType, "webhook_callback_verification_pending"),
+	})
+	if err != nil {
+		return err
+	}
+	resp, respBody, err := s.Send(MessageTypeVerification, body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("verification of %s returned %s", subscriptionType, resp.Status)
+	}
+	if string(respBody) != challenge {
+		return fmt.Errorf("verification of %s returned %q instead of the challenge %q", subscriptionType, respBody, challenge)
+	}
+	return nil
+}
+
+// Notify sends a notification with the sample event of subscriptionType.
+func (s *Simulator) Notify(subscriptionType string) (*http.Response, error) {
+	sample, ok := sampleEvents[subscriptionType]
+	if !ok {
+		return nil, fmt.Errorf("no sample event for %s", subscriptionType)
+	}
+	return s.NotifyEvent(subscriptionType, sample.event(s.broadcasterID()))
+}
+
+// NotifyEvent sends a notification for subscriptionType with event as its payload.
+func (s *Simulator) NotifyEvent(subscriptionType string, event any) (*http.Response, error) {
+	body, err := json.Marshal(map[string]any{
+		"subscription": s.Subscription(subscriptionType, "enabled"),
+		"event":        event,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, _, err := s.Send(MessageTypeNotification, body)
+	return resp, err
+}
+
+// Revoke sends a revocation for subscriptionType with status as the reason, e.g.
+// "authorization_revoked" or "notification_failures_exceeded".
+func (s *Simulator) Revoke(subscriptionType, status string) (*http.Response, error) {
+	body, err := json.Marshal(map[string]any{
+		"subscription": s.Subscription(subscriptionType, status),
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, _, err := s.Send(MessageTypeRevocation, body)
+	return resp, err
+}
+
+// Replay sends a captured message body again, signed with the simulator's secret. The message type
+// is derived from the body: bodies with a challenge are verifications, bodies with an event are
+// notifications and anything else is a revocation.
+func (s *Simulator) Replay(body []byte) (*http.Response, error) {
+	var captured struct {
+		Challenge string          `json:"challenge"`
+		Event     json.RawMessage `json:"event"`
+	}
+	if err := json.Unmarshal(body, &captured); err != nil {
+		return nil, fmt.Errorf("error decoding captured payload: %w", err)
+	}
+	messageType := MessageTypeRevocation
+	switch {
+	case captured.Challenge != "":
+		messageType = MessageTypeVerification
+	case len(captured.Event) > 0:
+		messageType = MessageTypeNotification
+	}
+	resp, _, err := s.Send(messageType, body)
+	return resp, err
+}
+
+// Send delivers body as a signed message of messageType and returns the response and its body.
+func (s *Simulator) Send(messageType string, body []byte) (*http.Response, []byte, error) {
+	messageID := randomID()
+	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
+	req, err := http.NewRequest(http.MethodPost, s.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webserver.TwitchEventsubMessageIDHeader, messageID)
+	req.Header.Set(webserver.TwitchEventsubMessageTimestampHeader, timestamp)
+	req.Header.Set(webserver.TwitchEventsubMessageSignatureHeader, Sign(s.Secret, messageID, timestamp, body))
+	req.Header.Set(webserver.TwitchEventsubMessageTypeHeader, messageType)
+	req.Header.Set(webserver.TwitchEventsubSubscriptionTypeHeader, subscriptionType(body))
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error sending %s message: %w", messageType, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, nil, fmt.Errorf("error reading %s response: %w", messageType, err)
+	}
+	return resp, respBody, nil
+}
+
+func (s *Simulator) broadcasterID() string {
+	if s.BroadcasterID != "" {
+		return s.BroadcasterID
+	}
+	return DefaultBroadcasterID
+}
+
+// The Discord guild and channels of a Pipeline.
+const (
+	GuildID               = "guild"
+	AnnouncementChannelID = "announcements"
+	AdminChannelID        = "admin"
+)
+
+// The channel information a Pipeline announces the stream with.
+const (
+	ChannelTitle = "Sample stream"
+	ChannelGame  = "Sample game"
+)
+
+// Pipeline is a webserver.Handler served by an httptest.Server with a Simulator pointed at it. The
+// notifications the handler emits are passed to Dispatcher, which talks to Discord through Recorder,
+// and then delivered on Notifications. Errors the handler emits are delivered on Errors.
+type Pipeline struct {
+	*Simulator
+	Server   *httptest.Server
+	Recorder *discord.Recorder
+	// Dispatcher has no features enabled except the go-live announcement, set its fields before
+	// sending notifications to enable more.
+	Dispatcher    *discord.Dispatcher
+	Notifications chan twitchws.Notification
+	Errors        chan error
+
+	events chan twitchws.Notification
+	closed chan struct{}
+}
+
+// NewPipeline starts a callback handler accepting secret. Close it when done.
+func NewPipeline(secret string) *Pipeline {
+	p := &Pipeline{
+		Recorder:      discord.NewRecorder(),
+		Notifications: make(chan twitchws.Notification, 16),
+		Errors:        make(chan error, 16),
+		events:        make(chan twitchws.Notification),
+		closed:        make(chan struct{}),
+	}
+	// NewClient only fails creating a session, which it does not with a Recorder.
+	client, _ := discord.NewClient(&discord.Config{
+		Transport:         p.Recorder,
+		DiscordGuildID:    GuildID,
+		DiscordChannelIDs: []string{AnnouncementChannelID},
+		AdminChannelIDs:   []string{AdminChannelID},
+	})
+	p.Dispatcher = &discord.Dispatcher{
+		Client:        client,
+		Notifier:      discord.NewAdminNotifier(client, discord.NotifierConfig{}),
+		BroadcasterID: DefaultBroadcasterID,
+		ChannelInformation: func() (*twitchws.GetChannelInformationResponse, error) {
+			return &twitchws.GetChannelInformationResponse{Data: []twitchws.ChannelInfo{{
+				BroadcasterID: DefaultBroadcasterID,
+				Title:         ChannelTitle,
+				GameName:      ChannelGame,
+			}}}, nil
+		},
+	}
+	handler := &webserver.Handler{
+		EventChannel:      p.events,
+		ErrorEventChannel: p.Errors,
+		Secrets:           webserver.StaticSecrets{secret},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jagger/callback", handler.HandleTwitchCallback)
+	p.Server = httptest.NewServer(mux)
+	p.Simulator = &Simulator{
+		CallbackURL: p.Server.URL + "/jagger/callback",
+		Secret:      secret,
+		Client:      p.Server.Client(),
+	}
+	go p.dispatch()
+	return p
+}
+
+// dispatch dispatches every notification from the handler and then delivers it on Notifications.
+// After Close notifications are dropped until the handler is done.
+func (p *Pipeline) dispatch() {
+	for notification := range p.events {
+		select {
+		case <-p.closed:
+			continue
+		default:
+		}
+		p.Dispatcher.Dispatch(notification)
+		select {
+		case p.Notifications <- notification:
+		case <-p.closed:
+		}
+	}
+}
+
+func (p *Pipeline) Close() {
+	close(p.closed)
+	p.Server.Close()
+	close(p.events)
+}
+
+func subscriptionType(body []byte) string {
+	var message struct {
+		Subscription struct {
+			Type string `json:"type"`
+		} `json:"subscription"`
+	}
+	json.Unmarshal(body, &message)
+	return message.Subscription.Type
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
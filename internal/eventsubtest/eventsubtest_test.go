@@ -0,0 +1,64 @@
+package eventsubtest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+)
+
+func TestStreamOnlineIsAnnounced(t *testing.T) {
+	p := NewPipeline("pipeline-secret")
+	defer p.Close()
+
+	resp, err := p.Notify(twitchws.EventTypeStreamOnline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("notification returned %s", resp.Status)
+	}
+	select {
+	case notification := <-p.Notifications:
+		if notification.Subscription.Type != twitchws.EventTypeStreamOnline {
+			t.Fatalf("dispatched %s, want %s", notification.Subscription.Type, twitchws.EventTypeStreamOnline)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the notification was not dispatched")
+	}
+
+	announcements := p.Recorder.Messages(AnnouncementChannelID)
+	if len(announcements) != 1 {
+		t.Fatalf("sent %d announcements, want 1", len(announcements))
+	}
+	for _, message := range announcements {
+		if len(message.Embeds) != 1 {
+			t.Fatalf("announcement has %d embeds, want 1", len(message.Embeds))
+		}
+		embed := message.Embeds[0]
+		if embed.Title != ChannelTitle {
+			t.Errorf("announcement title is %q, want %q", embed.Title, ChannelTitle)
+		}
+		if len(embed.Fields) != 1 || embed.Fields[0].Value != ChannelGame {
+			t.Errorf("announcement fields are %v, want the game %q", embed.Fields, ChannelGame)
+		}
+	}
+}
+
+func TestBadSignatureIsNotDispatched(t *testing.T) {
+	p := NewPipeline("pipeline-secret")
+	defer p.Close()
+	p.Secret = "another-secret"
+
+	resp, err := p.Notify(twitchws.EventTypeStreamOnline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("notification returned %s, want 403", resp.Status)
+	}
+	if messages := p.Recorder.Messages(AnnouncementChannelID); len(messages) != 0 {
+		t.Fatalf("sent %d messages for a rejected notification", len(messages))
+	}
+}
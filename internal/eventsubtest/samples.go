@@ -0,0 +1,285 @@
+package eventsubtest
+
+import "time"
+
+type sampleEvent struct {
+	version string
+	event   func(broadcasterID string) map[string]any
+}
+
+// sampleEvents are realistic events for every subscription type jagger handles, modeled on the
+// examples in the Twitch EventSub reference.
+var sampleEvents = map[string]sampleEvent{
+	"stream.online": {"1", func(broadcasterID string) map[string]any {
+		return map[string]any{
+			"id":                     "9001",
+			"broadcaster_user_id":    broadcasterID,
+			"broadcaster_user_login": "sensaiopti",
+			"broadcaster_user_name":  "SensaiOpti",
+			"type":                   "live",
+			"started_at":             now(),
+		}
+	}},
+	"stream.offline": {"1", func(broadcasterID string) map[string]any {
+		return broadcaster(broadcasterID)
+	}},
+	"channel.update": {"2", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), map[string]any{
+			"title":                         "Best Stream Ever",
+			"language":                      "en",
+			"category_id":                   "12453",
+			"category_name":                 "Grand Theft Auto",
+			"content_classification_labels": []string{"MatureGame"},
+		})
+	}},
+	"channel.raid": {"1", func(broadcasterID string) map[string]any {
+		return map[string]any{
+			"from_broadcaster_user_id":    "1234",
+			"from_broadcaster_user_login": "cool_user",
+			"from_broadcaster_user_name":  "Cool_User",
+			"to_broadcaster_user_id":      broadcasterID,
+			"to_broadcaster_user_login":   "sensaiopti",
+			"to_broadcaster_user_name":    "SensaiOpti",
+			"viewers":                     9001,
+		}
+	}},
+	"channel.subscribe": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), viewer(), map[string]any{
+			"tier":    "1000",
+			"is_gift": false,
+		})
+	}},
+	"channel.subscription.end": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), viewer(), map[string]any{
+			"tier":    "1000",
+			"is_gift": false,
+		})
+	}},
+	"channel.follow": {"2", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), viewer(), map[string]any{
+			"followed_at": now(),
+		})
+	}},
+	"channel.subscription.message": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), viewer(), map[string]any{
+			"tier": "1000",
+			"message": map[string]any{
+				"text":   "Love the stream! FevziGG",
+				"emotes": []map[string]any{{"begin": 23, "end": 30, "id": "302976485"}},
+			},
+			"cumulative_months": 15,
+			"streak_months":     1,
+			"duration_months":   6,
+		})
+	}},
+	"channel.subscription.gift": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), viewer(), map[string]any{
+			"total":            2,
+			"tier":             "1000",
+			"cumulative_total": 284,
+			"is_anonymous":     false,
+		})
+	}},
+	"channel.cheer": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), viewer(), map[string]any{
+			"is_anonymous": false,
+			"message":      "pogchamp",
+			"bits":         1000,
+		})
+	}},
+	"channel.poll.begin": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), poll(0, 0), map[string]any{
+			"ends_at": later(),
+		})
+	}},
+	"channel.poll.progress": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), poll(12, 7), map[string]any{
+			"ends_at": later(),
+		})
+	}},
+	"channel.poll.end": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), poll(21, 9), map[string]any{
+			"status":   "completed",
+			"ended_at": now(),
+		})
+	}},
+	"channel.prediction.begin": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), prediction(0, 0), map[string]any{
+			"locks_at": later(),
+		})
+	}},
+	"channel.prediction.progress": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), prediction(5000, 1200), map[string]any{
+			"locks_at": later(),
+		})
+	}},
+	"channel.prediction.lock": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), prediction(15000, 8000), map[string]any{
+			"locked_at": now(),
+		})
+	}},
+	"channel.prediction.end": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), prediction(15000, 8000), map[string]any{
+			"winning_outcome_id": "1243456",
+			"status":             "resolved",
+			"ended_at":           now(),
+		})
+	}},
+	"channel.hype_train.begin": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), hypeTrain(1, 137), map[string]any{
+			"progress":   137,
+			"goal":       500,
+			"expires_at": later(),
+		})
+	}},
+	"channel.hype_train.progress": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), hypeTrain(2, 700), map[string]any{
+			"progress":   200,
+			"goal":       1000,
+			"expires_at": later(),
+		})
+	}},
+	"channel.hype_train.end": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), hypeTrain(2, 700), map[string]any{
+			"ended_at":         now(),
+			"cooldown_ends_at": later(),
+		})
+	}},
+	"channel.channel_points_custom_reward_redemption.add": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), viewer(), map[string]any{
+			"id":         "17fa2df1-ad76-4804-bfa5-a40ef63efe63",
+			"user_input": "pogchamp",
+			"status":     "unfulfilled",
+			"reward": map[string]any{
+				"id":     "92af127c-7326-4483-a52b-b0da0be61c01",
+				"title":  "Hydrate",
+				"cost":   100,
+				"prompt": "Make Sensai drink some water",
+			},
+			"redeemed_at": now(),
+		})
+	}},
+	"channel.ban": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), viewer(), moderator(), map[string]any{
+			"reason":       "Offensive language",
+			"banned_at":    now(),
+			"ends_at":      later(),
+			"is_permanent": false,
+		})
+	}},
+	"channel.unban": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), viewer(), moderator())
+	}},
+	"channel.moderator.add": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), viewer())
+	}},
+	"channel.moderator.remove": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), viewer())
+	}},
+	"automod.message.hold": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), viewer(), map[string]any{
+			"message_id": "bad-message-id",
+			"message": map[string]any{
+				"text":      "This is a bad message… pogchamp",
+				"fragments": []map[string]any{{"type": "text", "text": "This is a bad message… pogchamp"}},
+			},
+			"category": "aggressive",
+			"level":    1,
+			"held_at":  now(),
+		})
+	}},
+	"automod.message.update": {"1", func(broadcasterID string) map[string]any {
+		return with(broadcaster(broadcasterID), viewer(), moderator(), map[string]any{
+			"message_id": "bad-message-id",
+			"message": map[string]any{
+				"text":      "This is a bad message… pogchamp",
+				"fragments": []map[string]any{{"type": "text", "text": "This is a bad message… pogchamp"}},
+			},
+			"category": "aggressive",
+			"level":    1,
+			"status":   "Approved",
+			"held_at":  now(),
+		})
+	}},
+}
+
+func moderator() map[string]any {
+	return map[string]any{
+		"moderator_user_id":    "1339",
+		"moderator_user_login": "mod_user",
+		"moderator_user_name":  "Mod_User",
+	}
+}
+
+func hypeTrain(level, total int) map[string]any {
+	return map[string]any{
+		"id":    "1b0AsbInCHZW2SQFQkCzqN07Ib2",
+		"level": level,
+		"total": total,
+		"top_contributions": []map[string]any{
+			{"user_id": "123", "user_login": "pogchamp", "user_name": "PogChamp", "type": "bits", "total": total / 2},
+			{"user_id": "456", "user_login": "kappa", "user_name": "Kappa", "type": "subscription", "total": 45},
+		},
+		"last_contribution": map[string]any{"user_id": "123", "user_login": "pogchamp", "user_name": "PogChamp", "type": "bits", "total": 50},
+		"started_at":        now(),
+	}
+}
+
+func poll(yes, no int) map[string]any {
+	return map[string]any{
+		"id":    "1243456",
+		"title": "Aren't shoes just really hard socks?",
+		"choices": []map[string]any{
+			{"id": "123", "title": "Yeah!", "votes": yes, "channel_points_votes": 0},
+			{"id": "124", "title": "No!", "votes": no, "channel_points_votes": 0},
+		},
+		"channel_points_voting": map[string]any{"is_enabled": true, "amount_per_vote": 10},
+		"started_at":            now(),
+	}
+}
+
+func prediction(bluePoints, pinkPoints int) map[string]any {
+	return map[string]any{
+		"id":    "1243456",
+		"title": "Aren't shoes just really hard socks?",
+		"outcomes": []map[string]any{
+			{"id": "1243456", "title": "Yeah!", "color": "blue", "users": bluePoints / 100, "channel_points": bluePoints},
+			{"id": "2243456", "title": "No!", "color": "pink", "users": pinkPoints / 100, "channel_points": pinkPoints},
+		},
+		"started_at": now(),
+	}
+}
+
+func broadcaster(broadcasterID string) map[string]any {
+	return map[string]any{
+		"broadcaster_user_id":    broadcasterID,
+		"broadcaster_user_login": "sensaiopti",
+		"broadcaster_user_name":  "SensaiOpti",
+	}
+}
+
+func viewer() map[string]any {
+	return map[string]any{
+		"user_id":    "1234",
+		"user_login": "cool_user",
+		"user_name":  "Cool_User",
+	}
+}
+
+func with(fields ...map[string]any) map[string]any {
+	event := make(map[string]any)
+	for _, f := range fields {
+		for k, v := range f {
+			event[k] = v
+		}
+	}
+	return event
+}
+
+func later() string {
+	return time.Now().Add(5 * time.Minute).UTC().Format(time.RFC3339Nano)
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,162 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
+)
+
+const (
+	eventSubSecretsBucket = "eventsub_secrets"
+	eventSubSecretsKey    = "secrets"
+)
+
+type eventSubSecretsState struct {
+	Current         string    `json:"current"`
+	Generation      int       `json:"generation"`
+	Previous        string    `json:"previous,omitempty"`
+	PreviousExpires time.Time `json:"previous_expires,omitempty"`
+	RotatedAt       time.Time `json:"rotated_at"`
+}
+
+// EventSubSecrets is the set of secrets EventSub webhook notifications may be signed with. After a
+// rotation the previous secret is still accepted until its overlap period ends, so notifications
+// in flight for the old subscriptions are not rejected. The state is kept encrypted in the store.
+type EventSubSecrets struct {
+	store    *store.Store
+	keystore *Keystore
+
+	mu    sync.Mutex
+	state eventSubSecretsState
+	// rotatedFrom is the state before the last rotation, so it can be reverted.
+	rotatedFrom *eventSubSecretsState
+}
+
+// LoadEventSubSecrets loads the secrets from s. Until the first rotation the current secret is
+// initial, it replaces the stored one if it was changed. Without a keystore nothing is stored,
+// initial stays the current secret and Rotate fails.
+func LoadEventSubSecrets(s *store.Store, keystore *Keystore, initial string) (*EventSubSecrets, error) {
+	e := &EventSubSecrets{store: s, keystore: keystore}
+	if keystore == nil {
+		e.state = eventSubSecretsState{Current: initial, RotatedAt: time.Now()}
+		return e, nil
+	}
+	var sealed string
+	found, err := s.Get(eventSubSecretsBucket, eventSubSecretsKey, &sealed)
+	if err != nil {
+		return nil, fmt.Errorf("error reading eventsub secrets: %w", err)
+	}
+	if !found {
+		// The state is saved right away, otherwise RotatedAt would restart with every restart and a
+		// bot restarted more often than the rotation interval would never rotate.
+		e.state = eventSubSecretsState{Current: initial, RotatedAt: time.Now()}
+		if err := e.save(); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	raw, err := keystore.Open(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting eventsub secrets: %w", err)
+	}
+	if err := json.Unmarshal(raw, &e.state); err != nil {
+		return nil, fmt.Errorf("error decoding eventsub secrets: %w", err)
+	}
+	if e.state.Generation == 0 && e.state.Current != initial {
+		e.state = eventSubSecretsState{Current: initial, RotatedAt: time.Now()}
+		if err := e.save(); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Current returns the secret new subscriptions are created with and its generation, which is
+// increased by every rotation.
+func (e *EventSubSecrets) Current() (string, int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.state.Current, e.state.Generation
+}
+
+// Accepted returns the secrets notifications may currently be signed with.
+func (e *EventSubSecrets) Accepted() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	accepted := []string{e.state.Current}
+	if e.state.Previous != "" && time.Now().Before(e.state.PreviousExpires) {
+		accepted = append(accepted, e.state.Previous)
+	}
+	return accepted
+}
+
+// RotatedAt returns when the current secret was created.
+func (e *EventSubSecrets) RotatedAt() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.state.RotatedAt
+}
+
+// Rotate generates a new current secret, keeps accepting the previous one for overlap and returns
+// the new secret and its generation.
+func (e *EventSubSecrets) Rotate(overlap time.Duration) (string, int, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", 0, fmt.Errorf("error generating eventsub secret: %w", err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	previous := e.state
+	e.state = eventSubSecretsState{
+		Current:         hex.EncodeToString(b),
+		Generation:      previous.Generation + 1,
+		Previous:        previous.Current,
+		PreviousExpires: time.Now().Add(overlap),
+		RotatedAt:       time.Now(),
+	}
+	if err := e.save(); err != nil {
+		e.state = previous
+		return "", 0, err
+	}
+	e.rotatedFrom = &previous
+	return e.state.Current, e.state.Generation, nil
+}
+
+// Revert undoes the rotation that created generation, when its subscriptions could not be created
+// and the subscriptions with the previous secret were kept.
+func (e *EventSubSecrets) Revert(generation int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.rotatedFrom == nil || e.state.Generation != generation {
+		return fmt.Errorf("generation %d is not the last rotation", generation)
+	}
+	rotated := e.state
+	e.state = *e.rotatedFrom
+	if err := e.save(); err != nil {
+		e.state = rotated
+		return err
+	}
+	e.rotatedFrom = nil
+	return nil
+}
+
+// save writes the state to the store. e.mu must be held.
+func (e *EventSubSecrets) save() error {
+	raw, err := json.Marshal(&e.state)
+	if err != nil {
+		return fmt.Errorf("error encoding eventsub secrets: %w", err)
+	}
+	sealed, err := e.keystore.Seal(raw)
+	if err != nil {
+		return fmt.Errorf("error encrypting eventsub secrets: %w", err)
+	}
+	if err := e.store.Put(eventSubSecretsBucket, eventSubSecretsKey, sealed); err != nil {
+		return fmt.Errorf("error storing eventsub secrets: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package secrets
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEventSubSecretsRotation(t *testing.T) {
+	s := newTestStore(t)
+	keystore, err := NewKeystore(s, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := LoadEventSubSecrets(s, keystore, "initial")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current, generation := e.Current(); current != "initial" || generation != 0 {
+		t.Fatalf("Current returned %q, %d, want initial, 0", current, generation)
+	}
+
+	current, generation, err := e.Rotate(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if generation != 1 || current == "initial" {
+		t.Fatalf("Rotate returned %q, %d", current, generation)
+	}
+	if accepted := e.Accepted(); !reflect.DeepEqual(accepted, []string{current, "initial"}) {
+		t.Fatalf("Accepted within the overlap returned %v", accepted)
+	}
+
+	// The rotation is kept across restarts, and the changed initial secret does not replace it.
+	reloaded, err := LoadEventSubSecrets(s, keystore, "initial")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := reloaded.Current(); got != current {
+		t.Fatalf("reloaded Current returned %q, want %q", got, current)
+	}
+
+	if err := e.Revert(2); err == nil {
+		t.Fatal("Revert of a generation that was not rotated returned no error")
+	}
+	if err := e.Revert(generation); err != nil {
+		t.Fatal(err)
+	}
+	if got, generation := e.Current(); got != "initial" || generation != 0 {
+		t.Fatalf("Current after Revert returned %q, %d, want initial, 0", got, generation)
+	}
+	if accepted := e.Accepted(); !reflect.DeepEqual(accepted, []string{"initial"}) {
+		t.Fatalf("Accepted after Revert returned %v", accepted)
+	}
+}
+
+func TestEventSubSecretsOverlapEnds(t *testing.T) {
+	s := newTestStore(t)
+	keystore, err := NewKeystore(s, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := LoadEventSubSecrets(s, keystore, "initial")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, _, err := e.Rotate(-time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accepted := e.Accepted(); !reflect.DeepEqual(accepted, []string{current}) {
+		t.Fatalf("Accepted after the overlap returned %v, want only the current secret", accepted)
+	}
+}
+
+func TestEventSubSecretsWithoutKeystore(t *testing.T) {
+	e, err := LoadEventSubSecrets(newTestStore(t), nil, "initial")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := e.Rotate(time.Hour); err == nil {
+		t.Fatal("Rotate without a keystore returned no error")
+	}
+	if current, _ := e.Current(); current != "initial" {
+		t.Fatalf("Current after the failed rotation returned %q, want initial", current)
+	}
+}
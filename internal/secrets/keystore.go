@@ -0,0 +1,95 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	keystoreBucket  = "keystore"
+	keystoreSaltKey = "salt"
+)
+
+// ErrNoKeystore is returned when sealing or opening a value without a keystore.
+var ErrNoKeystore = errors.New("no keystore is configured, JAGGER_KEYSTORE_KEY is not set")
+
+// Keystore encrypts values such as OAuth refresh tokens before they are written to the store, using
+// AES-256-GCM with a key derived from a passphrase. A nil Keystore seals and opens nothing.
+type Keystore struct {
+	aead cipher.AEAD
+}
+
+// NewKeystore derives the encryption key from passphrase and a random salt kept in s, creating the
+// salt the first time.
+func NewKeystore(s *store.Store, passphrase string) (*Keystore, error) {
+	if passphrase == "" {
+		return nil, errors.New("keystore passphrase is empty")
+	}
+	var salt []byte
+	found, err := s.Get(keystoreBucket, keystoreSaltKey, &salt)
+	if err != nil {
+		return nil, fmt.Errorf("error reading keystore salt: %w", err)
+	}
+	if !found {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("error generating keystore salt: %w", err)
+		}
+		if err := s.Put(keystoreBucket, keystoreSaltKey, salt); err != nil {
+			return nil, fmt.Errorf("error storing keystore salt: %w", err)
+		}
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving keystore key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating keystore cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("error creating keystore cipher: %w", err)
+	}
+	return &Keystore{aead: aead}, nil
+}
+
+// Seal encrypts plaintext and returns it base64 encoded.
+func (k *Keystore) Seal(plaintext []byte) (string, error) {
+	if k == nil {
+		return "", ErrNoKeystore
+	}
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error generating nonce: %w", err)
+	}
+	sealed := k.aead.Seal(nonce, nonce, plaintext, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value returned by Seal.
+func (k *Keystore) Open(ciphertext string) ([]byte, error) {
+	if k == nil {
+		return nil, ErrNoKeystore
+	}
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding sealed value: %w", err)
+	}
+	if len(sealed) < k.aead.NonceSize() {
+		return nil, errors.New("sealed value is too short")
+	}
+	nonce, sealed := sealed[:k.aead.NonceSize()], sealed[k.aead.NonceSize():]
+	plaintext, err := k.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting sealed value, was the keystore passphrase changed?: %w", err)
+	}
+	return plaintext, nil
+}
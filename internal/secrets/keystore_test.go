@@ -0,0 +1,67 @@
+package secrets
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
+)
+
+func newTestStore(t *testing.T) *store.Store {
+	t.Helper()
+	s, err := store.Open(filepath.Join(t.TempDir(), "jagger.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestKeystoreRoundTrip(t *testing.T) {
+	keystore, err := NewKeystore(newTestStore(t), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := keystore.Seal([]byte("refresh-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealed == "refresh-token" {
+		t.Fatal("Seal returned the plaintext")
+	}
+	plaintext, err := keystore.Open(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "refresh-token" {
+		t.Fatalf("Open returned %q, want refresh-token", plaintext)
+	}
+}
+
+func TestKeystoreOpenFailsWithWrongPassphrase(t *testing.T) {
+	s := newTestStore(t)
+	keystore, err := NewKeystore(s, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := keystore.Seal([]byte("refresh-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewKeystore(s, "battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Open(sealed); err == nil {
+		t.Fatal("Open with the wrong passphrase returned no error")
+	}
+}
+
+func TestNilKeystore(t *testing.T) {
+	var keystore *Keystore
+	if _, err := keystore.Seal([]byte("x")); err != ErrNoKeystore {
+		t.Fatalf("Seal returned %v, want ErrNoKeystore", err)
+	}
+	if _, err := keystore.Open("x"); err != ErrNoKeystore {
+		t.Fatalf("Open returned %v, want ErrNoKeystore", err)
+	}
+}
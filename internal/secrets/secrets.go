@@ -0,0 +1,42 @@
+package secrets
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultSecretsDir is where Docker and Kubernetes mount secret files by default.
+const defaultSecretsDir = "/run/secrets"
+
+// Get returns the secret name. It is read from the file named by the NAME_FILE environment
+// variable if set, otherwise from a file named after the lowercased secret in the secrets directory
+// (JAGGER_SECRETS_DIR, /run/secrets by default), and otherwise from the NAME environment variable,
+// so plaintext .env files keep working. Get returns "" if the secret is not set anywhere.
+func Get(name string) string {
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		return readSecretFile(name, path)
+	}
+	dir := os.Getenv("JAGGER_SECRETS_DIR")
+	if dir == "" {
+		dir = defaultSecretsDir
+	}
+	path := filepath.Join(dir, strings.ToLower(name))
+	if _, err := os.Stat(path); err == nil {
+		return readSecretFile(name, path)
+	}
+	return os.Getenv(name)
+}
+
+func readSecretFile(name, path string) string {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("error reading secret %s from %s: %s", name, path, err)
+		}
+		return ""
+	}
+	return strings.TrimSpace(string(raw))
+}
@@ -0,0 +1,37 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("JAGGER_SECRETS_DIR", dir)
+	t.Setenv("JAGGER_TEST_SECRET", "from-env")
+	if got := Get("JAGGER_TEST_SECRET"); got != "from-env" {
+		t.Fatalf("Get returned %q, want the environment variable", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "jagger_test_secret"), []byte("from-dir\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := Get("JAGGER_TEST_SECRET"); got != "from-dir" {
+		t.Fatalf("Get returned %q, want the secrets directory file", got)
+	}
+
+	file := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(file, []byte("  from-file  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("JAGGER_TEST_SECRET_FILE", file)
+	if got := Get("JAGGER_TEST_SECRET"); got != "from-file" {
+		t.Fatalf("Get returned %q, want the _FILE file", got)
+	}
+
+	t.Setenv("JAGGER_TEST_SECRET_FILE", filepath.Join(dir, "missing"))
+	if got := Get("JAGGER_TEST_SECRET"); got != "" {
+		t.Fatalf("Get with a missing _FILE file returned %q, want nothing", got)
+	}
+}
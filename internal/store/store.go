@@ -0,0 +1,111 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Store is a small persistent key/value store backed by a single JSON file. Values are grouped in
+// buckets and every write rewrites the file, which is fine for the amount of state jagger keeps.
+type Store struct {
+	path string
+
+	mu   sync.Mutex
+	data map[string]map[string]json.RawMessage
+}
+
+// Open loads the store at path, creating an empty one if the file does not exist yet.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path: path,
+		data: make(map[string]map[string]json.RawMessage),
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading store %s: %w", path, err)
+	}
+	if err := json.Unmarshal(raw, &s.data); err != nil {
+		return nil, fmt.Errorf("error decoding store %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Get decodes the value stored under key in bucket into v and reports whether it was found.
+func (s *Store) Get(bucket, key string, v any) (bool, error) {
+	s.mu.Lock()
+	raw, ok := s.data[bucket][key]
+	s.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return true, fmt.Errorf("error decoding %s/%s: %w", bucket, key, err)
+	}
+	return true, nil
+}
+
+// Put stores v under key in bucket and writes the store to disk.
+func (s *Store) Put(bucket, key string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error encoding %s/%s: %w", bucket, key, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data[bucket] == nil {
+		s.data[bucket] = make(map[string]json.RawMessage)
+	}
+	s.data[bucket][key] = raw
+	return s.save()
+}
+
+// Delete removes key from bucket and writes the store to disk.
+func (s *Store) Delete(bucket, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data[bucket][key]; !ok {
+		return nil
+	}
+	delete(s.data[bucket], key)
+	return s.save()
+}
+
+// Keys returns the sorted keys of bucket.
+func (s *Store) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.data[bucket]))
+	for key := range s.data[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// save writes the store to a temporary file and renames it over the store so a crash never leaves
+// a partially written file behind. s.mu must be held.
+func (s *Store) save() error {
+	raw, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding store: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("error creating store directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("error writing store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("error replacing store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStoreSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jagger.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[string]int{"b": 2, "a": 1, "c": 3} {
+		if err := s.Put("numbers", key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Delete("numbers", "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := s.Keys("numbers"); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Fatalf("Keys returned %v, want [a b]", keys)
+	}
+	var value int
+	if found, err := s.Get("numbers", "b", &value); err != nil || !found || value != 2 {
+		t.Fatalf("Get returned %d, %t, %v, want 2, true, nil", value, found, err)
+	}
+	if found, err := s.Get("numbers", "c", &value); err != nil || found {
+		t.Fatalf("Get of a deleted key returned %t, %v, want false, nil", found, err)
+	}
+	if found, err := s.Get("missing", "a", &value); err != nil || found {
+		t.Fatalf("Get of a missing bucket returned %t, %v, want false, nil", found, err)
+	}
+}
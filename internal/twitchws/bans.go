@@ -0,0 +1,29 @@
+package twitchws
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+const twitchBansURL = "https://api.twitch.tv/helix/moderation/bans"
+
+// BanUser permanently bans userID from the tracked broadcaster's chat with the broadcaster's token,
+// which needs the moderator:manage:banned_users scope.
+func BanUser(tokens *TokenStore, userID, reason string) error {
+	broadcasterID := os.Getenv("TWITCH_SENSAI_USER_ID")
+	token, err := tokens.Token(broadcasterID)
+	if err != nil {
+		return fmt.Errorf("error getting broadcaster token: %w", err)
+	}
+	if token == nil || !token.HasScopes("moderator:manage:banned_users") {
+		return fmt.Errorf("the broadcaster has not granted jagger moderator:manage:banned_users")
+	}
+	query := url.Values{"broadcaster_id": {broadcasterID}, "moderator_id": {broadcasterID}}
+	body := map[string]any{"data": map[string]string{"user_id": userID, "reason": reason}}
+	if err := helixRequest(http.MethodPost, twitchBansURL+"?"+query.Encode(), token.AccessToken, body, nil); err != nil {
+		return fmt.Errorf("error banning %s: %w", userID, err)
+	}
+	return nil
+}
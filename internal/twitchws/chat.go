@@ -0,0 +1,373 @@
+package twitchws
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spddl/go-twitch-ws"
+)
+
+const (
+	twitchChatURL = "wss://irc-ws.chat.twitch.tv:443"
+
+	// https://dev.twitch.tv/docs/irc/#rate-limits
+	chatRateLimitWindow           = 30 * time.Second
+	chatRateLimitMessages         = 20
+	chatRateLimitModeratorMessage = 100
+	chatRateLimitVerifiedMessages = 7500
+	chatDefaultCommandCooldown    = 10 * time.Second
+	chatTimerCheckInterval        = time.Minute
+)
+
+type ChatConfig struct {
+	// Channel is the Twitch channel to join, usually the tracked broadcaster's login.
+	Channel string
+	// OAuthToken is the chat token of the jaggerOpti account, without the "oauth:" prefix.
+	OAuthToken string
+	// BotVerified raises the message limit to the verified bot limit.
+	BotVerified bool
+	// Moderator raises the message limit to the moderator limit; set it if jaggerOpti is a mod in Channel.
+	Moderator bool
+	// Commands maps a chat command such as "!discord" to its response. Responses override the
+	// built-in !uptime and !game commands.
+	Commands map[string]string
+	// CommandCooldown is the minimum time between two responses to the same command.
+	CommandCooldown time.Duration
+	// Timers are messages posted at an interval while the stream is live.
+	Timers []ChatTimer
+}
+
+type ChatTimer struct {
+	Interval time.Duration
+	Message  string
+}
+
+// Client is a Twitch chat connection for the jaggerOpti account.
+type Client struct {
+	ircClient *twitch.Client
+	channel   string
+	commands  map[string]string
+	cooldown  time.Duration
+	timers    []ChatTimer
+	limit     int
+
+	mu        sync.Mutex
+	sent      []time.Time
+	lastUsed  map[string]time.Time
+	lastTimer []time.Time
+	handlers  []func(ChatMessage)
+}
+
+// ChatMessage is a message posted in the joined Twitch chat.
+type ChatMessage struct {
+	ID          string
+	UserID      string
+	Login       string
+	DisplayName string
+	Text        string
+	Emotes      []ChatEmote
+}
+
+// ChatEmote is an emote used in a ChatMessage. Start and End are the inclusive rune offsets of the
+// emote name in the message text.
+type ChatEmote struct {
+	ID    string
+	Name  string
+	Start int
+	End   int
+}
+
+func NewIRCClient(config ChatConfig) (*Client, error) {
+	channel := strings.ToLower(strings.TrimPrefix(config.Channel, "#")) // only in Lowercase
+	bot, err := twitch.NewClient(&twitch.Client{
+		Server:      twitchChatURL,
+		User:        strings.ToLower(twitchUsername),
+		Oauth:       strings.TrimPrefix(config.OAuthToken, "oauth:"),
+		BotVerified: config.BotVerified, // verified bots: Have higher chat limits than regular users.
+		Channel:     []string{channel},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating Twitch chat client: %w", err)
+	}
+	limit := chatRateLimitMessages
+	if config.Moderator {
+		limit = chatRateLimitModeratorMessage
+	}
+	if config.BotVerified {
+		limit = chatRateLimitVerifiedMessages
+	}
+	cooldown := config.CommandCooldown
+	if cooldown <= 0 {
+		cooldown = chatDefaultCommandCooldown
+	}
+	commands := make(map[string]string)
+	for name, response := range config.Commands {
+		commands[strings.ToLower(name)] = response
+	}
+	lastTimer := make([]time.Time, len(config.Timers))
+	for i := range lastTimer {
+		lastTimer[i] = time.Now()
+	}
+	c := &Client{
+		ircClient: bot,
+		channel:   channel,
+		commands:  commands,
+		cooldown:  cooldown,
+		timers:    config.Timers,
+		limit:     limit,
+		lastUsed:  make(map[string]time.Time),
+		lastTimer: lastTimer,
+	}
+	bot.OnPrivateMessage = c.handlePrivateMessage
+	return c, nil
+}
+
+// RunIRCClient connects to Twitch chat and posts timer messages while the stream is live. It blocks
+// until stop is closed.
+func (c *Client) RunIRCClient(stop <-chan struct{}) {
+	c.ircClient.Run()
+	defer c.ircClient.Close()
+	ticker := time.NewTicker(chatTimerCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			c.runTimers(now)
+		}
+	}
+}
+
+// Say sends a message to the joined channel if the chat rate limit allows it, and reports whether
+// the message was sent.
+func (c *Client) Say(message string) bool {
+	if !c.allow(time.Now()) {
+		log.Printf("chat rate limit of %d messages per %s reached, dropping message: %s", c.limit, chatRateLimitWindow, message)
+		return false
+	}
+	// Rate limiting is done by allow, so always use the library's moderator queue which has the
+	// highest limit and would otherwise delay our messages further.
+	c.ircClient.Say(c.channel, message, true)
+	return true
+}
+
+func (c *Client) allow(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var recent []time.Time
+	for _, t := range c.sent {
+		if now.Sub(t) < chatRateLimitWindow {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= c.limit {
+		c.sent = recent
+		return false
+	}
+	c.sent = append(recent, now)
+	return true
+}
+
+// OnMessage registers a handler that is called for every message posted in the joined channel.
+func (c *Client) OnMessage(handler func(ChatMessage)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers = append(c.handlers, handler)
+}
+
+func (c *Client) handlePrivateMessage(msg twitch.IRCMessage) {
+	if len(msg.Params) < 2 {
+		return
+	}
+	chatMessage := parseChatMessage(msg)
+	c.mu.Lock()
+	handlers := c.handlers
+	c.mu.Unlock()
+	for _, handler := range handlers {
+		handler(chatMessage)
+	}
+	text := strings.TrimSpace(chatMessage.Text)
+	if !strings.HasPrefix(text, "!") {
+		return
+	}
+	name := strings.ToLower(strings.Fields(text)[0])
+	if !c.isCommand(name) || !c.offCooldown(name, time.Now()) {
+		return
+	}
+	// Built-in commands ask Helix, which must not hold up reading chat.
+	go func() {
+		if response, ok := c.commandResponse(name); ok {
+			c.Say(response)
+		}
+	}()
+}
+
+// isCommand reports whether name is a configured or built-in chat command.
+func (c *Client) isCommand(name string) bool {
+	_, configured := c.commands[name]
+	return configured || name == "!uptime" || name == "!game"
+}
+
+func (c *Client) commandResponse(name string) (string, bool) {
+	if response, ok := c.commands[name]; ok {
+		return response, true
+	}
+	switch name {
+	case "!uptime":
+		stream, err := GetStream()
+		if err != nil {
+			log.Printf("error getting stream for !uptime: %s", err)
+			return "", false
+		}
+		if stream == nil {
+			return fmt.Sprintf("%s is offline.", c.channel), true
+		}
+		startedAt, err := time.Parse(time.RFC3339, stream.StartedAt)
+		if err != nil {
+			log.Printf("error parsing stream start time %q: %s", stream.StartedAt, err)
+			return "", false
+		}
+		return fmt.Sprintf("%s has been live for %s.", stream.UserName, time.Since(startedAt).Truncate(time.Minute)), true
+	case "!game":
+		resp, err := GetChannelInformation()
+		if err != nil {
+			log.Printf("error getting channel information for !game: %s", err)
+			return "", false
+		}
+		if len(resp.Data) != 1 {
+			return "", false
+		}
+		return fmt.Sprintf("%s is playing %s.", resp.Data[0].BroadcasterName, resp.Data[0].GameName), true
+	}
+	return "", false
+}
+
+func (c *Client) offCooldown(name string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.lastUsed[name]) < c.cooldown {
+		return false
+	}
+	c.lastUsed[name] = now
+	return true
+}
+
+func (c *Client) runTimers(now time.Time) {
+	if len(c.timers) == 0 {
+		return
+	}
+	stream, err := GetStream()
+	if err != nil {
+		log.Printf("error getting stream for chat timers: %s", err)
+		return
+	}
+	if stream == nil {
+		return
+	}
+	for i, timer := range c.timers {
+		c.mu.Lock()
+		due := now.Sub(c.lastTimer[i]) >= timer.Interval
+		if due {
+			c.lastTimer[i] = now
+		}
+		c.mu.Unlock()
+		if due {
+			c.Say(timer.Message)
+		}
+	}
+}
+
+func parseChatMessage(msg twitch.IRCMessage) ChatMessage {
+	login, _, _ := strings.Cut(string(msg.Prefix), "!")
+	chatMessage := ChatMessage{
+		ID:          string(msg.Tags["id"]),
+		UserID:      string(msg.Tags["user-id"]),
+		Login:       login,
+		DisplayName: string(msg.Tags["display-name"]),
+		Text:        strings.TrimRight(string(msg.Params[1]), "\r\n"),
+	}
+	if chatMessage.DisplayName == "" {
+		chatMessage.DisplayName = login
+	}
+	chatMessage.Emotes = parseEmotes(string(msg.Tags["emotes"]), chatMessage.Text)
+	return chatMessage
+}
+
+// parseEmotes parses an IRC emotes tag such as "25:0-4,12-16/1902:6-10" into the emotes of text.
+func parseEmotes(tag, text string) []ChatEmote {
+	if tag == "" {
+		return nil
+	}
+	runes := []rune(text)
+	var emotes []ChatEmote
+	for _, emote := range strings.Split(tag, "/") {
+		id, positions, ok := strings.Cut(emote, ":")
+		if !ok {
+			continue
+		}
+		for _, position := range strings.Split(positions, ",") {
+			startValue, endValue, ok := strings.Cut(position, "-")
+			if !ok {
+				continue
+			}
+			start, err := strconv.Atoi(startValue)
+			if err != nil {
+				continue
+			}
+			end, err := strconv.Atoi(endValue)
+			if err != nil || start < 0 || end < start || end >= len(runes) {
+				continue
+			}
+			emotes = append(emotes, ChatEmote{ID: id, Name: string(runes[start : end+1]), Start: start, End: end})
+		}
+	}
+	return emotes
+}
+
+// ParseChatCommands parses a list of command=response pairs separated by semicolons, e.g.
+// "!discord=Join us at https://discord.gg/example;!socials=...".
+func ParseChatCommands(value string) (map[string]string, error) {
+	commands := make(map[string]string)
+	for _, pair := range strings.Split(value, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, response, ok := strings.Cut(pair, "=")
+		if !ok || !strings.HasPrefix(name, "!") {
+			return nil, fmt.Errorf("invalid chat command %q, expected !command=response", pair)
+		}
+		commands[strings.TrimSpace(name)] = strings.TrimSpace(response)
+	}
+	return commands, nil
+}
+
+// ParseChatTimers parses a list of interval=message pairs separated by semicolons, e.g.
+// "15m=Follow the stream!;1h=Join the Discord!".
+func ParseChatTimers(value string) ([]ChatTimer, error) {
+	var timers []ChatTimer
+	for _, pair := range strings.Split(value, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		interval, message, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid chat timer %q, expected interval=message", pair)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil {
+			return nil, fmt.Errorf("invalid chat timer interval %q: %w", interval, err)
+		}
+		if d < chatTimerCheckInterval {
+			return nil, fmt.Errorf("chat timer interval %s is shorter than %s", d, chatTimerCheckInterval)
+		}
+		timers = append(timers, ChatTimer{Interval: d, Message: strings.TrimSpace(message)})
+	}
+	return timers, nil
+}
@@ -0,0 +1,108 @@
+package twitchws
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	twitch "github.com/spddl/go-twitch-ws"
+)
+
+func TestParseEmotes(t *testing.T) {
+	got := parseEmotes("25:0-4,12-16/1902:6-10", "Kappa Keepo Kappa")
+	want := []ChatEmote{
+		{ID: "25", Name: "Kappa", Start: 0, End: 4},
+		{ID: "25", Name: "Kappa", Start: 12, End: 16},
+		{ID: "1902", Name: "Keepo", Start: 6, End: 10},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseEmotes returned %v, want %v", got, want)
+	}
+}
+
+func TestParseEmotesCountsCharacters(t *testing.T) {
+	got := parseEmotes("25:4-8", "héé Kappa")
+	if len(got) != 1 || got[0].Name != "Kappa" {
+		t.Fatalf("parseEmotes returned %v, want Kappa after multi-byte characters", got)
+	}
+}
+
+func TestParseEmotesSkipsInvalidPositions(t *testing.T) {
+	for _, tag := range []string{"", "25", "25:a-4", "25:4-2", "25:0-99", "25:0"} {
+		if got := parseEmotes(tag, "Kappa"); len(got) != 0 {
+			t.Errorf("parseEmotes(%q) returned %v, want none", tag, got)
+		}
+	}
+}
+
+func TestParseChatMessage(t *testing.T) {
+	msg := twitch.IRCMessage{
+		Tags:   map[string][]byte{"id": []byte("m1"), "user-id": []byte("42"), "emotes": []byte("25:4-8")},
+		Prefix: []byte("viewer!viewer@viewer.tmi.twitch.tv"),
+		Params: [][]byte{[]byte("#sensaiopti"), []byte("hey Kappa\r\n")},
+	}
+	got := parseChatMessage(msg)
+	if got.ID != "m1" || got.UserID != "42" || got.Login != "viewer" || got.DisplayName != "viewer" || got.Text != "hey Kappa" || len(got.Emotes) != 1 {
+		t.Fatalf("parseChatMessage returned %+v", got)
+	}
+}
+
+func TestParseChatCommands(t *testing.T) {
+	got, err := ParseChatCommands("!discord=Join at https://discord.gg/x?a=b; !socials = links ;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"!discord": "Join at https://discord.gg/x?a=b", "!socials": "links"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseChatCommands returned %v, want %v", got, want)
+	}
+	for _, value := range []string{"discord=no prefix", "!discord"} {
+		if _, err := ParseChatCommands(value); err == nil {
+			t.Errorf("ParseChatCommands(%q) returned no error", value)
+		}
+	}
+}
+
+func TestParseChatTimers(t *testing.T) {
+	got, err := ParseChatTimers("15m=Follow the stream!;1h = Join the Discord: https://discord.gg/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ChatTimer{{Interval: 15 * time.Minute, Message: "Follow the stream!"}, {Interval: time.Hour, Message: "Join the Discord: https://discord.gg/x"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseChatTimers returned %v, want %v", got, want)
+	}
+	for _, value := range []string{"15m", "soon=hi", "30s=too often"} {
+		if _, err := ParseChatTimers(value); err == nil {
+			t.Errorf("ParseChatTimers(%q) returned no error", value)
+		}
+	}
+}
+
+func TestCommandCooldown(t *testing.T) {
+	c := &Client{commands: map[string]string{"!discord": "link"}, cooldown: time.Minute, lastUsed: make(map[string]time.Time)}
+	if !c.isCommand("!discord") || !c.isCommand("!uptime") || c.isCommand("!lurk") {
+		t.Fatal("isCommand does not match the configured and built-in commands")
+	}
+	now := time.Now()
+	if !c.offCooldown("!discord", now) {
+		t.Fatal("first use is on cooldown")
+	}
+	if c.offCooldown("!discord", now.Add(time.Second)) {
+		t.Fatal("second use within the cooldown is allowed")
+	}
+	if !c.offCooldown("!discord", now.Add(time.Minute)) {
+		t.Fatal("use after the cooldown is not allowed")
+	}
+}
+
+func TestUnknownCommandsDoNotUseCooldowns(t *testing.T) {
+	c := &Client{commands: map[string]string{}, cooldown: time.Minute, lastUsed: make(map[string]time.Time)}
+	c.handlePrivateMessage(twitch.IRCMessage{
+		Prefix: []byte("viewer!viewer@viewer.tmi.twitch.tv"),
+		Params: [][]byte{[]byte("#sensaiopti"), []byte("!lurk")},
+	})
+	if len(c.lastUsed) != 0 {
+		t.Fatalf("unknown command started a cooldown: %v", c.lastUsed)
+	}
+}
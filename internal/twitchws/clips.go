@@ -0,0 +1,124 @@
+package twitchws
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	twitchClipsURL    = "https://api.twitch.tv/helix/clips"
+	twitchGetGamesURL = "https://api.twitch.tv/helix/games"
+)
+
+// Clip is a Twitch clip.
+type Clip struct {
+	ID              string    `json:"id"`
+	URL             string    `json:"url"`
+	EmbedURL        string    `json:"embed_url"`
+	BroadcasterID   string    `json:"broadcaster_id"`
+	BroadcasterName string    `json:"broadcaster_name"`
+	CreatorID       string    `json:"creator_id"`
+	CreatorName     string    `json:"creator_name"`
+	VideoID         string    `json:"video_id"`
+	GameID          string    `json:"game_id"`
+	Language        string    `json:"language"`
+	Title           string    `json:"title"`
+	ViewCount       int       `json:"view_count"`
+	CreatedAt       time.Time `json:"created_at"`
+	ThumbnailURL    string    `json:"thumbnail_url"`
+	Duration        float64   `json:"duration"`
+}
+
+type getClipsResponse struct {
+	Data       []Clip     `json:"data"`
+	Pagination Pagination `json:"pagination"`
+}
+
+// GetClips returns the clips of broadcasterID created between from and to.
+func GetClips(broadcasterID string, from, to time.Time) ([]Clip, error) {
+	token, err := authenticateToTwitch()
+	if err != nil {
+		return nil, fmt.Errorf("error authenticating to Twitch to get clips: %w", err)
+	}
+	query := url.Values{
+		"broadcaster_id": {broadcasterID},
+		"started_at":     {from.UTC().Format(time.RFC3339)},
+		"ended_at":       {to.UTC().Format(time.RFC3339)},
+		"first":          {"100"},
+	}
+	var clips []Clip
+	for {
+		var resp getClipsResponse
+		if err := helixRequest(http.MethodGet, twitchClipsURL+"?"+query.Encode(), token, nil, &resp); err != nil {
+			return nil, fmt.Errorf("error getting clips: %w", err)
+		}
+		clips = append(clips, resp.Data...)
+		if resp.Pagination.Cursor == "" || len(resp.Data) == 0 {
+			return clips, nil
+		}
+		query.Set("after", resp.Pagination.Cursor)
+	}
+}
+
+// ErrNotLive is returned for requests that need the stream to be live.
+var ErrNotLive = errors.New("the stream is not live")
+
+// CreateClip clips the live stream of the tracked broadcaster with the broadcaster's token, which
+// needs the clips:edit scope, and returns the clip's URL. The clip takes a few seconds to process
+// before the URL works.
+func CreateClip(tokens *TokenStore) (string, error) {
+	broadcasterID := os.Getenv("TWITCH_SENSAI_USER_ID")
+	token, err := tokens.Token(broadcasterID)
+	if err != nil {
+		return "", fmt.Errorf("error getting broadcaster token: %w", err)
+	}
+	if token == nil || !token.HasScopes("clips:edit") {
+		return "", fmt.Errorf("the broadcaster has not granted jagger clips:edit")
+	}
+	var resp struct {
+		Data []struct {
+			ID      string `json:"id"`
+			EditURL string `json:"edit_url"`
+		} `json:"data"`
+	}
+	if err := helixRequest(http.MethodPost, twitchClipsURL+"?"+url.Values{"broadcaster_id": {broadcasterID}}.Encode(), token.AccessToken, nil, &resp); err != nil {
+		var helixErr *HelixError
+		if errors.As(err, &helixErr) && helixErr.StatusCode == http.StatusNotFound {
+			return "", ErrNotLive
+		}
+		return "", fmt.Errorf("error creating clip: %w", err)
+	}
+	if len(resp.Data) == 0 {
+		return "", fmt.Errorf("error creating clip: no clip in response")
+	}
+	return fmt.Sprintf("https://clips.twitch.tv/%s", resp.Data[0].ID), nil
+}
+
+// GetGameNames returns the names of the games with gameIDs by ID.
+func GetGameNames(gameIDs ...string) (map[string]string, error) {
+	names := make(map[string]string)
+	if len(gameIDs) == 0 {
+		return names, nil
+	}
+	token, err := authenticateToTwitch()
+	if err != nil {
+		return nil, fmt.Errorf("error authenticating to Twitch to get games: %w", err)
+	}
+	var resp struct {
+		Data []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"data"`
+	}
+	if err := helixRequest(http.MethodGet, twitchGetGamesURL+"?"+url.Values{"id": gameIDs}.Encode(), token, nil, &resp); err != nil {
+		return nil, fmt.Errorf("error getting games: %w", err)
+	}
+	for _, game := range resp.Data {
+		names[game.ID] = game.Name
+	}
+	return names, nil
+}
@@ -0,0 +1,47 @@
+package twitchws
+
+import (
+	"sync"
+	"time"
+)
+
+// ReceivedNotification is a Notification with the time jagger received it.
+type ReceivedNotification struct {
+	Notification
+	ReceivedAt time.Time
+}
+
+// EventLog keeps the most recently received notifications in memory.
+type EventLog struct {
+	mu      sync.Mutex
+	entries []ReceivedNotification
+	size    int
+}
+
+func NewEventLog(size int) *EventLog {
+	return &EventLog{size: size}
+}
+
+// Add records notification as received now, dropping the oldest entry if the log is full.
+func (l *EventLog) Add(notification Notification) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, ReceivedNotification{Notification: notification, ReceivedAt: time.Now()})
+	if len(l.entries) > l.size {
+		l.entries = l.entries[len(l.entries)-l.size:]
+	}
+}
+
+// Last returns up to n of the most recent notifications, newest first.
+func (l *EventLog) Last(n int) []ReceivedNotification {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n > len(l.entries) {
+		n = len(l.entries)
+	}
+	last := make([]ReceivedNotification, 0, n)
+	for i := len(l.entries) - 1; i >= len(l.entries)-n; i-- {
+		last = append(last, l.entries[i])
+	}
+	return last
+}
@@ -0,0 +1,299 @@
+package twitchws
+
+// ChannelUpdateEvent is the event of a channel.update notification, sent when the broadcaster
+// changes the title, category or language of the stream.
+type ChannelUpdateEvent struct {
+	BroadcasterUserID           string   `json:"broadcaster_user_id"`
+	BroadcasterUserLogin        string   `json:"broadcaster_user_login"`
+	BroadcasterUserName         string   `json:"broadcaster_user_name"`
+	Title                       string   `json:"title"`
+	Language                    string   `json:"language"`
+	CategoryID                  string   `json:"category_id"`
+	CategoryName                string   `json:"category_name"`
+	ContentClassificationLabels []string `json:"content_classification_labels"`
+}
+
+// RaidEvent is the event of a channel.raid notification. Incoming and outgoing raids of the
+// broadcaster are both delivered.
+type RaidEvent struct {
+	FromBroadcasterUserID    string `json:"from_broadcaster_user_id"`
+	FromBroadcasterUserLogin string `json:"from_broadcaster_user_login"`
+	FromBroadcasterUserName  string `json:"from_broadcaster_user_name"`
+	ToBroadcasterUserID      string `json:"to_broadcaster_user_id"`
+	ToBroadcasterUserLogin   string `json:"to_broadcaster_user_login"`
+	ToBroadcasterUserName    string `json:"to_broadcaster_user_name"`
+	Viewers                  int    `json:"viewers"`
+}
+
+// FollowEvent is the event of a channel.follow notification.
+type FollowEvent struct {
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	UserName             string `json:"user_name"`
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+	FollowedAt           string `json:"followed_at"`
+}
+
+// SubscribeEvent is the event of a channel.subscribe notification, sent for new subscriptions
+// including gifted ones. Resubscriptions are SubscriptionMessageEvents.
+type SubscribeEvent struct {
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	UserName             string `json:"user_name"`
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+	// Tier is "1000", "2000" or "3000".
+	Tier   string `json:"tier"`
+	IsGift bool   `json:"is_gift"`
+}
+
+// SubscriptionEndEvent is the event of a channel.subscription.end notification, sent when a
+// subscription expires.
+type SubscriptionEndEvent struct {
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	UserName             string `json:"user_name"`
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+	Tier                 string `json:"tier"`
+	IsGift               bool   `json:"is_gift"`
+}
+
+// SubscriptionMessageEvent is the event of a channel.subscription.message notification, sent when a
+// subscriber shares their resubscription in chat.
+type SubscriptionMessageEvent struct {
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	UserName             string `json:"user_name"`
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+	Tier                 string `json:"tier"`
+	Message              struct {
+		Text string `json:"text"`
+	} `json:"message"`
+	CumulativeMonths int `json:"cumulative_months"`
+	// StreakMonths is nil if the subscriber chose not to share their streak.
+	StreakMonths   *int `json:"streak_months"`
+	DurationMonths int  `json:"duration_months"`
+}
+
+// SubscriptionGiftEvent is the event of a channel.subscription.gift notification. The user fields
+// are empty for anonymous gifts.
+type SubscriptionGiftEvent struct {
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	UserName             string `json:"user_name"`
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+	Total                int    `json:"total"`
+	Tier                 string `json:"tier"`
+	// CumulativeTotal is nil for anonymous gifts or if the gifter chose not to share it.
+	CumulativeTotal *int `json:"cumulative_total"`
+	IsAnonymous     bool `json:"is_anonymous"`
+}
+
+// CheerEvent is the event of a channel.cheer notification. The user fields are empty for anonymous
+// cheers.
+type CheerEvent struct {
+	IsAnonymous          bool   `json:"is_anonymous"`
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	UserName             string `json:"user_name"`
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+	Message              string `json:"message"`
+	Bits                 int    `json:"bits"`
+}
+
+// StreamOnlineEvent is the event of a stream.online notification.
+type StreamOnlineEvent struct {
+	// ID is the stream's ID, archived videos of the stream refer to it.
+	ID                   string `json:"id"`
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+	Type                 string `json:"type"`
+	StartedAt            string `json:"started_at"`
+}
+
+// PollEvent is the event of the channel.poll.begin, channel.poll.progress and channel.poll.end
+// notifications. EndsAt is set until the poll ends, Status and EndedAt once it has.
+type PollEvent struct {
+	ID                   string       `json:"id"`
+	BroadcasterUserID    string       `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string       `json:"broadcaster_user_login"`
+	BroadcasterUserName  string       `json:"broadcaster_user_name"`
+	Title                string       `json:"title"`
+	Choices              []PollChoice `json:"choices"`
+	ChannelPointsVoting  struct {
+		IsEnabled     bool `json:"is_enabled"`
+		AmountPerVote int  `json:"amount_per_vote"`
+	} `json:"channel_points_voting"`
+	StartedAt string `json:"started_at"`
+	EndsAt    string `json:"ends_at"`
+	// Status is "completed", "terminated" or "archived".
+	Status  string `json:"status"`
+	EndedAt string `json:"ended_at"`
+}
+
+// PollChoice is a choice of a poll. The vote counts are 0 in channel.poll.begin notifications.
+type PollChoice struct {
+	ID                 string `json:"id"`
+	Title              string `json:"title"`
+	Votes              int    `json:"votes"`
+	ChannelPointsVotes int    `json:"channel_points_votes"`
+}
+
+// PredictionEvent is the event of the channel.prediction.begin, channel.prediction.progress,
+// channel.prediction.lock and channel.prediction.end notifications.
+type PredictionEvent struct {
+	ID                   string              `json:"id"`
+	BroadcasterUserID    string              `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string              `json:"broadcaster_user_login"`
+	BroadcasterUserName  string              `json:"broadcaster_user_name"`
+	Title                string              `json:"title"`
+	Outcomes             []PredictionOutcome `json:"outcomes"`
+	StartedAt            string              `json:"started_at"`
+	LocksAt              string              `json:"locks_at"`
+	LockedAt             string              `json:"locked_at"`
+	// Status and WinningOutcomeID are set once the prediction ends, Status is "resolved" or
+	// "canceled".
+	Status           string `json:"status"`
+	WinningOutcomeID string `json:"winning_outcome_id"`
+	EndedAt          string `json:"ended_at"`
+}
+
+// PredictionOutcome is an outcome of a prediction. Users and ChannelPoints are 0 in
+// channel.prediction.begin notifications.
+type PredictionOutcome struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	// Color is "blue" or "pink".
+	Color         string `json:"color"`
+	Users         int    `json:"users"`
+	ChannelPoints int    `json:"channel_points"`
+}
+
+// HypeTrainEvent is the event of the channel.hype_train.begin, channel.hype_train.progress and
+// channel.hype_train.end notifications. Progress, Goal and ExpiresAt are set until the hype train
+// ends, EndedAt and CooldownEndsAt once it has.
+type HypeTrainEvent struct {
+	ID                   string                  `json:"id"`
+	BroadcasterUserID    string                  `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string                  `json:"broadcaster_user_login"`
+	BroadcasterUserName  string                  `json:"broadcaster_user_name"`
+	Level                int                     `json:"level"`
+	Total                int                     `json:"total"`
+	Progress             int                     `json:"progress"`
+	Goal                 int                     `json:"goal"`
+	TopContributions     []HypeTrainContribution `json:"top_contributions"`
+	LastContribution     *HypeTrainContribution  `json:"last_contribution"`
+	StartedAt            string                  `json:"started_at"`
+	ExpiresAt            string                  `json:"expires_at"`
+	EndedAt              string                  `json:"ended_at"`
+	CooldownEndsAt       string                  `json:"cooldown_ends_at"`
+}
+
+// HypeTrainContribution is a user's contribution to a hype train. Type is "bits", "subscription"
+// or "other", Total is in bits or in subscription points (500, 1000 or 2500 per tier).
+type HypeTrainContribution struct {
+	UserID    string `json:"user_id"`
+	UserLogin string `json:"user_login"`
+	UserName  string `json:"user_name"`
+	Type      string `json:"type"`
+	Total     int    `json:"total"`
+}
+
+// RedemptionEvent is the event of a channel.channel_points_custom_reward_redemption.add
+// notification, sent when a viewer redeems a custom reward.
+type RedemptionEvent struct {
+	ID                   string `json:"id"`
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	UserName             string `json:"user_name"`
+	UserInput            string `json:"user_input"`
+	// Status is "unfulfilled", "fulfilled" or "canceled".
+	Status string `json:"status"`
+	Reward struct {
+		ID     string `json:"id"`
+		Title  string `json:"title"`
+		Cost   int    `json:"cost"`
+		Prompt string `json:"prompt"`
+	} `json:"reward"`
+	RedeemedAt string `json:"redeemed_at"`
+}
+
+// BanEvent is the event of a channel.ban notification, for bans and timeouts.
+type BanEvent struct {
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	UserName             string `json:"user_name"`
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+	ModeratorUserID      string `json:"moderator_user_id"`
+	ModeratorUserLogin   string `json:"moderator_user_login"`
+	ModeratorUserName    string `json:"moderator_user_name"`
+	Reason               string `json:"reason"`
+	BannedAt             string `json:"banned_at"`
+	// EndsAt is when a timeout ends, empty for permanent bans.
+	EndsAt      string `json:"ends_at"`
+	IsPermanent bool   `json:"is_permanent"`
+}
+
+// UnbanEvent is the event of a channel.unban notification.
+type UnbanEvent struct {
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	UserName             string `json:"user_name"`
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+	ModeratorUserID      string `json:"moderator_user_id"`
+	ModeratorUserLogin   string `json:"moderator_user_login"`
+	ModeratorUserName    string `json:"moderator_user_name"`
+}
+
+// ModeratorEvent is the event of the channel.moderator.add and channel.moderator.remove
+// notifications, the user is the moderator who was added or removed.
+type ModeratorEvent struct {
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	UserName             string `json:"user_name"`
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+}
+
+// AutomodMessageEvent is the event of the automod.message.hold and automod.message.update
+// notifications. The moderator fields and Status are only set for updates.
+type AutomodMessageEvent struct {
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	UserName             string `json:"user_name"`
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+	ModeratorUserID      string `json:"moderator_user_id"`
+	ModeratorUserLogin   string `json:"moderator_user_login"`
+	ModeratorUserName    string `json:"moderator_user_name"`
+	MessageID            string `json:"message_id"`
+	Message              struct {
+		Text string `json:"text"`
+	} `json:"message"`
+	Category string `json:"category"`
+	Level    int    `json:"level"`
+	// Status is "Approved", "Denied" or "Expired".
+	Status string `json:"status"`
+	HeldAt string `json:"held_at"`
+}
@@ -0,0 +1,68 @@
+package twitchws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// HelixError is a Helix API response with an unexpected status.
+type HelixError struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *HelixError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("status code from response was not OK: %s", e.Status)
+	}
+	return fmt.Sprintf("status code from response was not OK: %s: %s", e.Status, e.Message)
+}
+
+// helixRequest sends a Helix API request authorized with token, which may be an app or a user token.
+// body, if not nil, is sent as JSON and the response is decoded into v unless v is nil.
+func helixRequest(method, url, token string, body, v any) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("error encoding request body: %w", err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Add("Client-Id", os.Getenv("TWITCH_CLIENT_ID"))
+	req.Header.Add("Authorization", fmt.Sprint("Bearer ", token))
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending http request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var errResp struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		return &HelixError{StatusCode: resp.StatusCode, Status: resp.Status, Message: errResp.Message}
+	}
+	if v == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("could not decode response body: %w", err)
+	}
+	return nil
+}
+
+// Pagination is the cursor of a paginated Helix response.
+type Pagination struct {
+	Cursor string `json:"cursor"`
+}
@@ -0,0 +1,385 @@
+package twitchws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/secrets"
+	"github.com/brandonlbarrow/jaggerbot/internal/store"
+)
+
+const (
+	twitchAuthorizeURL     = "https://id.twitch.tv/oauth2/authorize"
+	twitchValidateTokenURL = "https://id.twitch.tv/oauth2/validate"
+	twitchRevokeTokenURL   = "https://id.twitch.tv/oauth2/revoke"
+	defaultPublicURL       = "https://gonkbot.brandonbarrow.com"
+	userTokenBucket        = "twitch_user_tokens"
+	// userTokenRefreshMargin is how long before expiry a user token is refreshed.
+	userTokenRefreshMargin = 5 * time.Minute
+)
+
+// DefaultBroadcasterScopes are requested from the broadcaster when no scopes are given for a consent link.
+var DefaultBroadcasterScopes = []string{
+	"channel:read:subscriptions", "bits:read", "moderator:read:followers", "clips:edit",
+	"channel:read:polls", "channel:read:predictions", "channel:read:hype_train", "channel:manage:redemptions",
+	"channel:moderate", "moderation:read", "moderator:manage:automod", "moderator:manage:banned_users",
+}
+
+// scopedSubscriptionTypes are EventSub subscription types that need the broadcaster to have granted
+// the jagger application a scope before they can be created. Moderator types also need the
+// broadcaster as the moderator in their condition.
+var scopedSubscriptionTypes = []struct {
+	Type, Version, Scope string
+	Moderator            bool
+}{
+	{EventTypeChannelSubscribe, "1", "channel:read:subscriptions", false},
+	{EventTypeChannelSubscriptionMessage, "1", "channel:read:subscriptions", false},
+	{EventTypeChannelSubscriptionGift, "1", "channel:read:subscriptions", false},
+	{EventTypeChannelSubscriptionEnd, "1", "channel:read:subscriptions", false},
+	{EventTypeChannelCheer, "1", "bits:read", false},
+	{EventTypeChannelFollow, "2", "moderator:read:followers", true},
+	{EventTypeChannelPollBegin, "1", "channel:read:polls", false},
+	{EventTypeChannelPollProgress, "1", "channel:read:polls", false},
+	{EventTypeChannelPollEnd, "1", "channel:read:polls", false},
+	{EventTypeChannelPredictionBegin, "1", "channel:read:predictions", false},
+	{EventTypeChannelPredictionProgress, "1", "channel:read:predictions", false},
+	{EventTypeChannelPredictionLock, "1", "channel:read:predictions", false},
+	{EventTypeChannelPredictionEnd, "1", "channel:read:predictions", false},
+	{EventTypeChannelHypeTrainBegin, "1", "channel:read:hype_train", false},
+	{EventTypeChannelHypeTrainProgress, "1", "channel:read:hype_train", false},
+	{EventTypeChannelHypeTrainEnd, "1", "channel:read:hype_train", false},
+	{EventTypeChannelPointsRedemptionAdd, "1", "channel:manage:redemptions", false},
+	{EventTypeChannelBan, "1", "channel:moderate", false},
+	{EventTypeChannelUnban, "1", "channel:moderate", false},
+	{EventTypeChannelModeratorAdd, "1", "moderation:read", false},
+	{EventTypeChannelModeratorRemove, "1", "moderation:read", false},
+	{EventTypeAutomodMessageHold, "1", "moderator:manage:automod", true},
+	{EventTypeAutomodMessageUpdate, "1", "moderator:manage:automod", true},
+}
+
+// UserToken is a user access token granted to jagger through the authorization code flow.
+type UserToken struct {
+	UserID       string    `json:"user_id"`
+	Login        string    `json:"login"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	Scopes       []string  `json:"scopes"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// HasScopes reports whether every scope in scopes was granted.
+func (t *UserToken) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		found := false
+		for _, granted := range t.Scopes {
+			if granted == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+type userTokenResponse struct {
+	AccessToken  string   `json:"access_token"`
+	RefreshToken string   `json:"refresh_token"`
+	ExpiresIn    int      `json:"expires_in"` // seconds
+	Scope        []string `json:"scope"`
+	TokenType    string   `json:"token_type"`
+}
+
+type validateTokenResponse struct {
+	ClientID  string   `json:"client_id"`
+	Login     string   `json:"login"`
+	Scopes    []string `json:"scopes"`
+	UserID    string   `json:"user_id"`
+	ExpiresIn int      `json:"expires_in"`
+}
+
+// PublicURL is the externally reachable base URL of the jagger webserver, JAGGER_PUBLIC_URL or
+// https://JAGGER_HOSTNAME. It is https://gonkbot.brandonbarrow.com if neither is set.
+func PublicURL() string {
+	if publicURL := os.Getenv("JAGGER_PUBLIC_URL"); publicURL != "" {
+		return strings.TrimSuffix(publicURL, "/")
+	}
+	if hostname := os.Getenv("JAGGER_HOSTNAME"); hostname != "" {
+		return "https://" + hostname
+	}
+	return defaultPublicURL
+}
+
+// OAuthRedirectURL is the URL Twitch redirects to after the user has granted or denied consent.
+func OAuthRedirectURL() string {
+	return PublicURL() + "/jagger/oauth/callback"
+}
+
+// AuthorizeURL returns the Twitch consent page URL for scopes, carrying state back to the callback.
+func AuthorizeURL(state string, scopes []string) string {
+	values := url.Values{}
+	values.Set("client_id", os.Getenv("TWITCH_CLIENT_ID"))
+	values.Set("redirect_uri", OAuthRedirectURL())
+	values.Set("response_type", "code")
+	values.Set("scope", strings.Join(scopes, " "))
+	values.Set("state", state)
+	values.Set("force_verify", "true")
+	return fmt.Sprintf("%s?%s", twitchAuthorizeURL, values.Encode())
+}
+
+// TokenStore keeps user tokens per Twitch user ID, refreshing them before they expire and storing
+// the rotated refresh token Twitch returns with every refresh. Tokens are encrypted with the
+// keystore before they are written to the store.
+type TokenStore struct {
+	store    *store.Store
+	keystore *secrets.Keystore
+	mu       sync.Mutex
+}
+
+func NewTokenStore(s *store.Store, keystore *secrets.Keystore) *TokenStore {
+	return &TokenStore{store: s, keystore: keystore}
+}
+
+// ExchangeCode trades an authorization code from the OAuth callback for a user token, stores it and
+// returns it.
+func (t *TokenStore) ExchangeCode(code string) (*UserToken, error) {
+	values := url.Values{}
+	values.Set("client_id", os.Getenv("TWITCH_CLIENT_ID"))
+	values.Set("client_secret", secrets.Get("TWITCH_BOT_TOKEN"))
+	values.Set("code", code)
+	values.Set("grant_type", "authorization_code")
+	values.Set("redirect_uri", OAuthRedirectURL())
+	tokenResp, err := requestUserToken(values)
+	if err != nil {
+		return nil, fmt.Errorf("error exchanging authorization code: %w", err)
+	}
+	validated, err := validateToken(tokenResp.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("error validating new user token: %w", err)
+	}
+	token := &UserToken{
+		UserID:       validated.UserID,
+		Login:        validated.Login,
+		AccessToken:  tokenResp.AccessToken,
+		RefreshToken: tokenResp.RefreshToken,
+		Scopes:       tokenResp.Scope,
+		ExpiresAt:    time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
+	}
+	sort.Strings(token.Scopes)
+	if err := t.save(token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// IdentifyUser trades an authorization code from the OAuth callback for the ID and login of the
+// user who granted it. The token is revoked instead of stored, it is only used to prove who the user
+// is.
+func IdentifyUser(code string) (userID, login string, err error) {
+	values := url.Values{}
+	values.Set("client_id", os.Getenv("TWITCH_CLIENT_ID"))
+	values.Set("client_secret", secrets.Get("TWITCH_BOT_TOKEN"))
+	values.Set("code", code)
+	values.Set("grant_type", "authorization_code")
+	values.Set("redirect_uri", OAuthRedirectURL())
+	tokenResp, err := requestUserToken(values)
+	if err != nil {
+		return "", "", fmt.Errorf("error exchanging authorization code: %w", err)
+	}
+	validated, err := validateToken(tokenResp.AccessToken)
+	if err != nil {
+		return "", "", fmt.Errorf("error validating user token: %w", err)
+	}
+	if err := revokeToken(tokenResp.AccessToken); err != nil {
+		log.Printf("error revoking identification token of %s: %s", validated.Login, err)
+	}
+	return validated.UserID, validated.Login, nil
+}
+
+// Token returns a valid user token for userID, refreshing it if it is about to expire. It returns
+// nil without an error if the user never granted consent.
+func (t *TokenStore) Token(userID string) (*UserToken, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	token, err := t.load(userID)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, nil
+	}
+	if time.Until(token.ExpiresAt) > userTokenRefreshMargin {
+		return token, nil
+	}
+	values := url.Values{}
+	values.Set("client_id", os.Getenv("TWITCH_CLIENT_ID"))
+	values.Set("client_secret", secrets.Get("TWITCH_BOT_TOKEN"))
+	values.Set("grant_type", "refresh_token")
+	values.Set("refresh_token", token.RefreshToken)
+	tokenResp, err := requestUserToken(values)
+	if err != nil {
+		return nil, fmt.Errorf("error refreshing user token for %s: %w", token.Login, err)
+	}
+	token.AccessToken = tokenResp.AccessToken
+	token.RefreshToken = tokenResp.RefreshToken
+	token.Scopes = tokenResp.Scope
+	sort.Strings(token.Scopes)
+	token.ExpiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	if err := t.put(token); err != nil {
+		return nil, fmt.Errorf("error storing refreshed user token for %s: %w", token.Login, err)
+	}
+	return token, nil
+}
+
+// Scopes returns the scopes granted by every user with a stored token, keyed by user ID.
+func (t *TokenStore) Scopes() (map[string][]string, error) {
+	scopes := make(map[string][]string)
+	for _, userID := range t.store.Keys(userTokenBucket) {
+		token, err := t.load(userID)
+		if err != nil {
+			return nil, err
+		}
+		if token == nil {
+			// The token was removed after the keys were listed.
+			continue
+		}
+		scopes[userID] = token.Scopes
+	}
+	return scopes, nil
+}
+
+func (t *TokenStore) save(token *UserToken) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.put(token); err != nil {
+		return fmt.Errorf("error storing user token for %s: %w", token.Login, err)
+	}
+	return nil
+}
+
+func (t *TokenStore) load(userID string) (*UserToken, error) {
+	var sealed string
+	found, err := t.store.Get(userTokenBucket, userID, &sealed)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	raw, err := t.keystore.Open(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting user token for %s: %w", userID, err)
+	}
+	var token UserToken
+	if err := json.Unmarshal(raw, &token); err != nil {
+		return nil, fmt.Errorf("error decoding user token for %s: %w", userID, err)
+	}
+	return &token, nil
+}
+
+func (t *TokenStore) put(token *UserToken) error {
+	raw, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	sealed, err := t.keystore.Seal(raw)
+	if err != nil {
+		return err
+	}
+	return t.store.Put(userTokenBucket, token.UserID, sealed)
+}
+
+// SubscribeAuthorized creates the EventSub subscriptions with transport that the broadcaster userID
+// has granted jagger the scopes for.
+func SubscribeAuthorized(tokens *TokenStore, userID string, transport SubscriptionTransport) error {
+	token, err := tokens.Token(userID)
+	if err != nil {
+		return fmt.Errorf("error getting user token: %w", err)
+	}
+	if token == nil {
+		return nil
+	}
+	appToken, err := authenticateToTwitch()
+	if err != nil {
+		return fmt.Errorf("error getting app token: %w", err)
+	}
+	// Every type is tried so one failure does not leave the others unsubscribed.
+	var errs []error
+	for _, subscriptionType := range scopedSubscriptionTypes {
+		if !token.HasScopes(subscriptionType.Scope) {
+			log.Printf("%s has not granted %s, not subscribing to %s", token.Login, subscriptionType.Scope, subscriptionType.Type)
+			continue
+		}
+		condition := map[string]string{"broadcaster_user_id": userID}
+		if subscriptionType.Moderator {
+			condition["moderator_user_id"] = userID
+		}
+		if err := subscribe(appToken, transport, subscriptionType.Type, subscriptionType.Version, condition); err != nil {
+			errs = append(errs, fmt.Errorf("error subscribing to %s: %w", subscriptionType.Type, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func requestUserToken(values url.Values) (*userTokenResponse, error) {
+	resp, err := http.DefaultClient.PostForm(twitchAuthURL, values)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code from response was not OK: %s", resp.Status)
+	}
+	var tokenResp userTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, fmt.Errorf("could not decode token response: %w", err)
+	}
+	return &tokenResp, nil
+}
+
+func revokeToken(accessToken string) error {
+	resp, err := http.DefaultClient.PostForm(twitchRevokeTokenURL, url.Values{
+		"client_id": {os.Getenv("TWITCH_CLIENT_ID")},
+		"token":     {accessToken},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code from response was not OK: %s", resp.Status)
+	}
+	return nil
+}
+
+func validateToken(accessToken string) (*validateTokenResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, twitchValidateTokenURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", fmt.Sprint("OAuth ", accessToken))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code from response was not OK: %s", resp.Status)
+	}
+	var validateResp validateTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&validateResp); err != nil {
+		return nil, fmt.Errorf("could not decode validate response: %w", err)
+	}
+	return &validateResp, nil
+}
@@ -0,0 +1,112 @@
+package twitchws
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+const twitchCustomRewardsURL = "https://api.twitch.tv/helix/channel_points/custom_rewards"
+
+// CustomReward is a channel points custom reward of the broadcaster.
+type CustomReward struct {
+	ID                  string `json:"id"`
+	Title               string `json:"title"`
+	Prompt              string `json:"prompt"`
+	Cost                int    `json:"cost"`
+	IsEnabled           bool   `json:"is_enabled"`
+	IsPaused            bool   `json:"is_paused"`
+	IsUserInputRequired bool   `json:"is_user_input_required"`
+	BackgroundColor     string `json:"background_color"`
+}
+
+// CustomRewardUpdate are the fields of a custom reward to create or change, nil fields are left
+// unchanged.
+type CustomRewardUpdate struct {
+	Title               *string `json:"title,omitempty"`
+	Prompt              *string `json:"prompt,omitempty"`
+	Cost                *int    `json:"cost,omitempty"`
+	IsEnabled           *bool   `json:"is_enabled,omitempty"`
+	IsPaused            *bool   `json:"is_paused,omitempty"`
+	IsUserInputRequired *bool   `json:"is_user_input_required,omitempty"`
+}
+
+type customRewardsResponse struct {
+	Data []CustomReward `json:"data"`
+}
+
+// GetCustomRewards returns the custom rewards jagger can manage, the ones it created, with the
+// broadcaster's token, which needs the channel:manage:redemptions scope.
+func GetCustomRewards(tokens *TokenStore) ([]CustomReward, error) {
+	broadcasterID, token, err := rewardsToken(tokens)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"broadcaster_id": {broadcasterID}, "only_manageable_rewards": {"true"}}
+	var resp customRewardsResponse
+	if err := helixRequest(http.MethodGet, twitchCustomRewardsURL+"?"+query.Encode(), token, nil, &resp); err != nil {
+		return nil, fmt.Errorf("error getting custom rewards: %w", err)
+	}
+	return resp.Data, nil
+}
+
+// CreateCustomReward creates a custom reward, which needs at least a title and cost.
+func CreateCustomReward(tokens *TokenStore, reward CustomRewardUpdate) (*CustomReward, error) {
+	broadcasterID, token, err := rewardsToken(tokens)
+	if err != nil {
+		return nil, err
+	}
+	var resp customRewardsResponse
+	if err := helixRequest(http.MethodPost, twitchCustomRewardsURL+"?"+url.Values{"broadcaster_id": {broadcasterID}}.Encode(), token, reward, &resp); err != nil {
+		return nil, fmt.Errorf("error creating custom reward: %w", err)
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("error creating custom reward: no reward in response")
+	}
+	return &resp.Data[0], nil
+}
+
+// UpdateCustomReward changes the custom reward with rewardID.
+func UpdateCustomReward(tokens *TokenStore, rewardID string, update CustomRewardUpdate) (*CustomReward, error) {
+	broadcasterID, token, err := rewardsToken(tokens)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"broadcaster_id": {broadcasterID}, "id": {rewardID}}
+	var resp customRewardsResponse
+	if err := helixRequest(http.MethodPatch, twitchCustomRewardsURL+"?"+query.Encode(), token, update, &resp); err != nil {
+		return nil, fmt.Errorf("error updating custom reward %s: %w", rewardID, err)
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("error updating custom reward %s: no reward in response", rewardID)
+	}
+	return &resp.Data[0], nil
+}
+
+// DeleteCustomReward deletes the custom reward with rewardID.
+func DeleteCustomReward(tokens *TokenStore, rewardID string) error {
+	broadcasterID, token, err := rewardsToken(tokens)
+	if err != nil {
+		return err
+	}
+	query := url.Values{"broadcaster_id": {broadcasterID}, "id": {rewardID}}
+	if err := helixRequest(http.MethodDelete, twitchCustomRewardsURL+"?"+query.Encode(), token, nil, nil); err != nil {
+		return fmt.Errorf("error deleting custom reward %s: %w", rewardID, err)
+	}
+	return nil
+}
+
+// rewardsToken returns the tracked broadcaster's ID and access token for the custom rewards
+// endpoints.
+func rewardsToken(tokens *TokenStore) (string, string, error) {
+	broadcasterID := os.Getenv("TWITCH_SENSAI_USER_ID")
+	token, err := tokens.Token(broadcasterID)
+	if err != nil {
+		return "", "", fmt.Errorf("error getting broadcaster token: %w", err)
+	}
+	if token == nil || !token.HasScopes("channel:manage:redemptions") {
+		return "", "", fmt.Errorf("the broadcaster has not granted jagger channel:manage:redemptions")
+	}
+	return broadcasterID, token.AccessToken, nil
+}
@@ -0,0 +1,94 @@
+package twitchws
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const twitchGetScheduleURL = "https://api.twitch.tv/helix/schedule"
+
+// Schedule is the broadcaster's stream schedule.
+type Schedule struct {
+	BroadcasterID    string            `json:"broadcaster_id"`
+	BroadcasterName  string            `json:"broadcaster_name"`
+	BroadcasterLogin string            `json:"broadcaster_login"`
+	Segments         []ScheduleSegment `json:"segments"`
+	Vacation         *ScheduleVacation `json:"vacation"`
+}
+
+// ScheduleSegment is a scheduled stream. Recurring segments appear once per occurrence.
+type ScheduleSegment struct {
+	ID        string    `json:"id"`
+	StartTime time.Time `json:"start_time"`
+	// EndTime is nil if the broadcaster did not give a duration.
+	EndTime *time.Time `json:"end_time"`
+	Title   string     `json:"title"`
+	// CanceledUntil is set if this occurrence was canceled.
+	CanceledUntil *time.Time        `json:"canceled_until"`
+	Category      *ScheduleCategory `json:"category"`
+	IsRecurring   bool              `json:"is_recurring"`
+}
+
+type ScheduleCategory struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type ScheduleVacation struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// Canceled reports whether the broadcaster canceled this occurrence.
+func (s ScheduleSegment) Canceled() bool {
+	return s.CanceledUntil != nil
+}
+
+type getScheduleResponse struct {
+	Data       Schedule   `json:"data"`
+	Pagination Pagination `json:"pagination"`
+}
+
+// GetSchedule returns the schedule of the tracked broadcaster with the segments starting from from
+// and before until. A broadcaster without a schedule has no segments.
+func GetSchedule(from, until time.Time) (*Schedule, error) {
+	token, err := authenticateToTwitch()
+	if err != nil {
+		return nil, fmt.Errorf("error authenticating to Twitch to get schedule: %w", err)
+	}
+	query := url.Values{
+		"broadcaster_id": {os.Getenv("TWITCH_SENSAI_USER_ID")},
+		"start_time":     {from.UTC().Format(time.RFC3339)},
+		"first":          {"25"},
+	}
+	var schedule Schedule
+	for {
+		var resp getScheduleResponse
+		if err := helixRequest(http.MethodGet, twitchGetScheduleURL+"?"+query.Encode(), token, nil, &resp); err != nil {
+			var helixErr *HelixError
+			if errors.As(err, &helixErr) && helixErr.StatusCode == http.StatusNotFound {
+				return &schedule, nil
+			}
+			return nil, fmt.Errorf("error getting schedule: %w", err)
+		}
+		segments := schedule.Segments
+		schedule = resp.Data
+		schedule.Segments = segments
+		done := resp.Pagination.Cursor == ""
+		for _, segment := range resp.Data.Segments {
+			if !segment.StartTime.Before(until) {
+				done = true
+				break
+			}
+			schedule.Segments = append(schedule.Segments, segment)
+		}
+		if done {
+			return &schedule, nil
+		}
+		query.Set("after", resp.Pagination.Cursor)
+	}
+}
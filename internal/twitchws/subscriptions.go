@@ -0,0 +1,57 @@
+package twitchws
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+const twitchBroadcasterSubscriptionsURL = "https://api.twitch.tv/helix/subscriptions"
+
+// ChannelSubscription is a viewer's subscription to the broadcaster.
+type ChannelSubscription struct {
+	UserID    string `json:"user_id"`
+	UserLogin string `json:"user_login"`
+	UserName  string `json:"user_name"`
+	// Tier is "1000", "2000" or "3000".
+	Tier       string `json:"tier"`
+	IsGift     bool   `json:"is_gift"`
+	GifterName string `json:"gifter_name"`
+	PlanName   string `json:"plan_name"`
+}
+
+// GetBroadcasterSubscriptions returns the subscriptions to the tracked broadcaster with the
+// broadcaster's token, which needs the channel:read:subscriptions scope. If userIDs are given, at
+// most 100, only their subscriptions are returned.
+func GetBroadcasterSubscriptions(tokens *TokenStore, userIDs ...string) ([]ChannelSubscription, error) {
+	broadcasterID := os.Getenv("TWITCH_SENSAI_USER_ID")
+	token, err := tokens.Token(broadcasterID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting broadcaster token: %w", err)
+	}
+	if token == nil || !token.HasScopes("channel:read:subscriptions") {
+		return nil, fmt.Errorf("the broadcaster has not granted jagger channel:read:subscriptions")
+	}
+	query := url.Values{"broadcaster_id": {broadcasterID}}
+	if len(userIDs) > 0 {
+		query["user_id"] = userIDs
+	} else {
+		query.Set("first", "100")
+	}
+	var subscriptions []ChannelSubscription
+	for {
+		var resp struct {
+			Data       []ChannelSubscription `json:"data"`
+			Pagination Pagination            `json:"pagination"`
+		}
+		if err := helixRequest(http.MethodGet, twitchBroadcasterSubscriptionsURL+"?"+query.Encode(), token.AccessToken, nil, &resp); err != nil {
+			return nil, fmt.Errorf("error getting subscriptions: %w", err)
+		}
+		subscriptions = append(subscriptions, resp.Data...)
+		if len(userIDs) > 0 || resp.Pagination.Cursor == "" || len(resp.Data) == 0 {
+			return subscriptions, nil
+		}
+		query.Set("after", resp.Pagination.Cursor)
+	}
+}
@@ -3,14 +3,14 @@ package twitchws
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
-	"strings"
 
-	"github.com/spddl/go-twitch-ws"
+	"github.com/brandonlbarrow/jaggerbot/internal/secrets"
 )
 
 const (
@@ -20,26 +20,53 @@ const (
 	twitchGetUsersURL                          = "https://api.twitch.tv/helix/users"
 	twitchAuthURL                              = "https://id.twitch.tv/oauth2/token"
 	twitchGetChannelInfoURL                    = "https://api.twitch.tv/helix/channels"
+	twitchGetStreamsURL                        = "https://api.twitch.tv/helix/streams"
 	twitchEventSubscriptionStreamOnlineType    = "stream.online"
 	twitchEventSubscriptionStreamOnlineVersion = "1"
-)
 
-type Client struct {
-	ircClient *twitch.Client
-}
+	EventTypeStreamOnline  = twitchEventSubscriptionStreamOnlineType
+	EventTypeStreamOffline = "stream.offline"
+	EventTypeChannelUpdate = "channel.update"
+	EventTypeChannelRaid   = "channel.raid"
 
-func NewIRCClient(channelName string) (*Client, error) {
-	bot, err := twitch.NewClient(&twitch.Client{
-		Server:      twitchEventSubURL,
-		User:        twitchUsername,
-		Debug:       true,
-		BotVerified: false,                                  // verified bots: Have higher chat limits than regular users.
-		Channel:     []string{strings.ToLower(channelName)}, // only in Lowercase
-	})
-	if err != nil {
-		return nil, fmt.Errorf("error creating Twitch EventSub client: %w", err)
-	}
-	return &Client{ircClient: bot}, nil
+	EventTypeChannelFollow              = "channel.follow"
+	EventTypeChannelSubscribe           = "channel.subscribe"
+	EventTypeChannelSubscriptionMessage = "channel.subscription.message"
+	EventTypeChannelSubscriptionGift    = "channel.subscription.gift"
+	EventTypeChannelSubscriptionEnd     = "channel.subscription.end"
+	EventTypeChannelCheer               = "channel.cheer"
+
+	EventTypeChannelPollBegin          = "channel.poll.begin"
+	EventTypeChannelPollProgress       = "channel.poll.progress"
+	EventTypeChannelPollEnd            = "channel.poll.end"
+	EventTypeChannelPredictionBegin    = "channel.prediction.begin"
+	EventTypeChannelPredictionProgress = "channel.prediction.progress"
+	EventTypeChannelPredictionLock     = "channel.prediction.lock"
+	EventTypeChannelPredictionEnd      = "channel.prediction.end"
+
+	EventTypeChannelHypeTrainBegin    = "channel.hype_train.begin"
+	EventTypeChannelHypeTrainProgress = "channel.hype_train.progress"
+	EventTypeChannelHypeTrainEnd      = "channel.hype_train.end"
+
+	EventTypeChannelPointsRedemptionAdd = "channel.channel_points_custom_reward_redemption.add"
+
+	EventTypeChannelBan             = "channel.ban"
+	EventTypeChannelUnban           = "channel.unban"
+	EventTypeChannelModeratorAdd    = "channel.moderator.add"
+	EventTypeChannelModeratorRemove = "channel.moderator.remove"
+	EventTypeAutomodMessageHold     = "automod.message.hold"
+	EventTypeAutomodMessageUpdate   = "automod.message.update"
+)
+
+// eventSubscriptionTypes are the EventSub subscription types created for the tracked broadcaster,
+// with their versions. Condition is the condition field set to the broadcaster's ID,
+// broadcaster_user_id if it is empty.
+var eventSubscriptionTypes = []struct{ Type, Version, Condition string }{
+	{EventTypeStreamOnline, twitchEventSubscriptionStreamOnlineVersion, ""},
+	{EventTypeStreamOffline, "1", ""},
+	{EventTypeChannelUpdate, "2", ""},
+	{EventTypeChannelRaid, "1", "to_broadcaster_user_id"},
+	{EventTypeChannelRaid, "1", "from_broadcaster_user_id"},
 }
 
 type WebsocketMessage struct {
@@ -78,8 +105,10 @@ type AuthResponse struct {
 }
 
 type GetSubscriptionsResponse struct {
-	Total int            `json:"total"`
-	Data  []Subscription `json:"data"`
+	Total        int            `json:"total"`
+	TotalCost    int            `json:"total_cost"`
+	MaxTotalCost int            `json:"max_total_cost"`
+	Data         []Subscription `json:"data"`
 }
 
 type GetChannelInformationResponse struct {
@@ -100,6 +129,25 @@ type ChannelInfo struct {
 	IsBrandedContent            bool     `json:"is_branded_content"`
 }
 
+type GetStreamsResponse struct {
+	Data []StreamInfo `json:"data"`
+}
+
+type StreamInfo struct {
+	ID           string   `json:"id"`
+	UserID       string   `json:"user_id"`
+	UserLogin    string   `json:"user_login"`
+	UserName     string   `json:"user_name"`
+	GameID       string   `json:"game_id"`
+	GameName     string   `json:"game_name"`
+	Type         string   `json:"type"`
+	Title        string   `json:"title"`
+	ViewerCount  int      `json:"viewer_count"`
+	StartedAt    string   `json:"started_at"`
+	ThumbnailURL string   `json:"thumbnail_url"`
+	Tags         []string `json:"tags"`
+}
+
 type Subscription struct {
 	ID        string                `json:"id"`
 	Type      string                `json:"type"`
@@ -108,6 +156,7 @@ type Subscription struct {
 	Condition map[string]string     `json:"condition"`
 	Status    string                `json:"status"`
 	Transport SubscriptionTransport `json:"transport"`
+	CreatedAt string                `json:"created_at,omitempty"`
 }
 
 type SubscriptionTransport struct {
@@ -121,17 +170,31 @@ type Event struct {
 	UserID             string `json:"user_id"`
 	Username           string `json:"user_name"`
 	UserLogin          string `json:"user_login"`
-	BroadcastUserID    string `json:"broadcast_user_id"`
-	BroadcastUsername  string `json:"broadcast_user_name"`
-	BroadcastUserLogin string `json:"broadcast_user_login"`
+	BroadcastUserID    string `json:"broadcaster_user_id"`
+	BroadcastUsername  string `json:"broadcaster_user_name"`
+	BroadcastUserLogin string `json:"broadcaster_user_login"`
 	FollowedAt         string `json:"followed_at"`
 }
 
-func (c *Client) RunIRCClient() {
-	c.ircClient.Run()
+// Notification is an EventSub notification. Event holds the fields common to most subscription
+// types, RawEvent the full event for decoding into a type-specific struct with DecodeEvent.
+type Notification struct {
+	Subscription Subscription
+	Event        Event
+	RawEvent     json.RawMessage
+}
+
+// DecodeEvent unmarshals the raw event of the notification into v.
+func (n Notification) DecodeEvent(v any) error {
+	if err := json.Unmarshal(n.RawEvent, v); err != nil {
+		return fmt.Errorf("error decoding %s event: %w", n.Subscription.Type, err)
+	}
+	return nil
 }
 
-func SetupTwitch() error {
+// SetupTwitch recreates the EventSub subscriptions for the tracked broadcaster with transport,
+// including the ones the broadcaster has granted scopes for in tokens.
+func SetupTwitch(tokens *TokenStore, transport SubscriptionTransport) error {
 
 	authResp, err := authenticateToTwitch()
 	if err != nil {
@@ -142,12 +205,76 @@ func SetupTwitch() error {
 	if err != nil {
 		return fmt.Errorf("error getting twitch subscriptions: %w", err)
 	}
-	if err := subscribeToSensai(authResp, os.Getenv("TWITCH_EVENTSUB_SECRET"), userId); err != nil {
+	if err := subscribeToSensai(authResp, transport, userId); err != nil {
 		return fmt.Errorf("error subscribing to channel broadcast online: %w", err)
 	}
+	if err := SubscribeAuthorized(tokens, userId, transport); err != nil {
+		return fmt.Errorf("error subscribing to broadcaster authorized events: %w", err)
+	}
+	return nil
+}
+
+// RotateSubscriptions creates every subscription of the tracked broadcaster again with transport,
+// then deletes the subscriptions that still use another callback. transport must use a callback
+// that differs from the current one, see WebhookTransport, since Twitch rejects a second
+// subscription with the same type, condition and callback. If a subscription cannot be created, the
+// ones created with transport are deleted again and the old subscriptions are kept.
+func RotateSubscriptions(tokens *TokenStore, transport SubscriptionTransport) error {
+	authResp, err := authenticateToTwitch()
+	if err != nil {
+		return fmt.Errorf("error getting app token: %w", err)
+	}
+	userId := os.Getenv("TWITCH_SENSAI_USER_ID")
+	err = subscribeToSensai(authResp, transport, userId)
+	if err != nil {
+		err = fmt.Errorf("error subscribing with rotated secret: %w", err)
+	} else if err = SubscribeAuthorized(tokens, userId, transport); err != nil {
+		err = fmt.Errorf("error subscribing to broadcaster authorized events with rotated secret: %w", err)
+	}
+	if err != nil {
+		if deleteErr := deleteSubscriptions(authResp, func(s Subscription) bool { return s.Transport.Callback == transport.Callback }); deleteErr != nil {
+			log.Printf("error deleting subscriptions of failed rotation: %s", deleteErr)
+		}
+		return err
+	}
+	if err := deleteSubscriptions(authResp, func(s Subscription) bool { return s.Transport.Callback != transport.Callback }); err != nil {
+		return fmt.Errorf("error deleting subscriptions with old secret: %w", err)
+	}
 	return nil
 }
 
+// deleteSubscriptions deletes the subscriptions matching the filter.
+func deleteSubscriptions(token string, filter func(Subscription) bool) error {
+	subscriptions, err := getEventSubscriptions(token, false)
+	if err != nil {
+		return fmt.Errorf("error getting twitch subscriptions: %w", err)
+	}
+	for _, subscription := range subscriptions.Data {
+		if !filter(subscription) {
+			continue
+		}
+		if err := deleteEventSubscriptions(subscription.ID, token); err != nil {
+			return fmt.Errorf("error deleting subscription %s: %w", subscription.ID, err)
+		}
+	}
+	return nil
+}
+
+// WebhookTransport returns the webhook transport for subscriptions signed with secret. The secret
+// generation is added to the callback URL so subscriptions with a rotated secret can be created
+// next to the existing ones.
+func WebhookTransport(secret string, generation int) SubscriptionTransport {
+	callback := PublicURL() + "/jagger/callback"
+	if generation > 0 {
+		callback = fmt.Sprintf("%s?generation=%d", callback, generation)
+	}
+	return SubscriptionTransport{
+		Method:   "webhook",
+		Secret:   secret,
+		Callback: callback,
+	}
+}
+
 func GetEventSubscriptions() (*GetSubscriptionsResponse, error) {
 	authResp, err := authenticateToTwitch()
 	if err != nil {
@@ -156,6 +283,15 @@ func GetEventSubscriptions() (*GetSubscriptionsResponse, error) {
 	return getEventSubscriptions(authResp, false)
 }
 
+// DeleteEventSubscription deletes the EventSub subscription with subscriptionID.
+func DeleteEventSubscription(subscriptionID string) error {
+	authResp, err := authenticateToTwitch()
+	if err != nil {
+		return fmt.Errorf("error getting app token: %w", err)
+	}
+	return deleteEventSubscriptions(subscriptionID, authResp)
+}
+
 func GetChannelInformation() (*GetChannelInformationResponse, error) {
 	userId := os.Getenv("TWITCH_SENSAI_USER_ID")
 	var getChannelInfoResp GetChannelInformationResponse
@@ -184,11 +320,44 @@ func GetChannelInformation() (*GetChannelInformationResponse, error) {
 	return &getChannelInfoResp, nil
 }
 
+// GetStream returns the live stream of the tracked broadcaster, or nil if the broadcaster is offline.
+func GetStream() (*StreamInfo, error) {
+	userId := os.Getenv("TWITCH_SENSAI_USER_ID")
+	var getStreamsResp GetStreamsResponse
+	token, err := authenticateToTwitch()
+	if err != nil {
+		return nil, fmt.Errorf("error authenticating to Twitch to get stream: %w", err)
+	}
+	httpClient := http.DefaultClient
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s?user_id=%s", twitchGetStreamsURL, userId), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request for getting stream: %w", err)
+	}
+	req.Header.Add("Client-Id", os.Getenv("TWITCH_CLIENT_ID"))
+	req.Header.Add("Authorization", fmt.Sprint("Bearer ", token))
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending http request to get stream: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code from response was not OK: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&getStreamsResp); err != nil {
+		return nil, fmt.Errorf("could not decode response body from get streams response: %w", err)
+	}
+	if len(getStreamsResp.Data) == 0 {
+		return nil, nil
+	}
+	return &getStreamsResp.Data[0], nil
+}
+
 func authenticateToTwitch() (string, error) {
 	httpClient := http.DefaultClient
 	authReq := AuthRequest{
 		ClientID:     os.Getenv("TWITCH_CLIENT_ID"),
-		ClientSecret: os.Getenv("TWITCH_BOT_TOKEN"),
+		ClientSecret: secrets.Get("TWITCH_BOT_TOKEN"),
 		GrantType:    "client_credentials",
 	}
 	marshaledReq, err := json.Marshal(&authReq)
@@ -212,49 +381,38 @@ func authenticateToTwitch() (string, error) {
 	return fmt.Sprint(authResp.AccessToken), nil
 }
 
-func subscribeToSensai(token, secret, channelID string) error {
-	httpClient := http.DefaultClient
-	subscriptionReq := Subscription{
-		Type:    twitchEventSubscriptionStreamOnlineType,
-		Version: twitchEventSubscriptionStreamOnlineVersion,
-		Condition: map[string]string{
-			"broadcaster_user_id": channelID,
-		},
-		Transport: SubscriptionTransport{
-			Method:   "webhook",
-			Secret:   secret,
-			Callback: "https://gonkbot.brandonbarrow.com/jagger/callback",
-		},
-	}
-	marshaledReqBody, err := json.Marshal(&subscriptionReq)
-	if err != nil {
-		return err
-	}
-	r := io.NopCloser(bytes.NewBuffer(marshaledReqBody))
-	req, err := http.NewRequest(http.MethodPost, twitchEventSubscriptionsURL, r)
-	if err != nil {
-		return err
-	}
-	req.Header.Add("Client-Id", os.Getenv("TWITCH_CLIENT_ID"))
-	req.Header.Add("Authorization", fmt.Sprint("Bearer ", token))
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return err
-	}
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-	if strings.Contains(string(bodyBytes), "subscription already exists") {
-		log.Println("subscription already exists, doing nothing")
-	} else {
-		log.Println(string(bodyBytes))
+func subscribeToSensai(token string, transport SubscriptionTransport, channelID string) error {
+	for _, subscriptionType := range eventSubscriptionTypes {
+		condition := subscriptionType.Condition
+		if condition == "" {
+			condition = "broadcaster_user_id"
+		}
+		if err := subscribe(token, transport, subscriptionType.Type, subscriptionType.Version, map[string]string{
+			condition: channelID,
+		}); err != nil {
+			return fmt.Errorf("error subscribing to %s: %w", subscriptionType.Type, err)
+		}
 	}
-
 	return nil
 }
 
+// subscribe creates a subscription, or does nothing if it already exists. Any other response is an
+// error, callers such as RotateSubscriptions rely on it to keep working subscriptions.
+func subscribe(token string, transport SubscriptionTransport, subscriptionType, version string, condition map[string]string) error {
+	err := helixRequest(http.MethodPost, twitchEventSubscriptionsURL, token, Subscription{
+		Type:      subscriptionType,
+		Version:   version,
+		Condition: condition,
+		Transport: transport,
+	}, nil)
+	var helixErr *HelixError
+	if errors.As(err, &helixErr) && helixErr.StatusCode == http.StatusConflict {
+		log.Printf("%s subscription already exists, doing nothing", subscriptionType)
+		return nil
+	}
+	return err
+}
+
 func getEventSubscriptions(token string, flush bool) (*GetSubscriptionsResponse, error) {
 	httpClient := http.DefaultClient
 	req, err := http.NewRequest(http.MethodGet, twitchEventSubscriptionsURL, nil)
@@ -300,9 +458,13 @@ func deleteEventSubscriptions(subscriptionID, token string) error {
 	req.Header.Add("Client-Id", os.Getenv("TWITCH_CLIENT_ID"))
 	req.Header.Add("Authorization", fmt.Sprint("Bearer ", token))
 	req.Header.Add("Content-Type", "application/json")
-	_, err = httpClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("status code from response was not No Content: %s", resp.Status)
+	}
 	return nil
 }
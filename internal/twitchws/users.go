@@ -0,0 +1,64 @@
+package twitchws
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// User is a Twitch user.
+type User struct {
+	ID              string `json:"id"`
+	Login           string `json:"login"`
+	DisplayName     string `json:"display_name"`
+	Type            string `json:"type"`
+	BroadcasterType string `json:"broadcaster_type"`
+	Description     string `json:"description"`
+	ProfileImageURL string `json:"profile_image_url"`
+	OfflineImageURL string `json:"offline_image_url"`
+	CreatedAt       string `json:"created_at"`
+}
+
+type getUsersResponse struct {
+	Data []User `json:"data"`
+}
+
+// GetUser returns the user with userID, or nil if there is no such user.
+func GetUser(userID string) (*User, error) {
+	token, err := authenticateToTwitch()
+	if err != nil {
+		return nil, fmt.Errorf("error authenticating to Twitch to get user: %w", err)
+	}
+	var resp getUsersResponse
+	if err := helixRequest(http.MethodGet, twitchGetUsersURL+"?"+url.Values{"id": {userID}}.Encode(), token, nil, &resp); err != nil {
+		return nil, fmt.Errorf("error getting user %s: %w", userID, err)
+	}
+	if len(resp.Data) == 0 {
+		return nil, nil
+	}
+	return &resp.Data[0], nil
+}
+
+const twitchGetChannelFollowersURL = "https://api.twitch.tv/helix/channels/followers"
+
+// GetFollowerCount returns how many followers the tracked broadcaster has. Twitch only returns
+// followers for user tokens, so the broadcaster must have authorized jagger.
+func GetFollowerCount(tokens *TokenStore) (int, error) {
+	broadcasterID := os.Getenv("TWITCH_SENSAI_USER_ID")
+	token, err := tokens.Token(broadcasterID)
+	if err != nil {
+		return 0, fmt.Errorf("error getting broadcaster token: %w", err)
+	}
+	if token == nil {
+		return 0, fmt.Errorf("the broadcaster has not authorized jagger")
+	}
+	var resp struct {
+		Total int `json:"total"`
+	}
+	query := url.Values{"broadcaster_id": {broadcasterID}, "first": {"1"}}
+	if err := helixRequest(http.MethodGet, twitchGetChannelFollowersURL+"?"+query.Encode(), token.AccessToken, nil, &resp); err != nil {
+		return 0, fmt.Errorf("error getting followers: %w", err)
+	}
+	return resp.Total, nil
+}
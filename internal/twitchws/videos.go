@@ -0,0 +1,68 @@
+package twitchws
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	twitchGetVideosURL = "https://api.twitch.tv/helix/videos"
+
+	VideoTypeArchive   = "archive"
+	VideoTypeHighlight = "highlight"
+	VideoTypeUpload    = "upload"
+)
+
+// Video is a Twitch video: the archive of a past broadcast, a highlight or an upload.
+type Video struct {
+	ID string `json:"id"`
+	// StreamID is the stream an archive was recorded from, nil for other videos.
+	StreamID     *string   `json:"stream_id"`
+	UserID       string    `json:"user_id"`
+	UserLogin    string    `json:"user_login"`
+	UserName     string    `json:"user_name"`
+	Title        string    `json:"title"`
+	Description  string    `json:"description"`
+	CreatedAt    time.Time `json:"created_at"`
+	PublishedAt  time.Time `json:"published_at"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	Viewable     string    `json:"viewable"`
+	ViewCount    int       `json:"view_count"`
+	Language     string    `json:"language"`
+	Type         string    `json:"type"`
+	// Duration is formatted like 3h8m33s.
+	Duration string `json:"duration"`
+}
+
+// Thumbnail returns the URL of the video's thumbnail in the given size.
+func (v Video) Thumbnail(width, height int) string {
+	return strings.NewReplacer(
+		"%{width}", fmt.Sprint(width),
+		"%{height}", fmt.Sprint(height),
+	).Replace(v.ThumbnailURL)
+}
+
+// GetVideos returns the most recent videos of videoType of userID, newest first.
+func GetVideos(userID, videoType string) ([]Video, error) {
+	token, err := authenticateToTwitch()
+	if err != nil {
+		return nil, fmt.Errorf("error authenticating to Twitch to get videos: %w", err)
+	}
+	query := url.Values{
+		"user_id": {userID},
+		"type":    {videoType},
+		"sort":    {"time"},
+		"first":   {"20"},
+	}
+	var resp struct {
+		Data []Video `json:"data"`
+	}
+	if err := helixRequest(http.MethodGet, twitchGetVideosURL+"?"+query.Encode(), token, nil, &resp); err != nil {
+		return nil, fmt.Errorf("error getting videos: %w", err)
+	}
+	return resp.Data, nil
+}
@@ -4,14 +4,16 @@ import (
 	"bytes"
 	"crypto/hmac"
 	"crypto/sha256"
-	//"crypto/subtle"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
-	"os"
+	"time"
 
 	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
 )
@@ -21,60 +23,156 @@ const (
 	TwitchEventsubMessageTimestampHeader = "Twitch-Eventsub-Message-Timestamp"
 	TwitchEventsubMessageSignatureHeader = "Twitch-Eventsub-Message-Signature"
 	TwitchEventsubMessageTypeHeader      = "Twitch-Eventsub-Message-Type"
+	TwitchEventsubSubscriptionTypeHeader = "Twitch-Eventsub-Subscription-Type"
 )
 
+// DefaultMaxBodyBytes is the largest callback body accepted if Handler.MaxBodyBytes is not set.
+// EventSub payloads are a few kilobytes at most.
+const DefaultMaxBodyBytes = 1 << 20
+
+// DefaultQueueTimeout is how long a notification waits to be queued if Handler.QueueTimeout is not
+// set. Twitch retries notifications that are not answered within a few seconds.
+const DefaultQueueTimeout = 2 * time.Second
+
 type Handler struct {
-	EventChannel      chan twitchws.Event
+	EventChannel      chan twitchws.Notification
 	ErrorEventChannel chan error
+	// Secrets are the EventSub secrets notifications may be signed with. Every request is rejected
+	// if it is nil.
+	Secrets SecretSource
+	// MaxBodyBytes limits the size of callback bodies, DefaultMaxBodyBytes is used if it is 0.
+	MaxBodyBytes int64
+	// QueueTimeout limits how long a notification waits for room in EventChannel before the request
+	// fails with 503 so Twitch retries it, DefaultQueueTimeout is used if it is 0.
+	QueueTimeout time.Duration
+	// Limiter, if set, blocks clients after too many requests failed verification.
+	Limiter *FailureLimiter
+	// TrustProxy identifies clients by X-Forwarded-For for the Limiter, set it when the handler
+	// runs behind a reverse proxy.
+	TrustProxy bool
+}
+
+// SecretSource provides the secrets EventSub notifications may currently be signed with, see
+// secrets.EventSubSecrets.
+type SecretSource interface {
+	Accepted() []string
+}
+
+// StaticSecrets is a SecretSource that always accepts the same secrets.
+type StaticSecrets []string
+
+func (s StaticSecrets) Accepted() []string {
+	return s
 }
 
 func (h *Handler) HandleTwitchCallback(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("got request")
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+	client := clientIP(r, h.TrustProxy)
+	if h.Limiter != nil && !h.Limiter.Allow(client) {
+		log.Printf("rejecting request from %s after too many failures", client)
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	maxBodyBytes := h.MaxBodyBytes
+	if maxBodyBytes == 0 {
+		maxBodyBytes = DefaultMaxBodyBytes
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		log.Printf("error reading request body from %s: %s", client, err)
+		h.fail(client)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
 	if err := h.verifyMessageSignature(w, r); err != nil {
 		log.Print(err)
+		h.fail(client)
 		w.WriteHeader(http.StatusForbidden)
 		return
 	}
-	if r.Header.Get(TwitchEventsubMessageTypeHeader) == "webhook_callback_verification" {
+	switch messageType := r.Header.Get(TwitchEventsubMessageTypeHeader); messageType {
+	case "webhook_callback_verification":
 		log.Printf("got webhook_callback_verification request")
 		h.handleChallengeVerification(w, r)
-		return
-	}
-	if r.Header.Get(TwitchEventsubMessageTypeHeader) == "notification" {
+	case "notification":
 		log.Printf("got notification request")
 		h.handleSubscriptionEventNotification(w, r)
-		return
+	case "revocation":
+		log.Printf("got revocation request")
+		h.handleRevocation(w, r)
+	default:
+		log.Printf("got unknown message type %q", messageType)
+		w.WriteHeader(http.StatusBadRequest)
 	}
 }
 
+// reportFailure passes a verification failure on to ErrorEventChannel if it is ready to receive it
+// and drops it otherwise. Anyone can send failing requests, they must not be able to block the handler.
+func (h *Handler) reportFailure(err error) {
+	select {
+	case h.ErrorEventChannel <- err:
+	default:
+	}
+}
+
+func (h *Handler) fail(client string) {
+	if h.Limiter != nil {
+		h.Limiter.Fail(client)
+	}
+}
+
+// verifyMessageSignature checks that r is signed with one of the accepted secrets. Failures never
+// include the expected signature, it would let anyone forge messages for the body they sent.
 func (h *Handler) verifyMessageSignature(w http.ResponseWriter, r *http.Request) error {
-	secret := os.Getenv("TWITCH_EVENTSUB_SECRET")
 	incomingReqMessageID := r.Header.Get(TwitchEventsubMessageIDHeader)
 	incomingReqMessageTimestamp := r.Header.Get(TwitchEventsubMessageTimestampHeader)
+	if h.Secrets == nil {
+		respErr := fmt.Errorf("verifyMessageSignature: no EventSub secrets are configured\nDetails: RequestMessageID: %s", incomingReqMessageID)
+		h.reportFailure(respErr)
+		return respErr
+	}
 	incomingReqRawBody, err := io.ReadAll(r.Body)
 	r.Body = io.NopCloser(bytes.NewBuffer(incomingReqRawBody))
 
 	if err != nil {
 		respErr := fmt.Errorf("verifyMessageSignature: error reading raw request body: %w\nDetails: RequestMessageID: %s\nRequestTimestamp:%s", err, incomingReqMessageID, incomingReqMessageTimestamp)
-		log.Println(respErr)
-		h.ErrorEventChannel <- respErr
+		h.reportFailure(respErr)
 		return respErr
 	}
-	hmacMessage := fmt.Sprintf("%s%s%s", incomingReqMessageID, incomingReqMessageTimestamp, incomingReqRawBody)
-	hm := hmac.New(sha256.New, []byte(secret))
-	hm.Write([]byte(hmacMessage))
-	incomingSignature := fmt.Sprint("sha256=", hex.EncodeToString(hm.Sum(nil)))
-	providedSignature := r.Header.Get(TwitchEventsubMessageSignatureHeader)
-	//subtle.ConstantTimeCompare([]byte(incomingSignature), []byte(providedSignature))
-	if incomingSignature != providedSignature {
-		respErr := fmt.Errorf("verifyMessageSignature: error signatures do not match:\nDetails: RequestBody: %v\nRequestSignature: %s\nProvidedSignature: %s\nRequestMessageID: %s\nRequestTimestamp: %v",
-			incomingReqRawBody, incomingSignature, providedSignature, incomingReqMessageID, incomingReqMessageTimestamp)
-		log.Println(respErr)
-		h.ErrorEventChannel <- respErr
-		return respErr
+	providedSignature := []byte(r.Header.Get(TwitchEventsubMessageSignatureHeader))
+	valid := 0
+	// Every secret is checked even after a match so the time taken does not reveal which one matched.
+	for _, secret := range h.Secrets.Accepted() {
+		hm := hmac.New(sha256.New, []byte(secret))
+		hm.Write([]byte(incomingReqMessageID))
+		hm.Write([]byte(incomingReqMessageTimestamp))
+		hm.Write(incomingReqRawBody)
+		expectedSignature := []byte(fmt.Sprint("sha256=", hex.EncodeToString(hm.Sum(nil))))
+		valid |= subtle.ConstantTimeCompare(expectedSignature, providedSignature)
 	}
-	return nil
+	if valid == 1 {
+		return nil
+	}
+	respErr := fmt.Errorf("verifyMessageSignature: signature does not match any accepted secret\nDetails: RequestMessageID: %s\nRequestTimestamp: %s\nSubscriptionType: %s",
+		incomingReqMessageID, incomingReqMessageTimestamp, r.Header.Get(TwitchEventsubSubscriptionTypeHeader))
+	h.reportFailure(respErr)
+	return respErr
 }
 
 func (h *Handler) handleChallengeVerification(w http.ResponseWriter, r *http.Request) {
@@ -85,7 +183,7 @@ func (h *Handler) handleChallengeVerification(w http.ResponseWriter, r *http.Req
 	if err != nil {
 		respErr := fmt.Errorf("handleChallengeVerification: cannot read request body: %w", err)
 		log.Println(respErr)
-		h.ErrorEventChannel <- respErr
+		h.reportFailure(respErr)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -93,7 +191,7 @@ func (h *Handler) handleChallengeVerification(w http.ResponseWriter, r *http.Req
 	if err := json.Unmarshal(reqBody, &challengeRequest); err != nil {
 		respErr := fmt.Errorf("handleChallengeVerification: cannot unmarshal request body: %w\nDetails: RequestBody: %v", err, reqBody)
 		log.Println(respErr)
-		h.ErrorEventChannel <- respErr
+		h.reportFailure(respErr)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -116,25 +214,78 @@ func (h *Handler) handleSubscriptionEventNotification(w http.ResponseWriter, r *
 	if err != nil {
 		respErr := fmt.Errorf("handleSubscriptionEventNotification: cannot read request body: %w", err)
 		log.Println(respErr)
-		h.ErrorEventChannel <- respErr
+		h.reportFailure(respErr)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 	var event subscriptionEventNotificationRequest
 	if err := json.Unmarshal(reqBody, &event); err != nil {
 		respErr := fmt.Errorf("handleSubscriptionEventNotification: cannot unmarshal request body: %w\nDetails: RequestBody: %v", err, reqBody)
-		h.ErrorEventChannel <- respErr
+		h.reportFailure(respErr)
 		log.Println(respErr)
 		log.Printf("%v", string(reqBody))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	log.Printf("event: %v\n", event)
-	h.EventChannel <- event.Event
+	var commonEvent twitchws.Event
+	if err := json.Unmarshal(event.Event, &commonEvent); err != nil {
+		respErr := fmt.Errorf("handleSubscriptionEventNotification: cannot unmarshal %s event: %w", event.Subscription.Type, err)
+		h.reportFailure(respErr)
+		log.Println(respErr)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	log.Printf("event: %s %v\n", event.Subscription.Type, commonEvent)
+	queueTimeout := h.QueueTimeout
+	if queueTimeout == 0 {
+		queueTimeout = DefaultQueueTimeout
+	}
+	timer := time.NewTimer(queueTimeout)
+	defer timer.Stop()
+	select {
+	case h.EventChannel <- twitchws.Notification{
+		Subscription: event.Subscription,
+		Event:        commonEvent,
+		RawEvent:     event.Event,
+	}:
+	case <-timer.C:
+		respErr := fmt.Errorf("handleSubscriptionEventNotification: %s event was not queued within %s", event.Subscription.Type, queueTimeout)
+		log.Println(respErr)
+		h.reportFailure(respErr)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
 type subscriptionEventNotificationRequest struct {
 	Subscription twitchws.Subscription `json:"subscription"`
-	Event        twitchws.Event        `json:"event"`
+	Event        json.RawMessage       `json:"event"`
+}
+
+func (h *Handler) handleRevocation(w http.ResponseWriter, r *http.Request) {
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		respErr := fmt.Errorf("handleRevocation: cannot read request body: %w", err)
+		log.Println(respErr)
+		h.reportFailure(respErr)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var revocation revocationRequest
+	if err := json.Unmarshal(reqBody, &revocation); err != nil {
+		respErr := fmt.Errorf("handleRevocation: cannot unmarshal request body: %w", err)
+		log.Println(respErr)
+		h.reportFailure(respErr)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	respErr := fmt.Errorf("handleRevocation: Twitch revoked the %s subscription %s: %s", revocation.Subscription.Type, revocation.Subscription.ID, revocation.Subscription.Status)
+	log.Println(respErr)
+	h.reportFailure(respErr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type revocationRequest struct {
+	Subscription twitchws.Subscription `json:"subscription"`
 }
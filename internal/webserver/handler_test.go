@@ -0,0 +1,239 @@
+package webserver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+)
+
+const testSecret = "s3cr3t-for-tests"
+
+// signedRequest returns a callback request of messageType for body, signed with secret.
+func signedRequest(secret, messageType, body string) *http.Request {
+	messageID, timestamp := "message-1", time.Now().UTC().Format(time.RFC3339)
+	hm := hmac.New(sha256.New, []byte(secret))
+	hm.Write([]byte(messageID + timestamp + body))
+	r := httptest.NewRequest(http.MethodPost, "/jagger/callback", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(TwitchEventsubMessageIDHeader, messageID)
+	r.Header.Set(TwitchEventsubMessageTimestampHeader, timestamp)
+	r.Header.Set(TwitchEventsubMessageSignatureHeader, "sha256="+hex.EncodeToString(hm.Sum(nil)))
+	r.Header.Set(TwitchEventsubMessageTypeHeader, messageType)
+	r.Header.Set(TwitchEventsubSubscriptionTypeHeader, "stream.online")
+	return r
+}
+
+func newTestHandler() *Handler {
+	return &Handler{
+		EventChannel:      make(chan twitchws.Notification, 1),
+		ErrorEventChannel: make(chan error, 1),
+		Secrets:           StaticSecrets{testSecret},
+	}
+}
+
+func TestHandleTwitchCallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		code    int
+		body    string
+	}{
+		{
+			name: "non-POST",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/jagger/callback", nil)
+			},
+			code: http.StatusMethodNotAllowed,
+		},
+		{
+			name: "wrong content type",
+			request: func() *http.Request {
+				r := signedRequest(testSecret, "notification", `{}`)
+				r.Header.Set("Content-Type", "text/plain")
+				return r
+			},
+			code: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "oversized body",
+			request: func() *http.Request {
+				return signedRequest(testSecret, "notification", fmt.Sprintf(`{"padding":%q}`, strings.Repeat("a", DefaultMaxBodyBytes)))
+			},
+			code: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "missing signature",
+			request: func() *http.Request {
+				r := signedRequest(testSecret, "notification", `{}`)
+				r.Header.Del(TwitchEventsubMessageSignatureHeader)
+				return r
+			},
+			code: http.StatusForbidden,
+		},
+		{
+			name: "signed with another secret",
+			request: func() *http.Request {
+				return signedRequest("another-secret", "notification", `{}`)
+			},
+			code: http.StatusForbidden,
+		},
+		{
+			name: "unknown message type",
+			request: func() *http.Request {
+				return signedRequest(testSecret, "something_else", `{}`)
+			},
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "verification",
+			request: func() *http.Request {
+				return signedRequest(testSecret, "webhook_callback_verification", `{"challenge":"pogchamp-kappa-360"}`)
+			},
+			code: http.StatusOK,
+			body: "pogchamp-kappa-360",
+		},
+		{
+			name: "revocation",
+			request: func() *http.Request {
+				return signedRequest(testSecret, "revocation", `{"subscription":{"id":"1","type":"stream.online","status":"authorization_revoked"}}`)
+			},
+			code: http.StatusNoContent,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newTestHandler().HandleTwitchCallback(w, test.request())
+			if w.Code != test.code {
+				t.Fatalf("returned %d, want %d", w.Code, test.code)
+			}
+			if test.body != "" && w.Body.String() != test.body {
+				t.Fatalf("returned body %q, want %q", w.Body, test.body)
+			}
+		})
+	}
+}
+
+func TestHandleTwitchCallbackNotification(t *testing.T) {
+	h := newTestHandler()
+	w := httptest.NewRecorder()
+	h.HandleTwitchCallback(w, signedRequest(testSecret, "notification", `{"subscription":{"id":"1","type":"stream.online"},"event":{"broadcaster_user_id":"12826"}}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("returned %d, want %d", w.Code, http.StatusOK)
+	}
+	select {
+	case notification := <-h.EventChannel:
+		if notification.Subscription.Type != "stream.online" {
+			t.Fatalf("received %s notification, want stream.online", notification.Subscription.Type)
+		}
+	default:
+		t.Fatal("no notification was received")
+	}
+}
+
+func TestBadSignatureIsNotEchoed(t *testing.T) {
+	h := newTestHandler()
+	r := signedRequest("another-secret", "notification", `{}`)
+	signature := r.Header.Get(TwitchEventsubMessageSignatureHeader)
+	w := httptest.NewRecorder()
+	h.HandleTwitchCallback(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("returned %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if strings.Contains(w.Body.String(), signature) {
+		t.Fatalf("response %q echoes the signature", w.Body)
+	}
+	select {
+	case err := <-h.ErrorEventChannel:
+		if strings.Contains(err.Error(), signature) {
+			t.Fatalf("reported error %q contains the signature", err)
+		}
+	default:
+		t.Fatal("the failure was not reported")
+	}
+}
+
+func TestBadSignatureDoesNotBlockWithoutReceiver(t *testing.T) {
+	h := newTestHandler()
+	h.ErrorEventChannel = make(chan error)
+	done := make(chan struct{})
+	go func() {
+		h.HandleTwitchCallback(httptest.NewRecorder(), signedRequest("another-secret", "notification", `{}`))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler blocked reporting the failure")
+	}
+}
+
+func TestLimiterBlocksAfterFailures(t *testing.T) {
+	h := newTestHandler()
+	h.ErrorEventChannel = nil
+	h.Limiter = NewFailureLimiter(3, time.Minute)
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		h.HandleTwitchCallback(w, signedRequest("another-secret", "notification", `{}`))
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("failure %d returned %d, want %d", i+1, w.Code, http.StatusForbidden)
+		}
+	}
+	w := httptest.NewRecorder()
+	h.HandleTwitchCallback(w, signedRequest(testSecret, "webhook_callback_verification", `{"challenge":"c"}`))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request after the failures returned %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestHandleTwitchCallbackQueueFull(t *testing.T) {
+	h := newTestHandler()
+	h.EventChannel = make(chan twitchws.Notification)
+	h.QueueTimeout = 10 * time.Millisecond
+	w := httptest.NewRecorder()
+	h.HandleTwitchCallback(w, signedRequest(testSecret, "notification", `{"subscription":{"id":"1","type":"stream.online"},"event":{"broadcaster_user_id":"12826"}}`))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("returned %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	select {
+	case <-h.ErrorEventChannel:
+	default:
+		t.Fatal("the failure was not reported")
+	}
+}
+
+func TestFailuresDoNotBlockWithoutReceiver(t *testing.T) {
+	requests := map[string]func() *http.Request{
+		"bad notification": func() *http.Request { return signedRequest(testSecret, "notification", `not json`) },
+		"bad verification": func() *http.Request {
+			return signedRequest(testSecret, "webhook_callback_verification", `not json`)
+		},
+		"revocation": func() *http.Request {
+			return signedRequest(testSecret, "revocation", `{"subscription":{"id":"1","type":"stream.online","status":"authorization_revoked"}}`)
+		},
+	}
+	for name, request := range requests {
+		t.Run(name, func(t *testing.T) {
+			h := newTestHandler()
+			h.ErrorEventChannel = make(chan error)
+			done := make(chan struct{})
+			go func() {
+				h.HandleTwitchCallback(httptest.NewRecorder(), request())
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("handler blocked reporting the failure")
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package webserver
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brandonlbarrow/jaggerbot/internal/twitchws"
+)
+
+const (
+	// consentLinkTTL is how long a consent link handed out by an admin stays valid.
+	consentLinkTTL = 30 * time.Minute
+	// oauthStateCookie binds a flow to the browser that started it, so a callback URL of someone
+	// else's flow cannot be completed in another browser.
+	oauthStateCookie = "jagger_oauth_state"
+)
+
+type consentRequest struct {
+	scopes []string
+	// discordUserID is set for account links, which link the Twitch user to this Discord user
+	// instead of storing their token.
+	discordUserID string
+	expiresAt     time.Time
+}
+
+// OAuthHandler hosts the Twitch authorization code flow. Flows can only be started from links
+// created with NewConsentLink or NewAccountLink, so random visitors cannot start one, and can only be
+// completed in the browser that started them.
+type OAuthHandler struct {
+	Tokens            *twitchws.TokenStore
+	ErrorEventChannel chan error
+	// OnAuthorized is called with every token granted through the callback. It runs in its own
+	// goroutine so slow work such as creating subscriptions does not hold up the response.
+	OnAuthorized func(token *twitchws.UserToken)
+	// OnLinked is called when a Discord user links their Twitch account through an account link.
+	OnLinked func(discordUserID, twitchUserID, twitchLogin string) error
+
+	mu       sync.Mutex
+	requests map[string]consentRequest
+}
+
+// NewConsentLink returns a link to /jagger/oauth/start that asks the visitor to grant scopes.
+func (h *OAuthHandler) NewConsentLink(scopes []string) (string, error) {
+	return h.newLink(consentRequest{scopes: scopes})
+}
+
+// NewAccountLink returns a link to /jagger/oauth/start that links the Twitch account of the visitor
+// to discordUserID. No scopes are requested.
+func (h *OAuthHandler) NewAccountLink(discordUserID string) (string, error) {
+	return h.newLink(consentRequest{discordUserID: discordUserID})
+}
+
+func (h *OAuthHandler) newLink(req consentRequest) (string, error) {
+	state, err := randomState()
+	if err != nil {
+		return "", fmt.Errorf("error generating oauth state: %w", err)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.requests == nil {
+		h.requests = make(map[string]consentRequest)
+	}
+	now := time.Now()
+	for s, pending := range h.requests {
+		if now.After(pending.expiresAt) {
+			delete(h.requests, s)
+		}
+	}
+	req.expiresAt = now.Add(consentLinkTTL)
+	h.requests[state] = req
+	return fmt.Sprintf("%s/jagger/oauth/start?state=%s", twitchws.PublicURL(), state), nil
+}
+
+func (h *OAuthHandler) HandleStart(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	req, ok := h.request(state, false)
+	if !ok {
+		http.Error(w, "this link is invalid or has expired, please request a new one", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/jagger/oauth",
+		MaxAge:   int(time.Until(req.expiresAt).Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(twitchws.PublicURL(), "https://"),
+		// Lax cookies are sent on the top level redirect back from Twitch.
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, twitchws.AuthorizeURL(state, req.scopes), http.StatusFound)
+}
+
+func (h *OAuthHandler) HandleCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	cookie, err := r.Cookie(oauthStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(query.Get("state"))) != 1 {
+		http.Error(w, "this authorization was started in another browser, please open your link again in this one", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Path: "/jagger/oauth", MaxAge: -1})
+	req, ok := h.request(query.Get("state"), true)
+	if !ok {
+		http.Error(w, "this link is invalid or has expired, please request a new one", http.StatusBadRequest)
+		return
+	}
+	if errValue := query.Get("error"); errValue != "" {
+		log.Printf("oauth consent was not granted: %s: %s", errValue, query.Get("error_description"))
+		http.Error(w, "consent was not granted, nothing was changed", http.StatusOK)
+		return
+	}
+	if req.discordUserID != "" {
+		h.link(w, req.discordUserID, query.Get("code"))
+		return
+	}
+	token, err := h.Tokens.ExchangeCode(query.Get("code"))
+	if err != nil {
+		respErr := fmt.Errorf("HandleCallback: %w", err)
+		log.Println(respErr)
+		h.ErrorEventChannel <- respErr
+		http.Error(w, "could not complete authorization, please try again", http.StatusBadGateway)
+		return
+	}
+	log.Printf("%s granted scopes %v", token.Login, token.Scopes)
+	if h.OnAuthorized != nil {
+		go h.OnAuthorized(token)
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("Thanks %s, jagger is now authorized. You can close this page.", token.Login)))
+}
+
+// link links the Twitch account that granted code to discordUserID.
+func (h *OAuthHandler) link(w http.ResponseWriter, discordUserID, code string) {
+	twitchUserID, login, err := twitchws.IdentifyUser(code)
+	if err == nil && h.OnLinked != nil {
+		err = h.OnLinked(discordUserID, twitchUserID, login)
+	}
+	if err != nil {
+		respErr := fmt.Errorf("HandleCallback: error linking Discord user %s: %w", discordUserID, err)
+		log.Println(respErr)
+		h.ErrorEventChannel <- respErr
+		http.Error(w, "could not link your account, please try again", http.StatusBadGateway)
+		return
+	}
+	log.Printf("%s linked Discord user %s", login, discordUserID)
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("Thanks %s, your Twitch account is now linked to your Discord account. You can close this page.", login)))
+}
+
+// request returns the pending consent request for state, removing it if consume is set.
+func (h *OAuthHandler) request(state string, consume bool) (consentRequest, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	req, ok := h.requests[state]
+	if !ok || state == "" {
+		return consentRequest{}, false
+	}
+	if consume || time.Now().After(req.expiresAt) {
+		delete(h.requests, state)
+	}
+	return req, time.Now().Before(req.expiresAt)
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
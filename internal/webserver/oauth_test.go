@@ -0,0 +1,46 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestOAuthCallbackRequiresStartCookie(t *testing.T) {
+	h := &OAuthHandler{}
+	link, err := h.NewAccountLink("123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := linkURL.Query().Get("state")
+
+	start := httptest.NewRecorder()
+	h.HandleStart(start, httptest.NewRequest(http.MethodGet, linkURL.RequestURI(), nil))
+	if start.Code != http.StatusFound {
+		t.Fatalf("start returned %d, want %d", start.Code, http.StatusFound)
+	}
+	cookies := start.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oauthStateCookie || cookies[0].Value != state {
+		t.Fatalf("start set cookies %v, want the state cookie", cookies)
+	}
+
+	callbackURL := "/jagger/oauth/callback?" + url.Values{"state": {state}, "error": {"access_denied"}}.Encode()
+	other := httptest.NewRecorder()
+	h.HandleCallback(other, httptest.NewRequest(http.MethodGet, callbackURL, nil))
+	if other.Code != http.StatusBadRequest {
+		t.Fatalf("callback without the cookie returned %d, want %d", other.Code, http.StatusBadRequest)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, callbackURL, nil)
+	req.AddCookie(cookies[0])
+	same := httptest.NewRecorder()
+	h.HandleCallback(same, req)
+	if same.Code != http.StatusOK {
+		t.Fatalf("callback with the cookie returned %d, want %d: %s", same.Code, http.StatusOK, same.Body)
+	}
+}
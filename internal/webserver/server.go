@@ -0,0 +1,185 @@
+package webserver
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 10 * time.Second
+	writeTimeout      = 10 * time.Second
+	idleTimeout       = 60 * time.Second
+	maxHeaderBytes    = 16 << 10
+)
+
+// NewServer returns an http.Server for handler with timeouts suited to the callback server, so slow
+// or idle clients cannot hold connections open.
+func NewServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+		MaxHeaderBytes:    maxHeaderBytes,
+	}
+}
+
+// ListenConfig is how the callback server listens. TLS is served from CertFile and KeyFile if they
+// are set, or with certificates for Hostname from Let's Encrypt if Autocert is set. Otherwise plain
+// HTTP is served and TLS is left to a reverse proxy.
+type ListenConfig struct {
+	// Addr is the address the server listens on, e.g. ":8080" or ":443" with TLS.
+	Addr string
+	// RedirectAddr, with TLS, is the plain HTTP address redirecting to HTTPS, e.g. ":80". Autocert
+	// answers its HTTP challenges there too. No redirect is served if it is empty.
+	RedirectAddr string
+	// Hostname is the public host name of the server.
+	Hostname string
+	CertFile string
+	KeyFile  string
+	Autocert bool
+	// AutocertCacheDir is where certificates from Let's Encrypt are kept across restarts.
+	AutocertCacheDir string
+	// AutocertEmail is given to Let's Encrypt for notices about the certificates.
+	AutocertEmail string
+}
+
+// TLS reports whether the server terminates TLS itself.
+func (c ListenConfig) TLS() bool {
+	return c.Autocert || (c.CertFile != "" && c.KeyFile != "")
+}
+
+// ListenAndServe serves handler as configured by config. It only returns when a server fails.
+func ListenAndServe(config ListenConfig, handler http.Handler) error {
+	server := NewServer(config.Addr, handler)
+	if !config.TLS() {
+		log.Printf("listening on %s", config.Addr)
+		return server.ListenAndServe()
+	}
+	redirect := redirectHandler(config)
+	if config.Autocert {
+		if config.Hostname == "" {
+			return fmt.Errorf("autocert needs a hostname")
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(config.Hostname),
+			Cache:      autocert.DirCache(config.AutocertCacheDir),
+			Email:      config.AutocertEmail,
+		}
+		server.TLSConfig = manager.TLSConfig()
+		redirect = manager.HTTPHandler(redirect)
+	}
+	errs := make(chan error, 2)
+	if config.RedirectAddr != "" {
+		go func() {
+			log.Printf("redirecting HTTP on %s to HTTPS", config.RedirectAddr)
+			errs <- fmt.Errorf("error running HTTP redirect: %w", NewServer(config.RedirectAddr, redirect).ListenAndServe())
+		}()
+	}
+	go func() {
+		log.Printf("listening with TLS on %s", config.Addr)
+		// The files are ignored when TLSConfig already provides the certificates.
+		errs <- server.ListenAndServeTLS(config.CertFile, config.KeyFile)
+	}()
+	return <-errs
+}
+
+// redirectHandler redirects requests to the same URL on the HTTPS server.
+func redirectHandler(config ListenConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := config.Hostname
+		if host == "" {
+			host = r.Host
+			if h, _, err := net.SplitHostPort(r.Host); err == nil {
+				host = h
+			}
+		}
+		if _, port, err := net.SplitHostPort(config.Addr); err == nil && port != "443" && port != "" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// FailureLimiter blocks clients that make too many failed requests, e.g. with bad signatures. Only
+// failures count, so Twitch is never limited as long as its requests are valid.
+type FailureLimiter struct {
+	max    int
+	window time.Duration
+
+	mu       sync.Mutex
+	failures map[string][]time.Time
+}
+
+// NewFailureLimiter allows each client max failures per window.
+func NewFailureLimiter(max int, window time.Duration) *FailureLimiter {
+	return &FailureLimiter{
+		max:      max,
+		window:   window,
+		failures: make(map[string][]time.Time),
+	}
+}
+
+// Allow reports whether client may make another request.
+func (l *FailureLimiter) Allow(client string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.recent(client, time.Now())) < l.max
+}
+
+// Fail records a failed request of client.
+func (l *FailureLimiter) Fail(client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.failures[client] = append(l.recent(client, now), now)
+	// Forget clients that stopped failing so the map does not grow without bound.
+	for c := range l.failures {
+		l.recent(c, now)
+	}
+}
+
+// recent returns the failures of client within the window, l.mu must be held.
+func (l *FailureLimiter) recent(client string, now time.Time) []time.Time {
+	failures := l.failures[client]
+	i := 0
+	for i < len(failures) && now.Sub(failures[i]) >= l.window {
+		i++
+	}
+	failures = failures[i:]
+	if len(failures) == 0 {
+		delete(l.failures, client)
+		return nil
+	}
+	l.failures[client] = failures
+	return failures
+}
+
+// clientIP returns the IP address of the client making r. If trustProxy is set the address the
+// reverse proxy appended to X-Forwarded-For is used instead of the connection's.
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			addresses := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(addresses[len(addresses)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}